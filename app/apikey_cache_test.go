@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAPIKeyCacheInvalidation(t *testing.T) {
+	h := newHarness(t)
+	w := h.do(request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Acme", "cnpj": "11222333000181"}`})
+	var company map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &company); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create company = %d: %s", w.Code, w.Body)
+	}
+	id, key := str(t, company, "id"), str(t, company, "api_key")
+
+	expect := func(key string, want int) {
+		t.Helper()
+		if w := h.do(request{Method: http.MethodGet, Path: "/promotions", Key: key}); w.Code != want {
+			t.Fatalf("GET /promotions = %d, want %d: %s", w.Code, want, w.Body)
+		}
+	}
+	expect(key, http.StatusOK)
+	expect(key, http.StatusOK)
+
+	w = h.do(request{Method: http.MethodPost, Path: "/companies/" + id + "/rotate-api-key"})
+	var rotated map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &rotated); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("rotate API key = %d: %s", w.Code, w.Body)
+	}
+	newKey := str(t, rotated, "api_key")
+	expect(key, http.StatusUnauthorized)
+	expect(newKey, http.StatusOK)
+
+	if w := h.do(request{Method: http.MethodDelete, Path: "/companies/" + id}); w.Code != http.StatusNoContent {
+		t.Fatalf("deactivate company = %d: %s", w.Code, w.Body)
+	}
+	expect(newKey, http.StatusUnauthorized)
+}
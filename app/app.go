@@ -0,0 +1,384 @@
+// Package app wires the repositories, services, controllers, routes and
+// background workers of the promotions API together, so main and the
+// end-to-end tests build exactly the same application.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"promo-api/config"
+	"promo-api/controllers"
+	"promo-api/events"
+	"promo-api/metrics"
+	"promo-api/middlewares"
+	"promo-api/migrations"
+	"promo-api/ratelimit"
+	"promo-api/repositories"
+	"promo-api/routes"
+	"promo-api/services"
+	"promo-api/tracing"
+	"promo-api/utils"
+	"promo-api/workers"
+)
+
+// Options tune the application. OptionsFromConfig fills them from the
+// loaded configuration.
+type Options struct {
+	// AdminAPIKey authenticates administrators. Empty disables admin access.
+	AdminAPIKey string
+	Clock       utils.Clock
+	// Logger receives the access log. Nil means slog.Default().
+	Logger *slog.Logger
+
+	// OutboxSinks is a comma separated list of the sinks the outbox relay
+	// delivers to: log, stdout and webhooks.
+	OutboxSinks string
+
+	SchedulerInterval  time.Duration
+	PurgeInterval      time.Duration
+	PromotionRetention time.Duration
+	RelayInterval      time.Duration
+	OutboxRetention    time.Duration
+	DispatchInterval   time.Duration
+	// OutboxMaxAttempts is how many times an event is relayed before it is
+	// dead-lettered. Zero retries it forever.
+	OutboxMaxAttempts int
+
+	// IPRateLimit and CompanyRateLimit limit requests per client IP and,
+	// unless the company record sets its own, per company. The zero Limit
+	// disables them. RateLimitStore keeps the buckets; nil keeps them in
+	// memory.
+	IPRateLimit      ratelimit.Limit
+	CompanyRateLimit ratelimit.Limit
+	RateLimitStore   ratelimit.Store
+	// TrustedProxies are the proxies whose forwarding headers give the
+	// client IP that requests are limited and audited by. Without any, it
+	// is the peer address.
+	TrustedProxies []netip.Prefix
+
+	// APIKeyCacheTTL is how long the company of an API key is cached, and
+	// APIKeyCacheNegativeTTL how long an unknown key is. Zero TTL disables
+	// the cache. APIKeyCacheSize bounds the number of keys cached.
+	APIKeyCacheTTL         time.Duration
+	APIKeyCacheNegativeTTL time.Duration
+	APIKeyCacheSize        int
+	// PromotionCacheTTL bounds how stale a cached promotion can be when its
+	// invalidation is missed. Zero disables the cache.
+	PromotionCacheTTL  time.Duration
+	PromotionCacheSize int
+
+	// ReplicaHealthInterval and ReadYourWritesWindow apply when the storage
+	// has read replicas.
+	ReplicaHealthInterval time.Duration
+	ReadYourWritesWindow  time.Duration
+
+	WebhookClient      *http.Client
+	WebhookMaxAttempts int
+	WebhookBaseBackoff time.Duration
+	WebhookMaxBackoff  time.Duration
+	// WebhookAllowPrivateTargets lets webhooks target loopback and private
+	// addresses, for local development and tests.
+	WebhookAllowPrivateTargets bool
+}
+
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		AdminAPIKey:        cfg.AdminAPIKey,
+		Clock:              utils.SystemClock{},
+		OutboxSinks:        cfg.Outbox.Sinks,
+		SchedulerInterval:  cfg.Promotions.SchedulerInterval,
+		PurgeInterval:      cfg.Promotions.PurgeInterval,
+		PromotionRetention: cfg.Promotions.RetentionPeriod,
+		RelayInterval:      cfg.Outbox.RelayInterval,
+		OutboxRetention:    cfg.Outbox.RetentionPeriod,
+		OutboxMaxAttempts:  cfg.Outbox.MaxAttempts,
+		DispatchInterval:   cfg.Webhooks.DispatchInterval,
+		WebhookMaxAttempts: cfg.Webhooks.MaxAttempts,
+		WebhookBaseBackoff: cfg.Webhooks.RetryBaseDelay,
+		WebhookMaxBackoff:  cfg.Webhooks.RetryMaxDelay,
+
+		WebhookAllowPrivateTargets: cfg.Webhooks.AllowPrivateTargets,
+
+		IPRateLimit:      ratelimit.Limit{Rate: cfg.RateLimit.IPPerMinute, Burst: cfg.RateLimit.IPBurst},
+		CompanyRateLimit: ratelimit.Limit{Rate: cfg.RateLimit.CompanyPerMinute, Burst: cfg.RateLimit.CompanyBurst},
+		TrustedProxies:   cfg.HTTP.Proxies(),
+
+		APIKeyCacheTTL:         cfg.APIKeyCache.TTL,
+		APIKeyCacheNegativeTTL: cfg.APIKeyCache.NegativeTTL,
+		APIKeyCacheSize:        cfg.APIKeyCache.Size,
+		PromotionCacheTTL:      cfg.PromotionCache.TTL,
+		PromotionCacheSize:     cfg.PromotionCache.Size,
+
+		ReplicaHealthInterval: cfg.DB.ReplicaHealthInterval,
+		ReadYourWritesWindow:  cfg.DB.ReadYourWritesWindow,
+	}
+}
+
+// Worker is a background job that runs until its context is done.
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+// App is the wired application. Handler serves the API; the workers only
+// run once StartWorkers is called.
+type App struct {
+	Handler http.Handler
+
+	Promotions *services.PromotionService
+	Companies  *services.CompanyService
+	Webhooks   *services.WebhookService
+	Outbox     *services.OutboxService
+
+	Workers []Worker
+	Health  *controllers.HealthController
+	Metrics *metrics.Metrics
+
+	started atomic.Bool
+	exited  atomic.Int32
+	running sync.WaitGroup
+}
+
+func New(store *Storage, opts Options) (*App, error) {
+	clock := opts.Clock
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	limits := opts.RateLimitStore
+	if limits == nil {
+		limits = ratelimit.NewMemoryStore(clock)
+	}
+
+	appMetrics := metrics.New()
+	if store.DB != nil {
+		appMetrics.RegisterDB("primary", store.DB)
+	}
+	if store.Replicas != nil {
+		for i, db := range store.Replicas.DBs() {
+			appMetrics.RegisterDB(fmt.Sprintf("replica_%d", i), db)
+		}
+	}
+
+	// Transactions are tracked so the caches skip them and invalidate what
+	// they wrote once they end.
+	var tx repositories.Transactor
+	if store.Tx != nil {
+		tx = repositories.TrackTx(store.Tx)
+	}
+	caches := map[string]workers.Invalidator{}
+
+	companies := store.Companies
+	var companyCache *repositories.CachedCompanyRepository
+	if opts.APIKeyCacheTTL > 0 {
+		companyCache = repositories.NewCachedCompanyRepository(store.Companies, repositories.CompanyCacheOptions{
+			TTL:         opts.APIKeyCacheTTL,
+			NegativeTTL: opts.APIKeyCacheNegativeTTL,
+			Size:        opts.APIKeyCacheSize,
+			Clock:       clock,
+		})
+		companies = companyCache
+		caches[repositories.CompanyChangesChannel] = companyCache
+	}
+
+	promotions := store.Promotions
+	if opts.PromotionCacheTTL > 0 {
+		promotionCache := repositories.NewCachedPromotionRepository(store.Promotions, repositories.PromotionCacheOptions{
+			TTL:   opts.PromotionCacheTTL,
+			Size:  opts.PromotionCacheSize,
+			Clock: clock,
+		})
+		promotions = promotionCache
+		caches[repositories.PromotionChangesChannel] = promotionCache
+	}
+
+	auditService := &services.AuditService{Repo: store.Audit}
+	auditController := &controllers.AuditController{Service: auditService}
+
+	companyService := &services.CompanyService{
+		Repo:   companies,
+		Audit:  store.Audit,
+		Tx:     tx,
+		Clock:  clock,
+		Outbox: store.Outbox,
+	}
+	companyController := &controllers.CompanyController{Service: companyService}
+
+	webhookService := &services.WebhookService{
+		Repo:        store.Webhooks,
+		Clock:       clock,
+		Client:      opts.WebhookClient,
+		MaxAttempts: opts.WebhookMaxAttempts,
+		BaseBackoff: opts.WebhookBaseBackoff,
+		MaxBackoff:  opts.WebhookMaxBackoff,
+
+		AllowPrivateTargets: opts.WebhookAllowPrivateTargets,
+	}
+	webhookController := &controllers.WebhookController{Service: webhookService}
+
+	promoService := &services.PromotionService{
+		Repo:    promotions,
+		Audit:   store.Audit,
+		Tx:      tx,
+		Clock:   clock,
+		Outbox:  store.Outbox,
+		Metrics: appMetrics,
+	}
+	promoController := &controllers.PromotionController{Service: promoService}
+
+	sink, err := outboxSink(opts.OutboxSinks, webhookService)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure outbox sinks: %w", err)
+	}
+	outboxService := &services.OutboxService{
+		Repo:        store.Outbox,
+		Sink:        sink,
+		Clock:       clock,
+		Retention:   opts.OutboxRetention,
+		MaxAttempts: opts.OutboxMaxAttempts,
+	}
+
+	a := &App{
+		Promotions: promoService,
+		Companies:  companyService,
+		Webhooks:   webhookService,
+		Outbox:     outboxService,
+		Metrics:    appMetrics,
+	}
+	a.Health = &controllers.HealthController{Checks: healthChecks(store, a), Timeout: 2 * time.Second}
+
+	accessLog := middlewares.AccessLog(logger)
+	requestInfo := middlewares.CaptureRequestInfo(opts.TrustedProxies)
+	r := mux.NewRouter()
+	r.NotFoundHandler = tracing.Unmatched(appMetrics.InstrumentUnmatched(
+		requestInfo(accessLog(http.NotFoundHandler()))))
+	r.Use(tracing.Middleware)
+	r.Use(appMetrics.Instrument)
+	r.Use(requestInfo)
+	r.Use(accessLog)
+	routes.ConfigureHealthRoutes(r, a.Health)
+	routes.ConfigureMetricsRoutes(r, appMetrics.Handler())
+
+	authorized := r.PathPrefix("/").Subrouter()
+	authorized.Use(tracing.WrapMiddleware("ValidateContentType", middlewares.ValidateContentType))
+	authorized.Use(tracing.WrapMiddleware("RateLimitByIP",
+		middlewares.RateLimitByIP(limits, opts.IPRateLimit, appMetrics.RateLimited)))
+	authorized.Use(tracing.WrapMiddleware("ValidateAPIKey",
+		middlewares.ValidateAPIKey(companies, opts.AdminAPIKey, appMetrics.AuthFailed)))
+	authorized.Use(tracing.WrapMiddleware("RateLimitByCompany",
+		middlewares.RateLimitByCompany(limits, opts.CompanyRateLimit, appMetrics.RateLimited)))
+	if store.Replicas != nil {
+		authorized.Use(tracing.WrapMiddleware("ReadYourWrites", middlewares.ReadYourWrites(opts.ReadYourWritesWindow)))
+	}
+
+	routes.ConfigurePromotionRoutes(authorized, promoController)
+	routes.ConfigureCompanyRoutes(authorized, companyController)
+	routes.ConfigureAuditRoutes(authorized, auditController)
+	routes.ConfigureWebhookRoutes(authorized, webhookController)
+
+	a.Handler = r
+	a.Workers = []Worker{
+		&workers.PromotionScheduler{Service: promoService, Interval: opts.SchedulerInterval},
+		&workers.PromotionPurger{Service: promoService, Interval: opts.PurgeInterval, Retention: opts.PromotionRetention},
+		&workers.OutboxRelay{Service: outboxService, Interval: opts.RelayInterval},
+		&workers.WebhookDispatcher{Service: webhookService, Interval: opts.DispatchInterval},
+	}
+	if len(caches) > 0 && store.Changes != nil {
+		a.Workers = append(a.Workers, &workers.CacheInvalidator{Changes: store.Changes, Caches: caches})
+	}
+	if store.Replicas != nil {
+		a.Workers = append(a.Workers, &workers.ReplicaMonitor{Replicas: store.Replicas, Interval: opts.ReplicaHealthInterval})
+	}
+
+	return a, nil
+}
+
+// StartWorkers runs every worker in its own goroutine until ctx is done.
+// WaitWorkers blocks until they have all returned.
+func (a *App) StartWorkers(ctx context.Context) {
+	a.started.Store(true)
+	for _, worker := range a.Workers {
+		a.running.Add(1)
+		go func() {
+			defer a.running.Done()
+			defer a.exited.Add(1)
+			worker.Run(ctx)
+		}()
+	}
+}
+
+// WaitWorkers waits for the workers started by StartWorkers to return after
+// their context is done, so storage is not closed under a running job.
+func (a *App) WaitWorkers() {
+	a.running.Wait()
+}
+
+// healthChecks lists what /readyz verifies: the database answers and has
+// every migration applied, and the background workers are running.
+func healthChecks(store *Storage, a *App) []controllers.HealthCheck {
+	var checks []controllers.HealthCheck
+	if store.DB != nil {
+		checks = append(checks,
+			controllers.HealthCheck{Name: "database", Check: func(ctx context.Context) error {
+				return store.DB.PingContext(ctx)
+			}},
+			controllers.HealthCheck{Name: "migrations", Check: func(ctx context.Context) error {
+				pending, err := migrations.Pending(ctx, store.DB)
+				if err != nil {
+					return err
+				}
+				if len(pending) > 0 {
+					return fmt.Errorf("%d pending: %s", len(pending), strings.Join(pending, ", "))
+				}
+				return nil
+			}},
+		)
+	}
+	return append(checks, controllers.HealthCheck{Name: "workers", Check: func(ctx context.Context) error {
+		if !a.started.Load() {
+			return errors.New("not started")
+		}
+		if n := a.exited.Load(); n > 0 {
+			return fmt.Errorf("%d of %d stopped", n, len(a.Workers))
+		}
+		return nil
+	}})
+}
+
+// outboxSink builds the publisher the outbox relay delivers to from a comma
+// separated list of sink names: log, stdout and webhooks. The default is
+// "log,webhooks".
+func outboxSink(names string, webhooks events.Publisher) (events.Publisher, error) {
+	if names == "" {
+		names = "log,webhooks"
+	}
+
+	var sinks events.MultiPublisher
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "log":
+			sinks = append(sinks, events.LogPublisher{})
+		case "stdout":
+			sinks = append(sinks, &events.WriterPublisher{Writer: os.Stdout})
+		case "webhooks":
+			sinks = append(sinks, webhooks)
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCompanyRoutes(t *testing.T) {
+	h := newHarness(t)
+
+	h.check("create_invalid_json", request{Method: http.MethodPost, Path: "/companies", Body: `{"name": 1}`})
+	h.check("create_missing_name", request{Method: http.MethodPost, Path: "/companies", Body: `{"cnpj": "11222333000181"}`})
+	h.check("create_missing_cnpj", request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Acme"}`})
+	h.check("create_invalid_timezone", request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Acme", "cnpj": "11222333000181", "timezone": "Mars/Olympus"}`})
+
+	acme := h.check("create", request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Acme", "cnpj": " 11222333000181 ", "timezone": "America/Sao_Paulo"}`})
+	id := str(t, acme, "id")
+	key := str(t, acme, "api_key")
+	h.check("create_duplicate_cnpj", request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Acme again", "cnpj": "11222333000181"}`})
+	globex := h.check("create_second", request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Globex", "cnpj": "44555666000199"}`})
+
+	h.check("list", request{Method: http.MethodGet, Path: "/companies"})
+	h.check("list_page", request{Method: http.MethodGet, Path: "/companies?limit=1&offset=1"})
+	h.check("list_past_end", request{Method: http.MethodGet, Path: "/companies?offset=5"})
+	h.check("list_as_company", request{Method: http.MethodGet, Path: "/companies?limit=1", Key: key})
+
+	h.check("get", request{Method: http.MethodGet, Path: "/companies/" + id})
+	h.check("get_not_modified", request{Method: http.MethodGet, Path: "/companies/" + id, Header: map[string]string{"If-None-Match": `W/"1", "2"`}})
+	h.check("get_invalid_id", request{Method: http.MethodGet, Path: "/companies/acme"})
+	h.check("get_unknown", request{Method: http.MethodGet, Path: "/companies/00000000-0000-0000-0000-000000000000"})
+
+	update := `{"name": "Acme Corporation", "cnpj": "11222333000181", "timezone": "America/Sao_Paulo", "is_active": true}`
+	h.check("update_without_if_match", request{Method: http.MethodPut, Path: "/companies/" + id, Body: update})
+	h.check("update_stale", request{Method: http.MethodPut, Path: "/companies/" + id, Body: update, Header: map[string]string{"If-Match": `"3"`}})
+	h.check("update_invalid_json", request{Method: http.MethodPut, Path: "/companies/" + id, Body: `nope`, Header: map[string]string{"If-Match": `"1"`}})
+	h.check("update_missing_name", request{Method: http.MethodPut, Path: "/companies/" + id, Body: `{"cnpj": "11222333000181"}`, Header: map[string]string{"If-Match": `"1"`}})
+	h.check("update_duplicate_cnpj", request{Method: http.MethodPut, Path: "/companies/" + id, Body: `{"name": "Acme", "cnpj": "44555666000199"}`, Header: map[string]string{"If-Match": `"1"`}})
+	h.check("update", request{Method: http.MethodPut, Path: "/companies/" + id, Body: update, Header: map[string]string{"If-Match": `"1"`}})
+	h.check("update_unknown", request{Method: http.MethodPut, Path: "/companies/00000000-0000-0000-0000-000000000000", Body: update, Header: map[string]string{"If-Match": "*"}})
+
+	h.check("patch", request{Method: http.MethodPatch, Path: "/companies/" + id, Body: `{"timezone": "UTC"}`,
+		ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": `"2"`}})
+	h.check("patch_read_only_field", request{Method: http.MethodPatch, Path: "/companies/" + id, Body: `{"api_key": "mine"}`,
+		ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": `"3"`}})
+	h.check("patch_invalid_timezone", request{Method: http.MethodPatch, Path: "/companies/" + id, Body: `{"timezone": "Nowhere"}`,
+		ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": `"3"`}})
+	h.check("patch_invalid_document", request{Method: http.MethodPatch, Path: "/companies/" + id, Body: `[1, 2]`,
+		ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": `"3"`}})
+
+	rotated := h.check("rotate_api_key", request{Method: http.MethodPost, Path: "/companies/" + id + "/rotate-api-key"})
+	newKey := str(t, rotated, "api_key")
+	h.check("rotate_invalid_id", request{Method: http.MethodPost, Path: "/companies/acme/rotate-api-key"})
+	h.check("old_key_rejected", request{Method: http.MethodGet, Path: "/promotions", Key: key})
+	h.check("new_key_accepted", request{Method: http.MethodGet, Path: "/promotions", Key: newKey})
+
+	h.check("deactivate", request{Method: http.MethodDelete, Path: "/companies/" + id})
+	h.check("deactivate_invalid_id", request{Method: http.MethodDelete, Path: "/companies/acme"})
+	h.check("get_deactivated", request{Method: http.MethodGet, Path: "/companies/" + id})
+	h.check("deactivated_key_rejected", request{Method: http.MethodGet, Path: "/promotions", Key: newKey})
+	h.check("list_after_deactivation", request{Method: http.MethodGet, Path: "/companies"})
+
+	globexID := str(t, globex, "id")
+	globexKey := str(t, globex, "api_key")
+	h.check("rotate_other_company", request{Method: http.MethodPost, Path: "/companies/" + id + "/rotate-api-key", Key: globexKey})
+	h.check("rotate_unknown", request{Method: http.MethodPost, Path: "/companies/00000000-0000-0000-0000-000000000000/rotate-api-key"})
+	h.check("rotate_deactivated", request{Method: http.MethodPost, Path: "/companies/" + id + "/rotate-api-key"})
+	h.check("deactivate_again", request{Method: http.MethodDelete, Path: "/companies/" + id})
+	h.check("rotate_own_api_key", request{Method: http.MethodPost, Path: "/companies/" + globexID + "/rotate-api-key", Key: globexKey})
+}
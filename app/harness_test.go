@@ -0,0 +1,214 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"promo-api/logging"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const adminKey = "test-admin-key"
+
+// stepClock is a utils.Clock that advances one second on every request, so
+// timestamps in responses are deterministic and distinct. Started workers
+// read it concurrently.
+type stepClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *stepClock) advance() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(time.Second)
+}
+
+// harness drives the application built by New over in-memory storage and
+// checks its responses against golden files.
+type harness struct {
+	t       *testing.T
+	app     *App
+	clock   *stepClock
+	logs    *bytes.Buffer
+	handles map[string]string
+}
+
+// newHarness builds the application with test options, which configure may
+// adjust.
+func newHarness(t *testing.T, configure ...func(*Options)) *harness {
+	t.Helper()
+	clock := &stepClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	logs := &bytes.Buffer{}
+	logger, err := logging.New(logs, "json", "info")
+	if err != nil {
+		t.Fatalf("logging.New: %v", err)
+	}
+	opts := Options{
+		AdminAPIKey: adminKey,
+		Clock:       clock,
+		Logger:      logger,
+		OutboxSinks: "webhooks",
+		// Long enough that started workers run once and then stay idle.
+		SchedulerInterval: time.Hour,
+		PurgeInterval:     time.Hour,
+		RelayInterval:     time.Hour,
+		DispatchInterval:  time.Hour,
+		// Long enough that anything served stale would fail the goldens.
+		APIKeyCacheTTL:         time.Hour,
+		APIKeyCacheNegativeTTL: time.Hour,
+		APIKeyCacheSize:        100,
+		PromotionCacheTTL:      time.Hour,
+		PromotionCacheSize:     100,
+	}
+	for _, fn := range configure {
+		fn(&opts)
+	}
+	app, err := New(MemoryStorage(), opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return &harness{t: t, app: app, clock: clock, logs: logs, handles: map[string]string{}}
+}
+
+// request describes one call. Key defaults to the admin key and ContentType
+// to application/json when there is a body; "-" sends no header at all.
+// RemoteAddr defaults to the one set by httptest.
+type request struct {
+	Method      string
+	Path        string
+	Body        string
+	Key         string
+	ContentType string
+	Header      map[string]string
+	RemoteAddr  string
+}
+
+func (h *harness) do(req request) *httptest.ResponseRecorder {
+	h.t.Helper()
+	h.clock.advance()
+
+	r := httptest.NewRequest(req.Method, req.Path, strings.NewReader(req.Body))
+	switch req.Key {
+	case "":
+		r.Header.Set("X-API-Key", adminKey)
+	case "-":
+	default:
+		r.Header.Set("X-API-Key", req.Key)
+	}
+	switch {
+	case req.ContentType == "-":
+	case req.ContentType != "":
+		r.Header.Set("Content-Type", req.ContentType)
+	case req.Body != "":
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range req.Header {
+		r.Header.Set(name, value)
+	}
+	if req.RemoteAddr != "" {
+		r.RemoteAddr = req.RemoteAddr
+	}
+
+	w := httptest.NewRecorder()
+	h.app.Handler.ServeHTTP(w, r)
+	return w
+}
+
+// check performs the request and compares the response with the golden file
+// testdata/<test name>/<name>.json. It returns the decoded JSON body, if any.
+func (h *harness) check(name string, req request) map[string]any {
+	h.t.Helper()
+	w := h.do(req)
+
+	var body any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		body = strings.TrimSpace(w.Body.String())
+	}
+	headers := map[string]string{}
+	for _, name := range []string{"Content-Type", "ETag"} {
+		if value := w.Header().Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(map[string]any{
+		"request": req.Method + " " + req.Path,
+		"status":  w.Code,
+		"headers": headers,
+		"body":    body,
+	})
+	if err != nil {
+		h.t.Fatalf("%s: failed to encode response: %v", name, err)
+	}
+	got := h.normalize(buf.Bytes())
+
+	path := filepath.Join("testdata", h.t.Name(), name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			h.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		h.t.Fatalf("%s: %v (run go test ./app -update to create it)", name, err)
+	}
+	if !bytes.Equal(got, want) {
+		h.t.Errorf("%s: response differs from %s\ngot:\n%s\nwant:\n%s", name, path, got, want)
+	}
+
+	decoded, _ := body.(map[string]any)
+	return decoded
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	apiKeyPattern = regexp.MustCompile(`\b[0-9a-f]{64}\b`)
+)
+
+// normalize replaces the random parts of a response: UUIDs become handles
+// numbered in order of first appearance within the test, and API keys a
+// placeholder.
+func (h *harness) normalize(b []byte) []byte {
+	b = apiKeyPattern.ReplaceAll(b, []byte("<api-key>"))
+	return uuidPattern.ReplaceAllFunc(b, func(id []byte) []byte {
+		handle, ok := h.handles[string(id)]
+		if !ok {
+			handle = fmt.Sprintf("<id-%d>", len(h.handles)+1)
+			h.handles[string(id)] = handle
+		}
+		return []byte(handle)
+	})
+}
+
+func str(t *testing.T, body map[string]any, field string) string {
+	t.Helper()
+	value, ok := body[field].(string)
+	if !ok {
+		t.Fatalf("response has no string %q: %v", field, body)
+	}
+	return value
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	h := newHarness(t)
+
+	h.check("healthz", request{Method: http.MethodGet, Path: "/healthz", Key: "-"})
+	h.check("healthz_with_invalid_key", request{Method: http.MethodGet, Path: "/healthz", Key: "not-a-key"})
+	h.check("healthz_post", request{Method: http.MethodPost, Path: "/healthz", Key: "-", Body: "ping", ContentType: "text/plain"})
+	h.check("readyz_workers_not_started", request{Method: http.MethodGet, Path: "/readyz", Key: "-"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.app.StartWorkers(ctx)
+	h.check("readyz", request{Method: http.MethodGet, Path: "/readyz", Key: "-"})
+
+	h.app.Health.ShutDown()
+	h.check("readyz_shutting_down", request{Method: http.MethodGet, Path: "/readyz", Key: "-"})
+	h.check("healthz_shutting_down", request{Method: http.MethodGet, Path: "/healthz", Key: "-"})
+
+	cancel()
+	h.app.WaitWorkers()
+	h.check("readyz_workers_stopped", request{Method: http.MethodGet, Path: "/readyz", Key: "-"})
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// lastLog decodes the last line the harness logged.
+func (h *harness) lastLog() map[string]any {
+	h.t.Helper()
+	lines := strings.Split(strings.TrimSpace(h.logs.String()), "\n")
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		h.t.Fatalf("log line is not JSON: %v\n%s", err, h.logs)
+	}
+	return entry
+}
+
+func TestRequestIDs(t *testing.T) {
+	h := newHarness(t)
+
+	w := h.do(request{Method: http.MethodGet, Path: "/promotions", Header: map[string]string{"X-Request-ID": "trace-42"}})
+	if got := w.Header().Get("X-Request-ID"); got != "trace-42" {
+		t.Errorf("propagated X-Request-ID = %q, want trace-42", got)
+	}
+
+	for name, header := range map[string]map[string]string{
+		"missing":  nil,
+		"invalid":  {"X-Request-ID": "two words"},
+		"too long": {"X-Request-ID": strings.Repeat("x", 129)},
+	} {
+		w := h.do(request{Method: http.MethodGet, Path: "/promotions", Header: header})
+		if got := w.Header().Get("X-Request-ID"); !uuidPattern.MatchString(got) {
+			t.Errorf("%s: generated X-Request-ID = %q, want a UUID", name, got)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	h := newHarness(t)
+
+	w := h.do(request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Acme", "cnpj": "11222333000181"}`})
+	var company map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &company); err != nil {
+		t.Fatalf("create company: %v: %s", err, w.Body)
+	}
+	h.do(request{
+		Method: http.MethodGet,
+		Path:   "/promotions/00000000-0000-0000-0000-000000000000",
+		Key:    str(t, company, "api_key"),
+		Header: map[string]string{"X-Request-ID": "trace-42"},
+	})
+	entry := h.lastLog()
+	for field, want := range map[string]any{
+		"msg":        "request",
+		"level":      "INFO",
+		"method":     "GET",
+		"path":       "/promotions/00000000-0000-0000-0000-000000000000",
+		"route":      "/promotions/{id}",
+		"status":     float64(http.StatusNotFound),
+		"company_id": str(t, company, "id"),
+		"request_id": "trace-42",
+	} {
+		if entry[field] != want {
+			t.Errorf("%s = %v, want %v", field, entry[field], want)
+		}
+	}
+	if bytes, _ := entry["bytes"].(float64); bytes == 0 {
+		t.Errorf("bytes = %v, want the size of the error body", entry["bytes"])
+	}
+	if _, ok := entry["latency"].(float64); !ok {
+		t.Errorf("latency = %v, want a duration", entry["latency"])
+	}
+
+	h.do(request{Method: http.MethodGet, Path: "/nothing-here"})
+	entry = h.lastLog()
+	if entry["path"] != "/nothing-here" || entry["status"] != float64(http.StatusNotFound) || entry["request_id"] == nil {
+		t.Errorf("unmatched request logged as %v", entry)
+	}
+	if _, ok := entry["route"]; ok {
+		t.Errorf("unmatched request logged with route %v", entry["route"])
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	h := newHarness(t)
+
+	w := h.do(request{Method: http.MethodPost, Path: "/promotions", Body: springSale})
+	draft := decodeID(t, w.Body.Bytes())
+	w = h.do(request{Method: http.MethodPost, Path: "/promotions", Body: `{
+		"title": "Launch offer", "discount_type": "percentage", "discount_value": 15, "status": "active",
+		"start_date": "2026-03-01T00:00:00Z", "end_date": "2026-04-01T00:00:00Z",
+		"minimum_purchase_amount": 50, "max_usage": 1
+	}`})
+	launch := decodeID(t, w.Body.Bytes())
+	redeem := func(id, amount string) {
+		h.do(request{Method: http.MethodPost, Path: "/promotions/" + id + ":redeem", Body: `{"purchase_amount": ` + amount + `}`})
+	}
+	redeem(launch, "49.9")
+	redeem(launch, "80.5")
+	redeem(launch, "80")
+	redeem(draft, "80")
+	redeem("00000000-0000-0000-0000-000000000000", "80")
+	h.do(request{Method: http.MethodGet, Path: "/promotions/00000000-0000-0000-0000-000000000000"})
+	h.do(request{Method: http.MethodGet, Path: "/promotions/00000000-0000-0000-0000-000000000001"})
+	h.do(request{Method: http.MethodGet, Path: "/promotions", Key: "-"})
+	h.do(request{Method: http.MethodGet, Path: "/promotions", Key: "not-a-key"})
+	h.do(request{Method: http.MethodGet, Path: "/nothing-here"})
+
+	w = h.do(request{Method: http.MethodGet, Path: "/metrics", Key: "-"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="POST",route="/promotions",status="201"} 2`,
+		`http_requests_total{method="POST",route="/promotions/{id}:redeem",status="200"} 1`,
+		`http_requests_total{method="GET",route="/promotions/{id}",status="404"} 2`,
+		`http_requests_total{method="GET",route="/promotions",status="401"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/promotions/{id}",status="404"} 2`,
+		`auth_failures_total{reason="missing_key"} 1`,
+		`auth_failures_total{reason="unknown_key"} 1`,
+		`promotions_created_total{company="admin"} 2`,
+		`promotion_redemptions_total{company="admin"} 1`,
+		`promotion_discount_amount_total{company="admin"} 12.08`,
+		`promotion_redemptions_rejected_total{company="admin",reason="minimum_purchase"} 1`,
+		`promotion_redemptions_rejected_total{company="admin",reason="exhausted"} 1`,
+		`promotion_redemptions_rejected_total{company="admin",reason="not_active"} 1`,
+		`promotion_redemptions_rejected_total{company="admin",reason="not_found"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+	if t.Failed() {
+		t.Logf("metrics:\n%s", body)
+	}
+}
+
+func decodeID(t *testing.T, body []byte) string {
+	t.Helper()
+	var decoded map[string]any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("failed to decode %s: %v", body, err)
+	}
+	return str(t, decoded, "id")
+}
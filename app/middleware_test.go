@@ -0,0 +1,41 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAuthentication(t *testing.T) {
+	h := newHarness(t)
+
+	h.check("missing_key", request{Method: http.MethodGet, Path: "/promotions", Key: "-"})
+	h.check("blank_key", request{Method: http.MethodGet, Path: "/companies", Key: "   "})
+	h.check("unknown_key", request{Method: http.MethodGet, Path: "/companies", Key: "not-a-key"})
+	h.check("unknown_key_on_write", request{Method: http.MethodPost, Path: "/promotions", Key: "not-a-key", Body: springSale})
+	h.check("admin_key", request{Method: http.MethodGet, Path: "/promotions"})
+
+	company := h.check("create_company", request{Method: http.MethodPost, Path: "/companies", Body: `{"name": "Acme", "cnpj": "11222333000181"}`})
+	key := str(t, company, "api_key")
+	h.check("company_key", request{Method: http.MethodGet, Path: "/promotions", Key: key})
+	h.check("company_include_deleted", request{Method: http.MethodGet, Path: "/promotions?include_deleted=true", Key: key})
+	h.check("company_audit_events", request{Method: http.MethodGet, Path: "/audit-events", Key: key})
+	h.check("unknown_route", request{Method: http.MethodGet, Path: "/nothing-here"})
+}
+
+func TestContentType(t *testing.T) {
+	h := newHarness(t)
+
+	h.check("post_without_type", request{Method: http.MethodPost, Path: "/promotions", Body: springSale, ContentType: "-"})
+	h.check("post_text", request{Method: http.MethodPost, Path: "/promotions", Body: springSale, ContentType: "text/plain"})
+	h.check("post_form", request{Method: http.MethodPost, Path: "/companies", Body: "name=Acme", ContentType: "application/x-www-form-urlencoded"})
+	created := h.check("post_json", request{Method: http.MethodPost, Path: "/promotions", Body: springSale})
+	id := str(t, created, "id")
+
+	h.check("put_text", request{Method: http.MethodPut, Path: "/promotions/" + id, Body: springSale, ContentType: "text/plain",
+		Header: map[string]string{"If-Match": `"1"`}})
+	h.check("patch_json_patch", request{Method: http.MethodPatch, Path: "/promotions/" + id, Body: `[{"op": "remove", "path": "/title"}]`,
+		ContentType: "application/json-patch+json", Header: map[string]string{"If-Match": `"1"`}})
+	h.check("patch_plain_json", request{Method: http.MethodPatch, Path: "/promotions/" + id, Body: `{"title": "Plain JSON patch"}`,
+		Header: map[string]string{"If-Match": `"1"`}})
+	h.check("bodiless_action", request{Method: http.MethodPost, Path: "/promotions/" + id + ":resume"})
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+)
+
+const springSale = `{
+	"title": "Spring sale",
+	"description": "25% off everything",
+	"discount_type": "percentage",
+	"discount_value": 25,
+	"start_date": "2026-03-01T00:00:00Z",
+	"end_date": "2026-04-01T00:00:00Z",
+	"coupon_code": "SPRING25"
+}`
+
+func TestPromotionRoutes(t *testing.T) {
+	h := newHarness(t)
+
+	h.check("create_invalid_json", request{Method: http.MethodPost, Path: "/promotions", Body: `{"title":`})
+	h.check("create_invalid_dates", request{Method: http.MethodPost, Path: "/promotions", Body: `{
+		"title": "Backwards", "discount_type": "fixed", "discount_value": 5,
+		"start_date": "2026-04-01T00:00:00Z", "end_date": "2026-03-01T00:00:00Z"
+	}`})
+	h.check("create_invalid_discount", request{Method: http.MethodPost, Path: "/promotions", Body: `{
+		"title": "Free", "discount_type": "fixed", "discount_value": 0,
+		"start_date": "2026-03-01T00:00:00Z", "end_date": "2026-04-01T00:00:00Z"
+	}`})
+	h.check("create_invalid_status", request{Method: http.MethodPost, Path: "/promotions", Body: `{
+		"title": "Odd", "discount_type": "fixed", "discount_value": 5, "status": "bogus",
+		"start_date": "2026-03-01T00:00:00Z", "end_date": "2026-04-01T00:00:00Z"
+	}`})
+
+	created := h.check("create", request{Method: http.MethodPost, Path: "/promotions", Body: springSale})
+	id := str(t, created, "id")
+	h.check("create_scheduled", request{Method: http.MethodPost, Path: "/promotions", Body: `{
+		"title": "Summer sale", "discount_type": "fixed", "discount_value": 10, "status": "scheduled",
+		"start_date": "2026-06-01T00:00:00Z", "end_date": "2026-07-01T00:00:00Z", "coupon_code": "SUMMER10"
+	}`})
+	h.check("create_with_schedule", request{Method: http.MethodPost, Path: "/promotions", Body: `{
+		"title": "Happy hour", "discount_type": "percentage", "discount_value": 15, "status": "active",
+		"start_date": "2026-03-01T00:00:00Z", "end_date": "2026-12-31T00:00:00Z",
+		"schedule": {"days_of_week": ["friday"], "time_windows": [{"start": "17:00", "end": "19:00"}]}
+	}`})
+
+	h.check("list", request{Method: http.MethodGet, Path: "/promotions"})
+	h.check("list_page", request{Method: http.MethodGet, Path: "/promotions?limit=1&offset=1"})
+	h.check("list_past_end", request{Method: http.MethodGet, Path: "/promotions?limit=10&offset=10"})
+	h.check("list_invalid_pagination", request{Method: http.MethodGet, Path: "/promotions?limit=-1&offset=abc"})
+	h.check("list_status", request{Method: http.MethodGet, Path: "/promotions?status=scheduled"})
+	h.check("list_invalid_status", request{Method: http.MethodGet, Path: "/promotions?status=bogus"})
+	h.check("list_active_at", request{Method: http.MethodGet, Path: "/promotions?active_at=2026-06-15T00:00:00Z"})
+	h.check("list_invalid_active_at", request{Method: http.MethodGet, Path: "/promotions?active_at=tomorrow"})
+	h.check("list_active_at_and_status", request{Method: http.MethodGet, Path: "/promotions?active_at=2026-06-15T00:00:00Z&status=active"})
+	h.check("list_invalid_include_deleted", request{Method: http.MethodGet, Path: "/promotions?include_deleted=maybe"})
+
+	h.check("coupon", request{Method: http.MethodGet, Path: "/promotions/coupon?coupon=spring"})
+	h.check("coupon_no_match", request{Method: http.MethodGet, Path: "/promotions/coupon?coupon=winter"})
+	h.check("coupon_missing", request{Method: http.MethodGet, Path: "/promotions/coupon"})
+
+	h.check("get", request{Method: http.MethodGet, Path: "/promotions/" + id})
+	h.check("get_not_modified", request{Method: http.MethodGet, Path: "/promotions/" + id, Header: map[string]string{"If-None-Match": `"1"`}})
+	h.check("get_invalid_id", request{Method: http.MethodGet, Path: "/promotions/not-a-uuid"})
+	h.check("get_unknown", request{Method: http.MethodGet, Path: "/promotions/00000000-0000-0000-0000-000000000000"})
+
+	update := `{
+		"title": "Spring sale extended",
+		"description": "25% off everything",
+		"discount_type": "percentage",
+		"discount_value": 25,
+		"start_date": "2026-03-01T00:00:00Z",
+		"end_date": "2026-04-15T00:00:00Z",
+		"coupon_code": "SPRING25"
+	}`
+	h.check("update_without_if_match", request{Method: http.MethodPut, Path: "/promotions/" + id, Body: update})
+	h.check("update_stale", request{Method: http.MethodPut, Path: "/promotions/" + id, Body: update, Header: map[string]string{"If-Match": `"7"`}})
+	h.check("update_invalid_if_match", request{Method: http.MethodPut, Path: "/promotions/" + id, Body: update, Header: map[string]string{"If-Match": `W/"1"`}})
+	h.check("update_invalid_json", request{Method: http.MethodPut, Path: "/promotions/" + id, Body: `[]`, Header: map[string]string{"If-Match": `"1"`}})
+	h.check("update", request{Method: http.MethodPut, Path: "/promotions/" + id, Body: update, Header: map[string]string{"If-Match": `"1"`}})
+	h.check("update_unknown", request{Method: http.MethodPut, Path: "/promotions/00000000-0000-0000-0000-000000000000", Body: update, Header: map[string]string{"If-Match": "*"}})
+
+	h.check("patch", request{Method: http.MethodPatch, Path: "/promotions/" + id, Body: `{"discount_value": 30, "coupon_code": null}`,
+		ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": `"2"`}})
+	h.check("patch_read_only_field", request{Method: http.MethodPatch, Path: "/promotions/" + id, Body: `{"id": "00000000-0000-0000-0000-000000000000"}`,
+		ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": `"3"`}})
+	h.check("patch_invalid_discount", request{Method: http.MethodPatch, Path: "/promotions/" + id, Body: `{"discount_value": -1}`,
+		ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": `"3"`}})
+
+	h.check("pause_draft", request{Method: http.MethodPost, Path: "/promotions/" + id + ":pause"})
+	h.check("publish", request{Method: http.MethodPost, Path: "/promotions/" + id + ":resume"})
+	h.check("pause", request{Method: http.MethodPost, Path: "/promotions/" + id + ":pause"})
+	h.check("pause_again", request{Method: http.MethodPost, Path: "/promotions/" + id + ":pause"})
+	h.check("resume", request{Method: http.MethodPost, Path: "/promotions/" + id + ":resume"})
+	h.check("transition_invalid_id", request{Method: http.MethodPost, Path: "/promotions/not-a-uuid:pause"})
+	h.check("transitions", request{Method: http.MethodGet, Path: "/promotions/" + id + "/transitions"})
+	h.check("transitions_unknown", request{Method: http.MethodGet, Path: "/promotions/00000000-0000-0000-0000-000000000000/transitions"})
+
+	h.check("revisions", request{Method: http.MethodGet, Path: "/promotions/" + id + "/revisions"})
+	h.check("revisions_unknown", request{Method: http.MethodGet, Path: "/promotions/00000000-0000-0000-0000-000000000000/revisions"})
+	h.check("get_as_of", request{Method: http.MethodGet, Path: "/promotions/" + id + "?as_of=2026-03-01T12:00:06Z"})
+	h.check("get_as_of_before_creation", request{Method: http.MethodGet, Path: "/promotions/" + id + "?as_of=2026-01-01T00:00:00Z"})
+	h.check("get_invalid_as_of", request{Method: http.MethodGet, Path: "/promotions/" + id + "?as_of=yesterday"})
+	h.check("rollback_without_if_match", request{Method: http.MethodPost, Path: "/promotions/" + id + "/revisions/1:rollback"})
+	h.check("rollback_invalid_revision", request{Method: http.MethodPost, Path: "/promotions/" + id + "/revisions/0:rollback", Header: map[string]string{"If-Match": "*"}})
+	h.check("rollback_unknown_revision", request{Method: http.MethodPost, Path: "/promotions/" + id + "/revisions/99:rollback", Header: map[string]string{"If-Match": "*"}})
+	h.check("rollback", request{Method: http.MethodPost, Path: "/promotions/" + id + "/revisions/1:rollback", Header: map[string]string{"If-Match": `"6"`}})
+
+	h.check("archive", request{Method: http.MethodPost, Path: "/promotions/" + id + ":archive"})
+	h.check("update_archived", request{Method: http.MethodPut, Path: "/promotions/" + id, Body: update, Header: map[string]string{"If-Match": "*"}})
+	h.check("resume_archived", request{Method: http.MethodPost, Path: "/promotions/" + id + ":resume"})
+
+	h.check("delete", request{Method: http.MethodDelete, Path: "/promotions/" + id})
+	h.check("delete_again", request{Method: http.MethodDelete, Path: "/promotions/" + id})
+	h.check("delete_invalid_id", request{Method: http.MethodDelete, Path: "/promotions/not-a-uuid"})
+	h.check("get_deleted", request{Method: http.MethodGet, Path: "/promotions/" + id})
+	h.check("get_deleted_included", request{Method: http.MethodGet, Path: "/promotions/" + id + "?include_deleted=true"})
+	h.check("list_include_deleted", request{Method: http.MethodGet, Path: "/promotions?include_deleted=true&limit=1"})
+	h.check("restore", request{Method: http.MethodPost, Path: "/promotions/" + id + ":restore"})
+	h.check("restore_again", request{Method: http.MethodPost, Path: "/promotions/" + id + ":restore"})
+}
+
+func TestPromotionRedemption(t *testing.T) {
+	h := newHarness(t)
+
+	created := h.check("create", request{Method: http.MethodPost, Path: "/promotions", Body: `{
+		"title": "Launch offer", "discount_type": "percentage", "discount_value": 15, "status": "active",
+		"start_date": "2026-03-01T00:00:00Z", "end_date": "2026-04-01T00:00:00Z",
+		"minimum_purchase_amount": 50, "max_usage": 1
+	}`})
+	id := str(t, created, "id")
+	draft := h.check("create_draft", request{Method: http.MethodPost, Path: "/promotions", Body: springSale})
+
+	h.check("redeem_invalid_id", request{Method: http.MethodPost, Path: "/promotions/not-a-uuid:redeem", Body: `{"purchase_amount": 80}`})
+	h.check("redeem_invalid_json", request{Method: http.MethodPost, Path: "/promotions/" + id + ":redeem", Body: `{"purchase_amount":`})
+	h.check("redeem_invalid_amount", request{Method: http.MethodPost, Path: "/promotions/" + id + ":redeem", Body: `{"purchase_amount": 0}`})
+	h.check("redeem_unknown", request{Method: http.MethodPost, Path: "/promotions/00000000-0000-0000-0000-000000000000:redeem", Body: `{"purchase_amount": 80}`})
+	h.check("redeem_draft", request{Method: http.MethodPost, Path: "/promotions/" + str(t, draft, "id") + ":redeem", Body: `{"purchase_amount": 80}`})
+	h.check("redeem_below_minimum", request{Method: http.MethodPost, Path: "/promotions/" + id + ":redeem", Body: `{"purchase_amount": 49.9}`})
+	h.check("redeem", request{Method: http.MethodPost, Path: "/promotions/" + id + ":redeem", Body: `{"purchase_amount": 80}`})
+	h.check("redeem_exhausted", request{Method: http.MethodPost, Path: "/promotions/" + id + ":redeem", Body: `{"purchase_amount": 80}`})
+	h.check("get_redeemed", request{Method: http.MethodGet, Path: "/promotions/" + id})
+}
+
+func TestPromotionIfMatchLists(t *testing.T) {
+	h := newHarness(t)
+
+	created := h.check("create", request{Method: http.MethodPost, Path: "/promotions", Body: springSale})
+	path := "/promotions/" + str(t, created, "id")
+	patch := func(name, ifMatch, body string) {
+		t.Helper()
+		h.check(name, request{Method: http.MethodPatch, Path: path, Body: body,
+			ContentType: "application/merge-patch+json", Header: map[string]string{"If-Match": ifMatch}})
+	}
+
+	patch("several_strong_tags", `"1", "2"`, `{"discount_value": 30}`)
+	patch("star_in_list", `*, "1"`, `{"discount_value": 30}`)
+	patch("only_weak_tags", `W/"1", W/"2"`, `{"discount_value": 30}`)
+	patch("weak_and_strong_tags", `W/"7", "1"`, `{"discount_value": 30}`)
+	patch("stale_tag_in_list", `W/"2", "1", `, `{"discount_value": 35}`)
+	patch("empty_list_elements", `, "2",`, `{"discount_value": 35}`)
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"promo-api/ratelimit"
+)
+
+func TestRateLimitByIP(t *testing.T) {
+	h := newHarness(t, func(opts *Options) {
+		opts.IPRateLimit = ratelimit.Limit{Rate: 1, Burst: 2}
+	})
+
+	// The limit applies before the API key is checked.
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		w := h.do(request{Method: http.MethodGet, Path: "/promotions", Key: "not-a-key"})
+		if w.Code != want {
+			t.Fatalf("request %d = %d, want %d", i+1, w.Code, want)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d RateLimit-Limit = %q, want 2", i+1, got)
+		}
+	}
+
+	w := h.do(request{Method: http.MethodGet, Path: "/promotions"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("admin request over the IP limit = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "57" {
+		t.Errorf("Retry-After = %q, want 57", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	if w := h.do(request{Method: http.MethodGet, Path: "/healthz"}); w.Code != http.StatusOK {
+		t.Errorf("GET /healthz = %d, want probes left unlimited", w.Code)
+	}
+}
+
+// TestRateLimitByIPBehindProxy checks that forwarding headers only choose
+// the bucket of requests that come through a trusted proxy.
+func TestRateLimitByIPBehindProxy(t *testing.T) {
+	h := newHarness(t, func(opts *Options) {
+		opts.IPRateLimit = ratelimit.Limit{Rate: 1, Burst: 1}
+		opts.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	})
+	for _, tt := range []struct {
+		name       string
+		remoteAddr string
+		header     map[string]string
+		want       int
+	}{
+		// Untrusted peers are limited by their own address, whatever they
+		// claim to forward.
+		{"untrusted peer", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, http.StatusUnauthorized},
+		{"untrusted peer, other claimed client", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.2"}, http.StatusTooManyRequests},
+
+		// Behind a trusted proxy, the rightmost hop it does not trust is
+		// the client; what the client prepends is ignored.
+		{"trusted proxy", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2"}, http.StatusUnauthorized},
+		{"trusted proxy, other client", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.2"}, http.StatusUnauthorized},
+		{"trusted proxy, spoofed hop", "10.0.0.3:1234", map[string]string{"X-Forwarded-For": "192.0.2.99, 198.51.100.1"}, http.StatusTooManyRequests},
+		{"trusted proxy, malformed hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.3, bogus, 10.0.0.2"}, http.StatusUnauthorized},
+		{"trusted proxy, no header", "10.0.0.2:1234", nil, http.StatusTooManyRequests},
+
+		{"trusted proxy, Forwarded", "10.0.0.1:1234", map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https`}, http.StatusUnauthorized},
+		{"trusted proxy, Forwarded again", "10.0.0.1:1234", map[string]string{"Forwarded": "for=192.0.2.60, for=\"[2001:db8::1]\""}, http.StatusTooManyRequests},
+	} {
+		w := h.do(request{Method: http.MethodGet, Path: "/promotions", Key: "not-a-key", Header: tt.header, RemoteAddr: tt.remoteAddr})
+		if w.Code != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRateLimitByCompany(t *testing.T) {
+	h := newHarness(t, func(opts *Options) {
+		opts.CompanyRateLimit = ratelimit.Limit{Rate: 60, Burst: 3}
+	})
+	create := func(body string) map[string]any {
+		w := h.do(request{Method: http.MethodPost, Path: "/companies", Body: body})
+		var company map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &company); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("create company = %d: %s", w.Code, w.Body)
+		}
+		return company
+	}
+	limited := create(`{"name": "Acme", "cnpj": "11222333000181", "rate_limit": 1, "rate_limit_burst": 1}`)
+	if limited["rate_limit"] != float64(1) || limited["rate_limit_burst"] != float64(1) {
+		t.Fatalf("created company limits = %v/%v, want 1/1", limited["rate_limit"], limited["rate_limit_burst"])
+	}
+	other := create(`{"name": "Globex", "cnpj": "11444777000161"}`)
+	key, otherKey := str(t, limited, "api_key"), str(t, other, "api_key")
+
+	if w := h.do(request{Method: http.MethodGet, Path: "/promotions", Key: key}); w.Code != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", w.Code)
+	}
+	w := h.do(request{Method: http.MethodGet, Path: "/promotions", Key: key})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request = %d, want 429 under the company's own limit", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "1;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 1;w=60", got)
+	}
+
+	// Other companies have buckets of their own at the default limit, and
+	// the administrator is not limited per company.
+	for range 3 {
+		if w := h.do(request{Method: http.MethodGet, Path: "/promotions", Key: otherKey}); w.Code != http.StatusOK {
+			t.Fatalf("other company request = %d, want 200", w.Code)
+		}
+	}
+	for range 5 {
+		if w := h.do(request{Method: http.MethodGet, Path: "/promotions"}); w.Code != http.StatusOK {
+			t.Fatalf("admin request = %d, want 200", w.Code)
+		}
+	}
+
+	// Companies cannot raise their own limits.
+	w = h.do(request{
+		Method:      http.MethodPatch,
+		Path:        "/companies/" + str(t, other, "id"),
+		Key:         otherKey,
+		Body:        `{"rate_limit": 100000}`,
+		ContentType: "application/merge-patch+json",
+		Header:      map[string]string{"If-Match": `"1"`},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("company patching its rate limit = %d, want 400", w.Code)
+	}
+}
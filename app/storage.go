@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"promo-api/config"
+	"promo-api/migrations"
+	"promo-api/repositories"
+	"promo-api/repositories/memory"
+)
+
+// Storage is the set of repositories the API runs on.
+type Storage struct {
+	Companies  repositories.CompanyRepositoryInterface
+	Promotions repositories.PromotionRepositoryInterface
+	Audit      repositories.AuditRepositoryInterface
+	Webhooks   repositories.WebhookRepositoryInterface
+	Outbox     repositories.OutboxRepositoryInterface
+	Tx         repositories.Transactor
+
+	// DB is the primary database pool, or nil for in-memory storage.
+	DB *sqlx.DB
+
+	// Replicas are the read replicas the repositories route reads to, or nil
+	// without any.
+	Replicas *repositories.Replicas
+
+	// Changes reports the rows changed by any instance, for the caches to
+	// invalidate, or is nil when the backend is not shared with other
+	// instances or nothing is cached.
+	Changes *repositories.Changes
+
+	// Close releases the backend.
+	Close func()
+}
+
+// OpenStorage opens the backend named by cfg.Storage: "postgres" or
+// "memory", which keeps everything in process memory and loses it on exit.
+// The memory backend needs no database and suits local development.
+func OpenStorage(cfg *config.Config) (*Storage, error) {
+	switch cfg.Storage {
+	case "postgres":
+		db, err := config.ConnectDB(cfg.DB)
+		if err != nil {
+			return nil, err
+		}
+		if err := migrations.Apply(context.Background(), db); err != nil {
+			config.CloseDB()
+			return nil, fmt.Errorf("failed to apply migrations: %w", err)
+		}
+
+		var replicas *repositories.Replicas
+		replicaDBs, err := config.OpenReplicas(cfg.DB)
+		if err != nil {
+			config.CloseDB()
+			return nil, err
+		}
+		if len(replicaDBs) > 0 {
+			replicas = repositories.NewReplicas(replicaDBs)
+			replicas.CheckHealth(context.Background(), cfg.DB.ReplicaHealthInterval)
+		}
+
+		var changes *repositories.Changes
+		if cfg.APIKeyCache.TTL > 0 || cfg.PromotionCache.TTL > 0 {
+			changes, err = listenChanges(cfg.DB)
+			if err != nil {
+				config.CloseDB()
+				return nil, err
+			}
+		}
+
+		return &Storage{
+			Companies:  &repositories.CompanyRepository{DB: db, Replicas: replicas},
+			Promotions: &repositories.PromotionRepository{DB: db, Replicas: replicas},
+			Audit:      &repositories.AuditRepository{DB: db},
+			Webhooks:   &repositories.WebhookRepository{DB: db},
+			Outbox:     &repositories.OutboxRepository{DB: db},
+			Tx:         &repositories.SQLTransactor{DB: db},
+			DB:         config.GetDB(),
+			Replicas:   replicas,
+			Changes:    changes,
+			Close: func() {
+				if changes != nil {
+					changes.Close()
+				}
+				config.CloseDB()
+			},
+		}, nil
+	case "memory":
+		log.Println("Using in-memory storage; data is lost on exit")
+		return MemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+	}
+}
+
+// listenChanges opens the listener the caches of all instances are
+// invalidated through.
+func listenChanges(cfg config.DBConfig) (*repositories.Changes, error) {
+	listener, err := config.NewListener(cfg, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Changes listener connection event", "event", event, "error", err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	changes, err := repositories.ListenChanges(listener,
+		repositories.CompanyChangesChannel, repositories.PromotionChangesChannel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return changes, nil
+}
+
+// MemoryStorage returns empty in-memory storage.
+func MemoryStorage() *Storage {
+	store := memory.NewStore()
+	return &Storage{
+		Companies:  &memory.CompanyRepository{Store: store},
+		Promotions: &memory.PromotionRepository{Store: store},
+		Audit:      &memory.AuditRepository{Store: store},
+		Webhooks:   &memory.WebhookRepository{Store: store},
+		Outbox:     &memory.OutboxRepository{Store: store},
+		Tx:         store,
+		Close:      func() {},
+	}
+}
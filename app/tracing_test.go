@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"promo-api/tracing"
+)
+
+// recordSpans installs a tracer provider that keeps every span for the rest
+// of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), "none", "promo-api"); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestTracing(t *testing.T) {
+	recorder := recordSpans(t)
+	h := newHarness(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	h.do(request{
+		Method: http.MethodGet,
+		Path:   "/promotions/00000000-0000-0000-0000-000000000000",
+		Header: map[string]string{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"},
+	})
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, ok := spans["GET /promotions/{id}"]
+	if !ok {
+		t.Fatalf("no server span among %v", spans)
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("server span trace = %s, want the caller's %s", got, traceID)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the caller's span", got)
+	}
+
+	// Middleware and service spans are siblings under the server span: the
+	// middleware span covers the middleware alone.
+	for _, name := range []string{"middleware ValidateContentType", "middleware ValidateAPIKey", "PromotionService.GetPromotion"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %q span among %v", name, spans)
+			continue
+		}
+		if span.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("%q is not a child of the server span", name)
+		}
+	}
+	if service, ok := spans["PromotionService.GetPromotion"]; ok && len(service.Events()) == 0 {
+		t.Errorf("service span recorded no error for the missing promotion")
+	}
+}
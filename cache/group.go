@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// Group runs one load per key at a time: callers asking for a key already
+// being loaded wait for that load and share its result. It is safe for
+// concurrent use.
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+type call[V any] struct {
+	done    chan struct{}
+	waiters int
+	value   V
+	err     error
+}
+
+// Do returns the result of load, or of the load already running for key.
+// shared reports whether the result came from another caller's load. If
+// load panics, the panic goes on in the caller that ran it and the callers
+// waiting for it get an error.
+func (g *Group[K, V]) Do(key K, load func() (V, error)) (value V, err error, shared bool) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		c.waiters++
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+	if g.calls == nil {
+		g.calls = map[K]*call[V]{}
+	}
+	c := &call[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// Until load returns, waiters see errLoadPanicked, so a panic does not
+	// pass for a successful load of the zero value.
+	c.err = errLoadPanicked
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = load()
+	return c.value, c.err, false
+}
+
+var errLoadPanicked = errors.New("cache load panicked")
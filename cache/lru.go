@@ -0,0 +1,116 @@
+// Package cache provides the bounded in-process caches used to spare the
+// database repeated lookups.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"promo-api/utils"
+)
+
+// LRU is a cache of at most Size entries, each kept for at most its TTL.
+// When full, adding an entry evicts the least recently used one. It is safe
+// for concurrent use.
+type LRU[K comparable, V any] struct {
+	size  int
+	clock utils.Clock
+
+	mu      sync.Mutex
+	order   *list.List // of *entry[K, V], most recently used first
+	entries map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// NewLRU returns an empty cache of at most size entries. A nil clock uses
+// the system time.
+func NewLRU[K comparable, V any](size int, clock utils.Clock) *LRU[K, V] {
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+	return &LRU[K, V]{size: size, clock: clock, order: list.New(), entries: map[K]*list.Element{}}
+}
+
+// Get returns the value cached for key, if it has not expired.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		e := element.Value.(*entry[K, V])
+		if c.clock.Now().Before(e.expires) {
+			c.order.MoveToFront(element)
+			return e.value, true
+		}
+		c.remove(element)
+	}
+	var zero V
+	return zero, false
+}
+
+// Set caches value for key for ttl.
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.clock.Now().Add(ttl)
+	if element, ok := c.entries[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete removes key.
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// DeleteFunc removes every entry for which del returns true.
+func (c *LRU[K, V]) DeleteFunc(del func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if e := element.Value.(*entry[K, V]); del(e.key, e.value) {
+			c.remove(element)
+		}
+		element = next
+	}
+}
+
+// Purge removes every entry.
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// Len returns the number of entries, including expired ones not yet
+// removed.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+type fixedClock struct{ now time.Time }
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+func TestLRU(t *testing.T) {
+	clock := &fixedClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewLRU[string, int](2, clock)
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v, want 1, true", v, ok)
+	}
+	// b is now the least recently used.
+	c.Set("c", 3, time.Second)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if _, ok := c.Get("c"); ok {
+		t.Error("c outlived its TTL")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %d, %v, want 1, true", v, ok)
+	}
+
+	c.Set("b", 2, time.Minute)
+	c.DeleteFunc(func(key string, value int) bool { return value > 1 })
+	if _, ok := c.Get("b"); ok {
+		t.Error("DeleteFunc kept b")
+	}
+	c.Delete("a")
+	if c.Len() != 0 {
+		t.Errorf("Len = %d after deleting everything, want 0", c.Len())
+	}
+}
+
+func TestGroupSharesConcurrentLoads(t *testing.T) {
+	var g Group[string, int]
+	release := make(chan struct{})
+	loads := 0
+	results := make(chan int)
+
+	go func() {
+		v, _, _ := g.Do("a", func() (int, error) {
+			loads++
+			<-release
+			return 1, nil
+		})
+		results <- v
+	}()
+	waitForWaiters(&g, "a", 0)
+	go func() {
+		v, _, shared := g.Do("a", func() (int, error) {
+			t.Error("second load ran concurrently with the first")
+			return 2, nil
+		})
+		if !shared {
+			t.Error("second call did not share the first load")
+		}
+		results <- v
+	}()
+
+	waitForWaiters(&g, "a", 1)
+	close(release)
+	if a, b := <-results, <-results; a != 1 || b != 1 || loads != 1 {
+		t.Errorf("results = %d, %d after %d loads, want 1, 1 after 1", a, b, loads)
+	}
+
+	v, _, shared := g.Do("a", func() (int, error) { return 3, nil })
+	if v != 3 || shared {
+		t.Errorf("Do after the load finished = %d, shared %v, want a new load", v, shared)
+	}
+}
+
+func TestGroupReportsPanicsToWaiters(t *testing.T) {
+	var g Group[string, *int]
+	release := make(chan struct{})
+
+	panicked := make(chan any)
+	go func() {
+		defer func() { panicked <- recover() }()
+		g.Do("a", func() (*int, error) {
+			<-release
+			panic("boom")
+		})
+	}()
+	waitForWaiters(&g, "a", 0)
+
+	waited := make(chan error)
+	go func() {
+		v, err, _ := g.Do("a", func() (*int, error) { return nil, nil })
+		if v != nil {
+			t.Errorf("waiter got %v, want nil", v)
+		}
+		waited <- err
+	}()
+	waitForWaiters(&g, "a", 1)
+	close(release)
+
+	if p := <-panicked; p != "boom" {
+		t.Errorf("leader recovered %v, want the load's panic", p)
+	}
+	if err := <-waited; err == nil {
+		t.Error("waiter got no error from a load that panicked")
+	}
+}
+
+// waitForWaiters waits until a load of key runs with n callers waiting for
+// it.
+func waitForWaiters[V any](g *Group[string, V], key string, n int) {
+	for {
+		g.mu.Lock()
+		c := g.calls[key]
+		ok := c != nil && c.waiters == n
+		g.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
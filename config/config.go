@@ -0,0 +1,527 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config is the effective configuration of the API. Load builds it from the
+// defaults, an optional YAML or TOML file and the environment, in increasing
+// order of precedence.
+//
+// Each field names its key in the config file with the config tag (nested
+// under the key of its section) and its environment variable with the env
+// tag. Fields tagged secret are redacted by Print.
+type Config struct {
+	// Storage is the backend: "postgres" or "memory".
+	Storage string `config:"storage" env:"STORAGE"`
+	// AdminAPIKey authenticates administrators. Empty disables admin access.
+	AdminAPIKey string `config:"admin_api_key" env:"ADMIN_API_KEY" secret:"true"`
+
+	Log        LogConfig        `config:"log"`
+	Tracing    TracingConfig    `config:"tracing"`
+	HTTP       HTTPConfig       `config:"http"`
+	DB         DBConfig         `config:"db"`
+	Promotions PromotionsConfig `config:"promotions"`
+	Outbox     OutboxConfig     `config:"outbox"`
+	Webhooks   WebhooksConfig   `config:"webhooks"`
+	RateLimit  RateLimitConfig  `config:"rate_limit"`
+
+	APIKeyCache    APIKeyCacheConfig    `config:"api_key_cache"`
+	PromotionCache PromotionCacheConfig `config:"promotion_cache"`
+}
+
+type LogConfig struct {
+	// Level is the least severe level logged: debug, info, warn or error.
+	Level string `config:"level" env:"LOG_LEVEL"`
+	// Format is json, for log collectors, or text, for reading in a
+	// terminal.
+	Format string `config:"format" env:"LOG_FORMAT"`
+}
+
+type TracingConfig struct {
+	// Exporter is none, which records no spans, or otlp, which sends them
+	// to the collector named by the standard OTEL_EXPORTER_OTLP_* variables.
+	Exporter    string `config:"exporter" env:"TRACING_EXPORTER"`
+	ServiceName string `config:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+type HTTPConfig struct {
+	Addr              string        `config:"addr" env:"HTTP_ADDR"`
+	ReadTimeout       time.Duration `config:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `config:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `config:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	MaxHeaderBytes    int           `config:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	ShutdownTimeout   time.Duration `config:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay is how long /readyz fails before the server stops
+	// accepting connections, for load balancers to notice.
+	ShutdownDelay time.Duration `config:"shutdown_delay" env:"HTTP_SHUTDOWN_DELAY"`
+	// TrustedProxies is a comma separated list of the addresses, or CIDR
+	// ranges, of the proxies in front of the API. Only requests from them
+	// have the client IP taken from X-Forwarded-For or Forwarded.
+	TrustedProxies string `config:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES"`
+}
+
+// Proxies returns the trusted proxy ranges, a single address being a range
+// of its own. Invalid entries are left out; Validate reports them.
+func (c HTTPConfig) Proxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(c.TrustedProxies, ",") {
+		if prefix, err := parseProxy(strings.TrimSpace(entry)); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+func parseProxy(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+type DBConfig struct {
+	// URL is a full connection string, either a postgres:// URL or key=value
+	// pairs. When set it replaces Host, Port, User, Password and Name, and
+	// the settings below only fill in what it leaves out.
+	URL string `config:"url" env:"DATABASE_URL" secret:"true"`
+
+	Host     string `config:"host" env:"DB_HOST"`
+	Port     int    `config:"port" env:"DB_PORT"`
+	User     string `config:"user" env:"DB_USER"`
+	Password string `config:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `config:"name" env:"DB_NAME"`
+
+	// SSLMode is disable, require, verify-ca or verify-full. Managed
+	// databases should use verify-full.
+	SSLMode     string `config:"sslmode" env:"DB_SSLMODE"`
+	SSLRootCert string `config:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert     string `config:"sslcert" env:"DB_SSLCERT"`
+	SSLKey      string `config:"sslkey" env:"DB_SSLKEY"`
+
+	ApplicationName string `config:"application_name" env:"DB_APPLICATION_NAME"`
+	// ConnectTimeout bounds establishing a connection, in whole seconds.
+	// StatementTimeout aborts statements that run longer. Zero disables
+	// either.
+	ConnectTimeout   time.Duration `config:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	StatementTimeout time.Duration `config:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+
+	// ReplicaURLs is a comma separated list of read replica connection
+	// strings, in the same forms as URL. The settings above other than the
+	// connection fields apply to them too.
+	ReplicaURLs string `config:"replica_urls" env:"DB_REPLICA_URLS" secret:"true"`
+	// ReplicaHealthInterval is how often replicas are pinged; failing ones
+	// get no reads until they recover.
+	ReplicaHealthInterval time.Duration `config:"replica_health_interval" env:"DB_REPLICA_HEALTH_INTERVAL"`
+	// ReadYourWritesWindow is how long after a client's write its reads stay
+	// on the primary. It should exceed the usual replication lag.
+	ReadYourWritesWindow time.Duration `config:"read_your_writes_window" env:"DB_READ_YOUR_WRITES_WINDOW"`
+
+	MaxOpenConns int `config:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns int `config:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	// ConnMaxLifetime and ConnMaxIdleTime of zero keep connections forever.
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `config:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+// Replicas returns the read replica connection strings.
+func (c DBConfig) Replicas() []string {
+	var urls []string
+	for _, url := range strings.Split(c.ReplicaURLs, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+type PromotionsConfig struct {
+	SchedulerInterval time.Duration `config:"scheduler_interval" env:"PROMOTION_SCHEDULER_INTERVAL"`
+	PurgeInterval     time.Duration `config:"purge_interval" env:"PROMOTION_PURGE_INTERVAL"`
+	RetentionPeriod   time.Duration `config:"retention_period" env:"PROMOTION_RETENTION_PERIOD"`
+}
+
+type OutboxConfig struct {
+	// Sinks is a comma separated list of the sinks the outbox relay delivers
+	// to: log, stdout and webhooks.
+	Sinks           string        `config:"sinks" env:"OUTBOX_SINKS"`
+	RelayInterval   time.Duration `config:"relay_interval" env:"OUTBOX_RELAY_INTERVAL"`
+	RetentionPeriod time.Duration `config:"retention_period" env:"OUTBOX_RETENTION_PERIOD"`
+	// MaxAttempts is how many times the relay tries a message before it
+	// dead-letters it.
+	MaxAttempts int `config:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS"`
+}
+
+type WebhooksConfig struct {
+	DispatchInterval time.Duration `config:"dispatch_interval" env:"WEBHOOK_DISPATCH_INTERVAL"`
+	MaxAttempts      int           `config:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	RetryBaseDelay   time.Duration `config:"retry_base_delay" env:"WEBHOOK_RETRY_BASE_DELAY"`
+	RetryMaxDelay    time.Duration `config:"retry_max_delay" env:"WEBHOOK_RETRY_MAX_DELAY"`
+	// AllowPrivateTargets lets webhooks target loopback and private
+	// addresses, for local development only.
+	AllowPrivateTargets bool `config:"allow_private_targets" env:"WEBHOOK_ALLOW_PRIVATE_TARGETS"`
+}
+
+// RateLimitConfig sets the token buckets requests are limited by: one per
+// client IP, checked before the API key, and one per company, whose record
+// may override the company defaults. A rate of zero disables the limit.
+type RateLimitConfig struct {
+	IPPerMinute      int `config:"ip_per_minute" env:"RATE_LIMIT_IP_PER_MINUTE"`
+	IPBurst          int `config:"ip_burst" env:"RATE_LIMIT_IP_BURST"`
+	CompanyPerMinute int `config:"company_per_minute" env:"RATE_LIMIT_COMPANY_PER_MINUTE"`
+	CompanyBurst     int `config:"company_burst" env:"RATE_LIMIT_COMPANY_BURST"`
+}
+
+// APIKeyCacheConfig sizes the in-process cache of the company each API key
+// authenticates. Changes made through any instance invalidate it at once, so
+// TTL only bounds staleness when invalidation is missed. A TTL of zero
+// disables the cache and a NegativeTTL of zero stops caching unknown keys.
+type APIKeyCacheConfig struct {
+	TTL         time.Duration `config:"ttl" env:"API_KEY_CACHE_TTL"`
+	NegativeTTL time.Duration `config:"negative_ttl" env:"API_KEY_CACHE_NEGATIVE_TTL"`
+	Size        int           `config:"size" env:"API_KEY_CACHE_SIZE"`
+}
+
+// PromotionCacheConfig sizes the in-process cache of promotions looked up by
+// ID and by coupon. Changes made through any instance invalidate it at once,
+// so TTL only bounds how stale a promotion, and its usage count, can be when
+// invalidation is missed. A TTL of zero disables the cache.
+type PromotionCacheConfig struct {
+	TTL  time.Duration `config:"ttl" env:"PROMOTION_CACHE_TTL"`
+	Size int           `config:"size" env:"PROMOTION_CACHE_SIZE"`
+}
+
+// Defaults returns the configuration used for everything the file and the
+// environment leave unset.
+func Defaults() *Config {
+	return &Config{
+		Storage: "postgres",
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "promo-api",
+		},
+		HTTP: HTTPConfig{
+			Addr:              ":8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		DB: DBConfig{
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			ApplicationName: "promo-api",
+			ConnectTimeout:  10 * time.Second,
+
+			ReplicaHealthInterval: 5 * time.Second,
+			ReadYourWritesWindow:  5 * time.Second,
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Promotions: PromotionsConfig{
+			SchedulerInterval: time.Minute,
+			PurgeInterval:     time.Hour,
+			RetentionPeriod:   90 * 24 * time.Hour,
+		},
+		Outbox: OutboxConfig{
+			Sinks:           "log,webhooks",
+			RelayInterval:   time.Second,
+			RetentionPeriod: 7 * 24 * time.Hour,
+			MaxAttempts:     10,
+		},
+		Webhooks: WebhooksConfig{
+			DispatchInterval: 10 * time.Second,
+			MaxAttempts:      8,
+			RetryBaseDelay:   30 * time.Second,
+			RetryMaxDelay:    time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			IPPerMinute:      300,
+			IPBurst:          60,
+			CompanyPerMinute: 600,
+			CompanyBurst:     100,
+		},
+		APIKeyCache: APIKeyCacheConfig{
+			TTL:         30 * time.Second,
+			NegativeTTL: 10 * time.Second,
+			Size:        10000,
+		},
+		PromotionCache: PromotionCacheConfig{
+			TTL:  10 * time.Second,
+			Size: 10000,
+		},
+	}
+}
+
+// Load builds the configuration. It loads .env, if there is one, into the
+// environment, then applies the config file at path over the defaults and
+// the environment over both. An empty path falls back to CONFIG_FILE; with
+// neither, no file is read.
+//
+// Every problem found, from unreadable values to failed validation, is
+// reported together in the returned error.
+func Load(path string) (*Config, error) {
+	if err := LoadEnv(); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := Defaults()
+	var problems []error
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, applyFile(cfg, path, values)...)
+	}
+	problems = append(problems, applyEnv(cfg, os.LookupEnv)...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, invalid(problems)
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration and reports every problem it finds.
+func (c *Config) Validate() error {
+	if problems := c.validate(); len(problems) > 0 {
+		return invalid(problems)
+	}
+	return nil
+}
+
+func (c *Config) validate() []error {
+	var problems []error
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	switch c.Storage {
+	case "postgres":
+		if c.DB.URL != "" {
+			if _, err := parseURL(c.DB.URL); err != nil {
+				fail("db.url (DATABASE_URL) is invalid: %v", err)
+			}
+			break
+		}
+		if c.DB.Host == "" {
+			fail("db.host (DB_HOST) is required")
+		}
+		if c.DB.User == "" {
+			fail("db.user (DB_USER) is required")
+		}
+		if c.DB.Name == "" {
+			fail("db.name (DB_NAME) is required")
+		}
+	case "memory":
+	default:
+		fail("storage (STORAGE) must be postgres or memory, got %q", c.Storage)
+	}
+
+	if c.DB.Port < 1 || c.DB.Port > 65535 {
+		fail("db.port (DB_PORT) must be between 1 and 65535, got %d", c.DB.Port)
+	}
+	for _, url := range c.DB.Replicas() {
+		if _, err := parseURL(url); err != nil {
+			fail("db.replica_urls (DB_REPLICA_URLS) has an invalid entry: %v", err)
+		}
+	}
+	if c.DB.ReplicaHealthInterval <= 0 {
+		fail("db.replica_health_interval (DB_REPLICA_HEALTH_INTERVAL) must be positive, got %s", c.DB.ReplicaHealthInterval)
+	}
+	if c.DB.ReadYourWritesWindow < 0 {
+		fail("db.read_your_writes_window (DB_READ_YOUR_WRITES_WINDOW) must not be negative, got %s", c.DB.ReadYourWritesWindow)
+	}
+
+	switch c.DB.SSLMode {
+	case "disable":
+		if c.DB.SSLRootCert != "" || c.DB.SSLCert != "" || c.DB.SSLKey != "" {
+			fail("db.sslmode (DB_SSLMODE) is disable but TLS certificates are configured")
+		}
+	case "require", "verify-ca", "verify-full":
+	default:
+		fail("db.sslmode (DB_SSLMODE) must be disable, require, verify-ca or verify-full, got %q", c.DB.SSLMode)
+	}
+	if (c.DB.SSLCert == "") != (c.DB.SSLKey == "") {
+		fail("db.sslcert (DB_SSLCERT) and db.sslkey (DB_SSLKEY) must be set together")
+	}
+	for _, file := range []struct{ name, path string }{
+		{"db.sslrootcert (DB_SSLROOTCERT)", c.DB.SSLRootCert},
+		{"db.sslcert (DB_SSLCERT)", c.DB.SSLCert},
+		{"db.sslkey (DB_SSLKEY)", c.DB.SSLKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			fail("%s is not readable: %v", file.name, err)
+		}
+	}
+	if c.DB.ConnectTimeout < 0 {
+		fail("db.connect_timeout (DB_CONNECT_TIMEOUT) must not be negative, got %s", c.DB.ConnectTimeout)
+	}
+	if c.DB.StatementTimeout < 0 {
+		fail("db.statement_timeout (DB_STATEMENT_TIMEOUT) must not be negative, got %s", c.DB.StatementTimeout)
+	}
+
+	if c.DB.MaxOpenConns < 1 {
+		fail("db.max_open_conns (DB_MAX_OPEN_CONNS) must be at least 1, got %d", c.DB.MaxOpenConns)
+	}
+	if c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		fail("db.max_idle_conns (DB_MAX_IDLE_CONNS) must be between 0 and db.max_open_conns, got %d", c.DB.MaxIdleConns)
+	}
+	if c.DB.ConnMaxLifetime < 0 {
+		fail("db.conn_max_lifetime (DB_CONN_MAX_LIFETIME) must not be negative, got %s", c.DB.ConnMaxLifetime)
+	}
+	if c.DB.ConnMaxIdleTime < 0 {
+		fail("db.conn_max_idle_time (DB_CONN_MAX_IDLE_TIME) must not be negative, got %s", c.DB.ConnMaxIdleTime)
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("log.level (LOG_LEVEL) must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		fail("log.format (LOG_FORMAT) must be json or text, got %q", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none":
+	case "otlp":
+		if c.Tracing.ServiceName == "" {
+			fail("tracing.service_name (OTEL_SERVICE_NAME) is required with the otlp exporter")
+		}
+	default:
+		fail("tracing.exporter (TRACING_EXPORTER) must be none or otlp, got %q", c.Tracing.Exporter)
+	}
+
+	for _, limit := range []struct {
+		rateName, burstName string
+		rate, burst         int
+	}{
+		{
+			"rate_limit.ip_per_minute (RATE_LIMIT_IP_PER_MINUTE)", "rate_limit.ip_burst (RATE_LIMIT_IP_BURST)",
+			c.RateLimit.IPPerMinute, c.RateLimit.IPBurst,
+		},
+		{
+			"rate_limit.company_per_minute (RATE_LIMIT_COMPANY_PER_MINUTE)", "rate_limit.company_burst (RATE_LIMIT_COMPANY_BURST)",
+			c.RateLimit.CompanyPerMinute, c.RateLimit.CompanyBurst,
+		},
+	} {
+		if limit.rate < 0 {
+			fail("%s must not be negative, got %d", limit.rateName, limit.rate)
+		}
+		if limit.rate > 0 && limit.burst < 1 {
+			fail("%s must be at least 1, got %d", limit.burstName, limit.burst)
+		}
+	}
+
+	if c.APIKeyCache.TTL < 0 {
+		fail("api_key_cache.ttl (API_KEY_CACHE_TTL) must not be negative, got %s", c.APIKeyCache.TTL)
+	}
+	if c.APIKeyCache.NegativeTTL < 0 {
+		fail("api_key_cache.negative_ttl (API_KEY_CACHE_NEGATIVE_TTL) must not be negative, got %s", c.APIKeyCache.NegativeTTL)
+	}
+	if c.APIKeyCache.TTL > 0 && c.APIKeyCache.Size < 1 {
+		fail("api_key_cache.size (API_KEY_CACHE_SIZE) must be at least 1, got %d", c.APIKeyCache.Size)
+	}
+
+	if c.PromotionCache.TTL < 0 {
+		fail("promotion_cache.ttl (PROMOTION_CACHE_TTL) must not be negative, got %s", c.PromotionCache.TTL)
+	}
+	if c.PromotionCache.TTL > 0 && c.PromotionCache.Size < 1 {
+		fail("promotion_cache.size (PROMOTION_CACHE_SIZE) must be at least 1, got %d", c.PromotionCache.Size)
+	}
+
+	if c.HTTP.Addr == "" {
+		fail("http.addr (HTTP_ADDR) is required")
+	}
+	if c.HTTP.ShutdownDelay < 0 {
+		fail("http.shutdown_delay (HTTP_SHUTDOWN_DELAY) must not be negative, got %s", c.HTTP.ShutdownDelay)
+	}
+	for _, entry := range strings.Split(c.HTTP.TrustedProxies, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if _, err := parseProxy(entry); err != nil {
+			fail("http.trusted_proxies (HTTP_TRUSTED_PROXIES) has an invalid entry %q; use an IP address or CIDR range", entry)
+		}
+	}
+	if c.HTTP.MaxHeaderBytes < 1 {
+		fail("http.max_header_bytes (HTTP_MAX_HEADER_BYTES) must be at least 1, got %d", c.HTTP.MaxHeaderBytes)
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"http.read_timeout (HTTP_READ_TIMEOUT)", c.HTTP.ReadTimeout},
+		{"http.read_header_timeout (HTTP_READ_HEADER_TIMEOUT)", c.HTTP.ReadHeaderTimeout},
+		{"http.write_timeout (HTTP_WRITE_TIMEOUT)", c.HTTP.WriteTimeout},
+		{"http.idle_timeout (HTTP_IDLE_TIMEOUT)", c.HTTP.IdleTimeout},
+		{"http.shutdown_timeout (HTTP_SHUTDOWN_TIMEOUT)", c.HTTP.ShutdownTimeout},
+		{"promotions.scheduler_interval (PROMOTION_SCHEDULER_INTERVAL)", c.Promotions.SchedulerInterval},
+		{"promotions.purge_interval (PROMOTION_PURGE_INTERVAL)", c.Promotions.PurgeInterval},
+		{"promotions.retention_period (PROMOTION_RETENTION_PERIOD)", c.Promotions.RetentionPeriod},
+		{"outbox.relay_interval (OUTBOX_RELAY_INTERVAL)", c.Outbox.RelayInterval},
+		{"outbox.retention_period (OUTBOX_RETENTION_PERIOD)", c.Outbox.RetentionPeriod},
+		{"webhooks.dispatch_interval (WEBHOOK_DISPATCH_INTERVAL)", c.Webhooks.DispatchInterval},
+		{"webhooks.retry_base_delay (WEBHOOK_RETRY_BASE_DELAY)", c.Webhooks.RetryBaseDelay},
+		{"webhooks.retry_max_delay (WEBHOOK_RETRY_MAX_DELAY)", c.Webhooks.RetryMaxDelay},
+	} {
+		if d.value <= 0 {
+			fail("%s must be positive, got %s", d.name, d.value)
+		}
+	}
+
+	for _, sink := range strings.Split(c.Outbox.Sinks, ",") {
+		switch strings.TrimSpace(sink) {
+		case "log", "stdout", "webhooks":
+		default:
+			fail("outbox.sinks (OUTBOX_SINKS) has unknown sink %q; use log, stdout or webhooks", sink)
+		}
+	}
+
+	if c.Outbox.MaxAttempts < 1 {
+		fail("outbox.max_attempts (OUTBOX_MAX_ATTEMPTS) must be at least 1, got %d", c.Outbox.MaxAttempts)
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		fail("webhooks.max_attempts (WEBHOOK_MAX_ATTEMPTS) must be at least 1, got %d", c.Webhooks.MaxAttempts)
+	}
+	if c.Webhooks.RetryBaseDelay > c.Webhooks.RetryMaxDelay {
+		fail("webhooks.retry_base_delay (WEBHOOK_RETRY_BASE_DELAY) must not exceed webhooks.retry_max_delay")
+	}
+
+	return problems
+}
+
+// invalid joins problems into one error, one problem per line.
+func invalid(problems []error) error {
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+}
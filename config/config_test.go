@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setEnv clears every variable Load reads and then sets vars, so the tests
+// do not depend on the environment they run in.
+func setEnv(t *testing.T, vars map[string]string) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, f := range fields(Defaults()) {
+		t.Setenv(f.env, "")
+	}
+	for key, value := range vars {
+		t.Setenv(key, value)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaultsAndEnv(t *testing.T) {
+	setEnv(t, map[string]string{
+		"DB_USER":              "promo",
+		"DB_NAME":              "promotions",
+		"DB_PORT":              "6432",
+		"DB_CONN_MAX_LIFETIME": "10m",
+		"WEBHOOK_MAX_ATTEMPTS": " 3 ",
+	})
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := Defaults()
+	want.DB.User = "promo"
+	want.DB.Name = "promotions"
+	want.DB.Port = 6432
+	want.DB.ConnMaxLifetime = 10 * time.Minute
+	want.Webhooks.MaxAttempts = 3
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load =\n%+v\nwant\n%+v", cfg, want)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+storage: postgres
+http:
+  addr: 127.0.0.1:9000
+  shutdown_timeout: 1m
+db:
+  host: db.internal
+  user: promo
+  name: promotions
+  max_open_conns: 50
+`,
+		"config.toml": `
+storage = "postgres"
+
+[http]
+addr = "127.0.0.1:9000"
+shutdown_timeout = "1m"
+
+[db]
+host = "db.internal"
+user = "promo"
+name = "promotions"
+max_open_conns = 50
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			setEnv(t, map[string]string{"DB_HOST": "db.override"})
+			t.Setenv("CONFIG_FILE", writeFile(t, name, content))
+
+			cfg, err := Load("")
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			want := Defaults()
+			want.HTTP.Addr = "127.0.0.1:9000"
+			want.HTTP.ShutdownTimeout = time.Minute
+			want.DB.Host = "db.override"
+			want.DB.User = "promo"
+			want.DB.Name = "promotions"
+			want.DB.MaxOpenConns = 50
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("Load =\n%+v\nwant\n%+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	setEnv(t, map[string]string{
+		"STORAGE":              "postgres",
+		"DB_PORT":              "five",
+		"DB_MAX_IDLE_CONNS":    "30",
+		"HTTP_READ_TIMEOUT":    "15",
+		"OUTBOX_SINKS":         "log,kafka",
+		"OUTBOX_MAX_ATTEMPTS":  "0",
+		"HTTP_TRUSTED_PROXIES": "10.0.0.0/8, proxy.internal",
+	})
+	path := writeFile(t, "config.yaml", "db:\n  hots: localhost\nwebhooks:\n  max_attempts: 0\n")
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load = nil error, want every problem reported")
+	}
+	for _, want := range []string{
+		"unknown setting db.hots",
+		`DB_PORT: invalid integer "five"`,
+		`HTTP_READ_TIMEOUT: invalid duration "15"`,
+		"db.user (DB_USER) is required",
+		"db.name (DB_NAME) is required",
+		"db.max_idle_conns (DB_MAX_IDLE_CONNS) must be between 0 and db.max_open_conns",
+		`unknown sink "kafka"`,
+		"outbox.max_attempts (OUTBOX_MAX_ATTEMPTS) must be at least 1",
+		"webhooks.max_attempts (WEBHOOK_MAX_ATTEMPTS) must be at least 1",
+		`http.trusted_proxies (HTTP_TRUSTED_PROXIES) has an invalid entry "proxy.internal"`,
+	} {
+		if !containsLine(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestHTTPProxies(t *testing.T) {
+	cfg := HTTPConfig{TrustedProxies: " 10.1.2.3/8, 192.0.2.10 ,::ffff:198.51.100.1,2001:db8::/32,"}
+	var got []string
+	for _, prefix := range cfg.Proxies() {
+		got = append(got, prefix.String())
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.10/32", "198.51.100.1/32", "2001:db8::/32"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Proxies = %v, want %v", got, want)
+	}
+}
+
+func TestLoadRejectsUnsupportedFile(t *testing.T) {
+	setEnv(t, nil)
+	if _, err := Load(writeFile(t, "config.json", "{}")); err == nil {
+		t.Error("Load(config.json) = nil error")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load(missing.yaml) = nil error")
+	}
+}
+
+func TestPrintRedactsSecretsAndLoadsBack(t *testing.T) {
+	setEnv(t, map[string]string{
+		"STORAGE":       "memory",
+		"ADMIN_API_KEY": "admin-secret",
+		"DB_PASSWORD":   "db-secret",
+	})
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	printed := out.String()
+	for _, secret := range []string{"admin-secret", "db-secret"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("Print leaked %q:\n%s", secret, printed)
+		}
+	}
+	if !strings.Contains(printed, "password: '[redacted]' # DB_PASSWORD") {
+		t.Errorf("Print does not show the redacted password:\n%s", printed)
+	}
+
+	setEnv(t, nil)
+	reloaded, err := Load(writeFile(t, "printed.yaml", printed))
+	if err != nil {
+		t.Fatalf("Load(printed): %v", err)
+	}
+	cfg.AdminAPIKey, cfg.DB.Password = redacted, redacted
+	if !reflect.DeepEqual(reloaded, cfg) {
+		t.Errorf("printed config loads back as\n%+v\nwant\n%+v", reloaded, cfg)
+	}
+}
+
+// containsLine reports whether a line of the reported problems contains want.
+func containsLine(problems, want string) bool {
+	for _, line := range strings.Split(problems, "\n") {
+		if strings.Contains(line, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,57 +3,165 @@ package config
 import (
 	"fmt"
 	"log"
-	"os"
-	"sync"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var (
 	dbInstance *sqlx.DB
-	once       sync.Once
+	replicas   []*sqlx.DB
 )
 
-func ConnectDB() {
-	err := godotenv.Load()
+// ConnectDB opens the PostgreSQL pool described by cfg and verifies it with
+// a ping. The pool is also kept for GetDB.
+func ConnectDB(cfg DBConfig) (*sqlx.DB, error) {
+	db, err := open(cfg)
 	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+		return nil, err
 	}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping the database: %w", err)
+	}
+
+	if cfg.URL != "" {
+		log.Println("Connected to database from DATABASE_URL")
+	} else {
+		log.Printf("Connected to database at %s:%d (sslmode %s)", cfg.Host, cfg.Port, cfg.SSLMode)
+	}
+	dbInstance = db
+	return db, nil
+}
+
+// OpenReplicas opens a pool for each of cfg.Replicas(). They are not
+// pinged: a replica that is down must not stop the service, and the
+// replica health checks take care of routing around it.
+func OpenReplicas(cfg DBConfig) ([]*sqlx.DB, error) {
+	var dbs []*sqlx.DB
+	for i, url := range cfg.Replicas() {
+		replicaCfg := cfg
+		replicaCfg.URL = url
+		db, err := open(replicaCfg)
+		if err != nil {
+			for _, opened := range dbs {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("read replica %d: %w", i, err)
+		}
+		dbs = append(dbs, db)
+	}
+	replicas = dbs
+	if len(dbs) > 0 {
+		log.Printf("Routing reads to %d read replicas", len(dbs))
+	}
+	return dbs, nil
+}
+
+// NewListener opens a connection to the primary described by cfg for
+// LISTEN, which a pool cannot do. It reconnects by itself, waiting between
+// minReconnect and maxReconnect, and reports connection events to onEvent.
+func NewListener(cfg DBConfig, minReconnect, maxReconnect time.Duration, onEvent pq.EventCallbackType) (*pq.Listener, error) {
+	connStr, err := dsn(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return pq.NewListener(connStr, minReconnect, maxReconnect, onEvent), nil
+}
+
+func open(cfg DBConfig) (*sqlx.DB, error) {
+	connStr, err := dsn(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	dbInstance, err = sqlx.Connect("postgres", connStr)
+	db, err := sqlx.Open("postgres", connStr)
 	if err != nil {
-		log.Fatalf("Error connecting to the database: %v", err)
+		return nil, fmt.Errorf("failed to open the database: %w", err)
 	}
 
-	dbInstance.SetMaxOpenConns(25)
-	dbInstance.SetMaxIdleConns(25)
-	dbInstance.SetConnMaxLifetime(5 * 60)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// dsn builds the lib/pq connection string for cfg. lib/pq lets later
+// pairs override earlier ones, so the pairs from DATABASE_URL come last and
+// win over the individual settings.
+func dsn(cfg DBConfig) (string, error) {
+	var pairs []string
+	add := func(key, value string) {
+		if value != "" {
+			pairs = append(pairs, key+"="+quote(value))
+		}
+	}
+
+	if cfg.URL == "" {
+		add("host", cfg.Host)
+		add("port", strconv.Itoa(cfg.Port))
+		add("user", cfg.User)
+		add("password", cfg.Password)
+		add("dbname", cfg.Name)
+	}
+	add("sslmode", cfg.SSLMode)
+	add("sslrootcert", cfg.SSLRootCert)
+	add("sslcert", cfg.SSLCert)
+	add("sslkey", cfg.SSLKey)
+	add("application_name", cfg.ApplicationName)
+	if cfg.ConnectTimeout > 0 {
+		add("connect_timeout", strconv.Itoa(int(math.Ceil(cfg.ConnectTimeout.Seconds()))))
+	}
+	if cfg.StatementTimeout > 0 {
+		// lib/pq sends unknown keys to the server as run-time parameters.
+		add("statement_timeout", strconv.FormatInt(int64(cfg.StatementTimeout/time.Millisecond), 10))
+	}
 
-	if err := dbInstance.Ping(); err != nil {
-		log.Fatalf("Error pinging the database: %v", err)
+	if cfg.URL != "" {
+		url, err := parseURL(cfg.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+		pairs = append(pairs, url)
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// parseURL converts a postgres:// URL to key=value pairs. Anything else is
+// taken to be key=value pairs already.
+func parseURL(url string) (string, error) {
+	if strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://") {
+		return pq.ParseURL(url)
 	}
+	if !strings.Contains(url, "=") {
+		return "", fmt.Errorf("expected a postgres:// URL or key=value pairs")
+	}
+	return url, nil
+}
 
-	log.Printf("Connecting to database at %s:%s", os.Getenv("DB_HOST"), os.Getenv("DB_PORT"))
+// quote escapes a value for a key=value connection string.
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
 
+// GetDB returns the pool opened by ConnectDB, or nil before it is called.
 func GetDB() *sqlx.DB {
-	once.Do(func() {
-		ConnectDB()
-	})
 	return dbInstance
 }
 
+// CloseDB closes the primary pool and any read replica pools.
 func CloseDB() {
+	for i, db := range replicas {
+		if err := db.Close(); err != nil {
+			log.Printf("Error closing read replica %d: %v", i, err)
+		}
+	}
 	if dbInstance != nil {
 		if err := dbInstance.Close(); err != nil {
 			log.Printf("Error closing the database: %v", err)
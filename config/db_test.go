@@ -0,0 +1,85 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDSN(t *testing.T) {
+	base := Defaults().DB
+	base.User = "promo"
+	base.Password = `it's a \secret`
+	base.Name = "promotions"
+
+	tls := base
+	tls.SSLMode = "verify-full"
+	tls.SSLRootCert = "/etc/ssl/ca.pem"
+	tls.SSLCert = "/etc/ssl/client.pem"
+	tls.SSLKey = "/etc/ssl/client.key"
+	tls.ConnectTimeout = 2500 * time.Millisecond
+	tls.StatementTimeout = 30 * time.Second
+
+	url := Defaults().DB
+	url.URL = "postgres://app:pw@db.example.com:6543/promos?sslmode=require"
+	url.Host = "ignored"
+
+	pairs := Defaults().DB
+	pairs.URL = "host=db.example.com dbname=promos"
+
+	tests := []struct {
+		name string
+		cfg  DBConfig
+		want string
+	}{
+		{"defaults", base, `host='localhost' port='5432' user='promo' password='it\'s a \\secret' dbname='promotions' ` +
+			`sslmode='disable' application_name='promo-api' connect_timeout='10'`},
+		{"tls and timeouts", tls, `host='localhost' port='5432' user='promo' password='it\'s a \\secret' dbname='promotions' ` +
+			`sslmode='verify-full' sslrootcert='/etc/ssl/ca.pem' sslcert='/etc/ssl/client.pem' sslkey='/etc/ssl/client.key' ` +
+			`application_name='promo-api' connect_timeout='3' statement_timeout='30000'`},
+		{"url", url, `sslmode='disable' application_name='promo-api' connect_timeout='10' ` +
+			`dbname='promos' host='db.example.com' password='pw' port='6543' sslmode='require' user='app'`},
+		{"key value url", pairs, `sslmode='disable' application_name='promo-api' connect_timeout='10' host=db.example.com dbname=promos`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dsn(tt.cfg)
+			if err != nil {
+				t.Fatalf("dsn: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("dsn =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDatabaseTLS(t *testing.T) {
+	cfg := Defaults()
+	cfg.DB.URL = "mysql://db"
+	cfg.DB.SSLMode = "prefer"
+	cfg.DB.SSLCert = "/does/not/exist.pem"
+	cfg.DB.StatementTimeout = -time.Second
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate = nil error")
+	}
+	for _, want := range []string{
+		"db.url (DATABASE_URL) is invalid",
+		`db.sslmode (DB_SSLMODE) must be disable, require, verify-ca or verify-full, got "prefer"`,
+		"db.sslcert (DB_SSLCERT) and db.sslkey (DB_SSLKEY) must be set together",
+		"db.sslcert (DB_SSLCERT) is not readable",
+		"db.statement_timeout (DB_STATEMENT_TIMEOUT) must not be negative",
+	} {
+		if !containsLine(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+
+	cfg = Defaults()
+	cfg.DB.URL = "postgres://app@db.example.com/promos"
+	cfg.DB.Host, cfg.DB.User, cfg.DB.Name = "", "", ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with DATABASE_URL only = %v", err)
+	}
+}
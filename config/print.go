@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[redacted]"
+
+// Print writes the configuration to w as YAML that Load accepts back, with
+// secrets redacted. Unset secrets print empty so it stays clear they are
+// missing.
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{}
+	for _, f := range fields(c) {
+		parent := root
+		key := f.key
+		if section, name, ok := strings.Cut(f.key, "."); ok {
+			if sections[section] == nil {
+				sections[section] = &yaml.Node{Kind: yaml.MappingNode}
+				root.Content = append(root.Content, scalar(section), sections[section])
+			}
+			parent, key = sections[section], name
+		}
+
+		value := fmt.Sprint(f.value.Interface())
+		if d, ok := f.value.Interface().(time.Duration); ok {
+			value = d.String()
+		}
+		if f.secret && value != "" {
+			value = redacted
+		}
+		node := scalar(value)
+		if value == "" {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+		node.LineComment = f.env
+		parent.Content = append(parent.Content, scalar(key), node)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+	return enc.Close()
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
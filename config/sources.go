@@ -0,0 +1,171 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadEnv loads variables from a .env file in the working directory, if
+// there is one. Variables already set in the environment take precedence.
+func LoadEnv() error {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to load .env: %w", err)
+	}
+	return nil
+}
+
+// field is one setting of Config: its dotted key in the config file, its
+// environment variable and where its value lives.
+type field struct {
+	key    string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields lists the settings of cfg in declaration order.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := prefix + f.Tag.Get("config")
+			if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			out = append(out, field{
+				key:    key,
+				env:    f.Tag.Get("env"),
+				secret: f.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// set parses raw into the field.
+func (f field) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(raw)
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		f.value.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q; use true or false", raw)
+		}
+		f.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q; use a value such as 30s or 5m", raw)
+		}
+		f.value.SetInt(int64(d))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+// applyEnv sets every field whose environment variable is set. Empty
+// variables count as unset, so a copy of sample.env keeps the defaults.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) []error {
+	var problems []error
+	for _, f := range fields(cfg) {
+		raw, ok := lookup(f.env)
+		if !ok || strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if err := f.set(raw); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", f.env, err))
+		}
+	}
+	return problems
+}
+
+// readFile decodes a YAML (.yaml, .yml) or TOML (.toml) config file.
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s; use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// applyFile sets the fields present in the decoded config file. Keys that
+// match no field are reported rather than ignored, so typos are caught.
+func applyFile(cfg *Config, path string, values map[string]any) []error {
+	flat := map[string]any{}
+	flatten(values, "", flat)
+
+	var problems []error
+	for _, f := range fields(cfg) {
+		value, ok := flat[f.key]
+		if !ok {
+			continue
+		}
+		delete(flat, f.key)
+		if value == nil {
+			value = ""
+		}
+		if err := f.set(fmt.Sprint(value)); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %s: %w", path, f.key, err))
+		}
+	}
+
+	unknown := make([]string, 0, len(flat))
+	for key := range flat {
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Errorf("%s: unknown setting %s", path, key))
+	}
+	return problems
+}
+
+func flatten(values map[string]any, prefix string, out map[string]any) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]any); ok {
+			flatten(nested, prefix+key+".", out)
+			continue
+		}
+		out[prefix+key] = value
+	}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/requestctx"
+	"promo-api/services"
+)
+
+type AuditController struct {
+	Service services.AuditServiceInterface
+}
+
+func (c *AuditController) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if !requestctx.IsAdmin(r.Context()) {
+		http.Error(w, "Audit events require the admin API key", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	limitStr := query.Get("limit")
+	offsetStr := query.Get("offset")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := models.AuditFilter{
+		Actor:        query.Get("actor"),
+		Action:       query.Get("action"),
+		ResourceType: query.Get("resource_type"),
+	}
+	if idStr := query.Get("resource_id"); idStr != "" {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			http.Error(w, "Invalid resource_id format", http.StatusBadRequest)
+			return
+		}
+		filter.ResourceID = &id
+	}
+	if filter.From, err = parseTimeParam(r, "from"); err != nil {
+		http.Error(w, "Invalid from format, expected RFC 3339", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(r, "to"); err != nil {
+		http.Error(w, "Invalid to format, expected RFC 3339", http.StatusBadRequest)
+		return
+	}
+
+	events, err := c.Service.GetAuditEvents(r.Context(), filter, limit, offset)
+	if err != nil {
+		serverError(w, r, err, "Failed to get audit events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
+
+// parseTimeParam reads an optional RFC 3339 query parameter.
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -25,6 +27,10 @@ func (c *CompanyController) CreateCompany(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := c.Service.CreateCompany(r.Context(), &company); err != nil {
+		if errors.Is(err, services.ErrDuplicate) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -35,7 +41,8 @@ func (c *CompanyController) CreateCompany(w http.ResponseWriter, r *http.Request
 }
 
 func (c *CompanyController) GetCompany(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
+	vars := mux.Vars(r)
+	idStr := vars["id"]
 	id, err := uuid.Parse(idStr)
 	if err != nil {
 		http.Error(w, "Invalid ID format", http.StatusBadRequest)
@@ -48,6 +55,12 @@ func (c *CompanyController) GetCompany(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tag := etag(company.Version)
+	if notModified(w, r, tag) {
+		return
+	}
+
+	w.Header().Set("ETag", tag)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(company)
@@ -69,7 +82,7 @@ func (c *CompanyController) GetAllCompanies(w http.ResponseWriter, r *http.Reque
 
 	companies, err := c.Service.GetAllCompanies(r.Context(), limit, offset)
 	if err != nil {
-		http.Error(w, "Failed to get companies", http.StatusInternalServerError)
+		serverError(w, r, err, "Failed to get companies")
 		return
 	}
 
@@ -79,18 +92,87 @@ func (c *CompanyController) GetAllCompanies(w http.ResponseWriter, r *http.Reque
 }
 
 func (c *CompanyController) UpdateCompany(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var company models.Company
 
 	if err := json.NewDecoder(r.Body).Decode(&company); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	company.ID = id
+	company.Version = version
 
 	if err := c.Service.UpdateCompany(r.Context(), &company); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Company not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrVersionConflict):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, services.ErrDuplicate):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(company.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(company)
+}
+
+// maxPatchSize bounds merge patch bodies read into memory.
+const maxPatchSize = 1 << 20
+
+func (c *CompanyController) PatchCompany(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	company, err := c.Service.PatchCompany(r.Context(), id, patch, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Company not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrVersionConflict):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, services.ErrDuplicate):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
+	w.Header().Set("ETag", etag(company.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(company)
@@ -106,7 +188,11 @@ func (c *CompanyController) DeactivateCompany(w http.ResponseWriter, r *http.Req
 	}
 
 	if err := c.Service.DeactivateCompany(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, services.ErrNotFound) {
+			http.Error(w, "Company not found", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err, err.Error())
 		return
 	}
 
@@ -124,7 +210,14 @@ func (c *CompanyController) RotateAPIKey(w http.ResponseWriter, r *http.Request)
 
 	newKey, err := c.Service.RotateAPIKey(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Company not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrForbidden):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			serverError(w, r, err, err.Error())
+		}
 		return
 	}
 
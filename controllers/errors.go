@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// serverError logs err, which the client never sees in full, with the
+// request's context so the line carries its request ID, and answers 500
+// with message.
+func serverError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	slog.ErrorContext(r.Context(), "Request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
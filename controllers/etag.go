@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag formats a row version as a strong entity tag.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion reads the If-Match precondition that updates require and
+// returns the version it names, or 0 for "*". The header may list several
+// tags, but a row has one current version, so at most one of them can be a
+// strong tag; weak tags never match. It writes the error response and
+// returns ok=false when the header is missing, lists more than one strong
+// tag or cannot match.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (version int, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		http.Error(w, "If-Match header required", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	var strong []string
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		switch {
+		case candidate == "*":
+			http.Error(w, `If-Match "*" cannot be combined with entity tags`, http.StatusBadRequest)
+			return 0, false
+		case candidate == "", strings.HasPrefix(candidate, "W/"):
+		default:
+			strong = append(strong, candidate)
+		}
+	}
+	if len(strong) > 1 {
+		http.Error(w, "If-Match may list only one strong entity tag", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if len(strong) == 1 {
+		if unquoted, err := strconv.Unquote(strong[0]); err == nil {
+			version, _ = strconv.Atoi(unquoted)
+		}
+	}
+	if version < 1 {
+		http.Error(w, "If-Match does not match the current version", http.StatusPreconditionFailed)
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified answers a conditional GET with 304 when If-None-Match lists the
+// current entity tag, reporting whether it did.
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == tag || candidate == "*" {
+			w.Header().Set("ETag", tag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// HealthCheck is one component of readiness. Check returns nil when the
+// component can serve traffic.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// ComponentStatus is the state of one component in a readiness report.
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthReport is the body of /healthz and /readyz.
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// HealthController serves the unauthenticated probes used by orchestrators.
+type HealthController struct {
+	Checks []HealthCheck
+	// Timeout bounds each check so a hung dependency fails the probe
+	// instead of stalling it.
+	Timeout time.Duration
+
+	shuttingDown atomic.Bool
+}
+
+// ShutDown makes readiness fail from now on, so load balancers stop routing
+// new requests while in-flight ones drain.
+func (c *HealthController) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Healthz reports that the process is alive. It stays 200 during shutdown:
+// restarting a draining process would cut its in-flight requests short.
+func (c *HealthController) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, HealthReport{Status: "ok"})
+}
+
+// Readyz runs every check and reports each component. It answers 503 when
+// any check fails or the server is shutting down.
+func (c *HealthController) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := HealthReport{Status: "ok", Components: map[string]ComponentStatus{}}
+	status := http.StatusOK
+
+	for _, check := range c.Checks {
+		ctx, cancel := context.WithTimeout(r.Context(), c.Timeout)
+		err := check.Check(ctx)
+		cancel()
+
+		if err != nil {
+			report.Components[check.Name] = ComponentStatus{Status: "unavailable", Error: err.Error()}
+			report.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		report.Components[check.Name] = ComponentStatus{Status: "ok"}
+	}
+
+	if c.shuttingDown.Load() {
+		report.Status = "shutting_down"
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, report)
+}
+
+func writeHealth(w http.ResponseWriter, status int, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
 	"promo-api/models"
+	"promo-api/requestctx"
 	"promo-api/services"
 )
 
@@ -34,20 +39,81 @@ func (c *PromotionController) CreatePromotion(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(promotion)
 }
 
+// includeDeleted reads the admin-only include_deleted query parameter. It
+// writes the error response and returns ok=false when the caller may not
+// use it.
+func includeDeleted(w http.ResponseWriter, r *http.Request) (include bool, ok bool) {
+	includeStr := r.URL.Query().Get("include_deleted")
+	if includeStr == "" {
+		return false, true
+	}
+
+	include, err := strconv.ParseBool(includeStr)
+	if err != nil {
+		http.Error(w, "Invalid include_deleted value", http.StatusBadRequest)
+		return false, false
+	}
+	if include && !requestctx.IsAdmin(r.Context()) {
+		http.Error(w, "include_deleted requires the admin API key", http.StatusForbidden)
+		return false, false
+	}
+	return include, true
+}
+
 func (c *PromotionController) GetPromotion(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
+	vars := mux.Vars(r)
+	idStr := vars["id"]
 	id, err := uuid.Parse(idStr)
 	if err != nil {
 		http.Error(w, "Invalid ID format", http.StatusBadRequest)
 		return
 	}
 
-	promotion, err := c.Service.GetPromotion(r.Context(), id)
+	if asOfStr := r.URL.Query().Get("as_of"); asOfStr != "" {
+		asOf, err := time.Parse(time.RFC3339, asOfStr)
+		if err != nil {
+			http.Error(w, "Invalid as_of format, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		c.getPromotionAsOf(w, r, id, asOf)
+		return
+	}
+
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	promotion, err := c.Service.GetPromotion(r.Context(), id, withDeleted)
 	if err != nil {
 		http.Error(w, "Promotion not found", http.StatusNotFound)
 		return
 	}
 
+	tag := etag(promotion.Version)
+	if notModified(w, r, tag) {
+		return
+	}
+
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(promotion)
+}
+
+// getPromotionAsOf writes the promotion as it was stored at the given instant.
+// Historical states carry no ETag because they cannot be written back.
+func (c *PromotionController) getPromotionAsOf(w http.ResponseWriter, r *http.Request, id uuid.UUID, asOf time.Time) {
+	promotion, err := c.Service.GetPromotionAsOf(r.Context(), id, asOf)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "Promotion did not exist at the given time", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, r, err, "Failed to get promotion")
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(promotion)
@@ -67,9 +133,39 @@ func (c *PromotionController) GetAllPromotions(w http.ResponseWriter, r *http.Re
 		offset = 0
 	}
 
-	promotions, err := c.Service.GetAllPromotions(r.Context(), limit, offset)
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	activeAtStr := r.URL.Query().Get("active_at")
+	status := r.URL.Query().Get("status")
+	if activeAtStr != "" && status != "" {
+		http.Error(w, "active_at and status cannot be combined", http.StatusBadRequest)
+		return
+	}
+
+	var promotions []models.Promotion
+	switch {
+	case activeAtStr != "":
+		var activeAt time.Time
+		activeAt, err = time.Parse(time.RFC3339, activeAtStr)
+		if err != nil {
+			http.Error(w, "Invalid active_at format, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		promotions, err = c.Service.GetPromotionsActiveAt(r.Context(), activeAt, limit, offset)
+	case status != "":
+		promotions, err = c.Service.GetPromotionsByStatus(r.Context(), status, limit, offset)
+	default:
+		promotions, err = c.Service.GetAllPromotions(r.Context(), withDeleted, limit, offset)
+	}
+	if errors.Is(err, services.ErrInvalidStatus) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to get promotions", http.StatusInternalServerError)
+		serverError(w, r, err, "Failed to get promotions")
 		return
 	}
 
@@ -88,7 +184,7 @@ func (c *PromotionController) GetPromotionsByCoupon(w http.ResponseWriter, r *ht
 
 	promotions, err := c.Service.GetPromotionsByCoupon(r.Context(), coupon)
 	if err != nil {
-		http.Error(w, "Failed to get promotions by coupon", http.StatusInternalServerError)
+		serverError(w, r, err, "Failed to get promotions by coupon")
 		return
 	}
 
@@ -98,18 +194,84 @@ func (c *PromotionController) GetPromotionsByCoupon(w http.ResponseWriter, r *ht
 }
 
 func (c *PromotionController) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var promotion models.Promotion
 
 	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	promotion.ID = id
+	promotion.Version = version
 
 	if err := c.Service.UpdatePromotion(r.Context(), &promotion); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Promotion not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrVersionConflict):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, services.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(promotion.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(promotion)
+}
+
+func (c *PromotionController) PatchPromotion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	promotion, err := c.Service.PatchPromotion(r.Context(), id, patch, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Promotion not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrVersionConflict):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, services.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
+	w.Header().Set("ETag", etag(promotion.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(promotion)
@@ -125,9 +287,189 @@ func (c *PromotionController) DeletePromotion(w http.ResponseWriter, r *http.Req
 	}
 
 	if err := c.Service.DeletePromotion(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, services.ErrNotFound) {
+			http.Error(w, "Promotion not found", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err, err.Error())
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (c *PromotionController) RestorePromotion(w http.ResponseWriter, r *http.Request) {
+	c.transitionPromotion(w, r, c.Service.RestorePromotion)
+}
+
+func (c *PromotionController) PausePromotion(w http.ResponseWriter, r *http.Request) {
+	c.transitionPromotion(w, r, c.Service.PausePromotion)
+}
+
+func (c *PromotionController) ResumePromotion(w http.ResponseWriter, r *http.Request) {
+	c.transitionPromotion(w, r, c.Service.ResumePromotion)
+}
+
+func (c *PromotionController) ArchivePromotion(w http.ResponseWriter, r *http.Request) {
+	c.transitionPromotion(w, r, c.Service.ArchivePromotion)
+}
+
+func (c *PromotionController) transitionPromotion(w http.ResponseWriter, r *http.Request, transition func(ctx context.Context, id uuid.UUID) (*models.Promotion, error)) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	promotion, err := transition(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Promotion not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, err, err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(promotion.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(promotion)
+}
+
+func (c *PromotionController) GetPromotionTransitions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	transitions, err := c.Service.GetPromotionTransitions(r.Context(), id)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "Promotion not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, r, err, "Failed to get promotion transitions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(transitions)
+}
+
+func (c *PromotionController) GetPromotionRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := c.Service.GetPromotionRevisions(r.Context(), id)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "Promotion not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, r, err, "Failed to get promotion revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func (c *PromotionController) RollbackPromotion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil || revision < 1 {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	promotion, err := c.Service.RollbackPromotion(r.Context(), id, revision, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Promotion or revision not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrVersionConflict):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, services.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(promotion.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(promotion)
+}
+
+// redeemRequest is the body of POST /promotions/{id}:redeem.
+type redeemRequest struct {
+	PurchaseAmount float64 `json:"purchase_amount"`
+}
+
+func (c *PromotionController) RedeemPromotion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req redeemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if req.PurchaseAmount <= 0 {
+		http.Error(w, "purchase_amount must be greater than zero", http.StatusBadRequest)
+		return
+	}
+
+	redemption, err := c.Service.RedeemPromotion(r.Context(), id, req.PurchaseAmount)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			http.Error(w, "Promotion not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrPromotionNotActive), errors.Is(err, services.ErrPromotionExhausted):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, services.ErrMinimumPurchase):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			serverError(w, r, err, "Failed to redeem promotion")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(redemption)
+}
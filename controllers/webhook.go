@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"promo-api/models"
+	"promo-api/services"
+)
+
+var deliveryStatuses = map[string]bool{
+	"":                              true,
+	models.WebhookDeliveryPending:   true,
+	models.WebhookDeliverySucceeded: true,
+	models.WebhookDeliveryDead:      true,
+}
+
+type WebhookController struct {
+	Service services.WebhookServiceInterface
+}
+
+// webhookError writes the response for errors returned by the webhook
+// service.
+func webhookError(w http.ResponseWriter, r *http.Request, err error, fallback int) {
+	switch {
+	case errors.Is(err, services.ErrCompanyRequired):
+		http.Error(w, "Webhooks require a company API key", http.StatusForbidden)
+	case errors.Is(err, services.ErrNotFound):
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+	case fallback == http.StatusInternalServerError:
+		serverError(w, r, err, err.Error())
+	default:
+		http.Error(w, err.Error(), fallback)
+	}
+}
+
+// pagination reads the limit and offset query parameters.
+func pagination(r *http.Request) (limit, offset int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	offset, err = strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func (c *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhook models.Webhook
+
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.Service.CreateWebhook(r.Context(), &webhook); err != nil {
+		webhookError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (c *WebhookController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := c.Service.GetWebhooks(r.Context())
+	if err != nil {
+		webhookError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+func (c *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := c.Service.GetWebhook(r.Context(), id)
+	if err != nil {
+		webhookError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (c *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.Service.DeleteWebhook(r.Context(), id); err != nil {
+		webhookError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if !deliveryStatuses[status] {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	limit, offset := pagination(r)
+
+	deliveries, err := c.Service.GetDeliveries(r.Context(), id, status, limit, offset)
+	if err != nil {
+		webhookError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// GetDeadDeliveries is the dead-letter view: deliveries that exhausted their
+// retries across all of the company's webhooks.
+func (c *WebhookController) GetDeadDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit, offset := pagination(r)
+
+	deliveries, err := c.Service.GetDeadDeliveries(r.Context(), limit, offset)
+	if err != nil {
+		webhookError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (c *WebhookController) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := uuid.Parse(vars["delivery_id"])
+	if err != nil {
+		http.Error(w, "Invalid delivery ID format", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := c.Service.Redeliver(r.Context(), id, deliveryID)
+	if err != nil {
+		webhookError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
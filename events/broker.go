@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"promo-api/models"
+)
+
+// WriterPublisher writes each event as one JSON line, e.g. to os.Stdout for a
+// log shipper to pick up.
+type WriterPublisher struct {
+	mu     sync.Mutex
+	Writer io.Writer
+}
+
+var _ Publisher = &WriterPublisher{}
+
+func (p *WriterPublisher) Publish(ctx context.Context, event models.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.Writer.Write(append(line, '\n'))
+	return err
+}
+
+// Message is a record for a message broker. Key is the aggregate ID, so
+// brokers that partition by key (Kafka partitions, NATS JetStream subjects
+// with per-subject ordering) keep the events of one aggregate in order.
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// Producer sends messages to a broker. Implementations wrap a Kafka or NATS
+// client and must not return before the broker has acknowledged the message.
+type Producer interface {
+	Produce(ctx context.Context, message Message) error
+}
+
+// BrokerPublisher publishes events through a Producer, one topic per
+// aggregate type: TopicPrefix + aggregate type, e.g. "promo.promotion".
+type BrokerPublisher struct {
+	Producer    Producer
+	TopicPrefix string
+}
+
+var _ Publisher = &BrokerPublisher{}
+
+func (p *BrokerPublisher) Publish(ctx context.Context, event models.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+	return p.Producer.Produce(ctx, Message{
+		Topic: p.TopicPrefix + event.AggregateType,
+		Key:   []byte(event.AggregateID.String()),
+		Value: value,
+		Headers: map[string]string{
+			"event-id":   event.ID.String(),
+			"event-type": event.Type,
+		},
+	})
+}
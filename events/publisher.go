@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"log"
+
+	"promo-api/models"
+)
+
+// Publisher delivers domain events emitted by the service layer.
+type Publisher interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+// LogPublisher writes events to the standard logger.
+type LogPublisher struct{}
+
+var _ Publisher = LogPublisher{}
+
+func (LogPublisher) Publish(ctx context.Context, event models.Event) error {
+	log.Printf("event %s %s %s/%s", event.ID, event.Type, event.AggregateType, event.AggregateID)
+	return nil
+}
+
+// NopPublisher discards events.
+type NopPublisher struct{}
+
+var _ Publisher = NopPublisher{}
+
+func (NopPublisher) Publish(ctx context.Context, event models.Event) error {
+	return nil
+}
+
+// MultiPublisher publishes every event to each of its publishers in order,
+// stopping at the first error.
+type MultiPublisher []Publisher
+
+var _ Publisher = MultiPublisher{}
+
+func (m MultiPublisher) Publish(ctx context.Context, event models.Event) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,40 +1,118 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
-
-	"github.com/gorilla/mux"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
+	"promo-api/app"
 	"promo-api/config"
-	"promo-api/controllers"
-	"promo-api/middlewares"
-	"promo-api/repositories"
-	"promo-api/routes"
-	"promo-api/services"
+	"promo-api/logging"
+	"promo-api/server"
+	"promo-api/tracing"
 )
 
+const usage = `Usage: promo-api [-config file] [command]
+
+Commands:
+  serve          serve the API (the default)
+  config print   print the effective configuration with secrets redacted
+
+The config file may be YAML or TOML; CONFIG_FILE is used when -config is
+not given. Environment variables and .env override the file.
+`
+
 func main() {
-	db := config.GetDB()
-	defer config.CloseDB()
+	configFile := flag.String("config", "", "path to a YAML or TOML config file")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+
+	var err error
+	switch args := flag.Args(); {
+	case len(args) == 0, len(args) == 1 && args[0] == "serve":
+		err = serve(*configFile)
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		err = printConfig(*configFile)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Printf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+func printConfig(configFile string) error {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+	return cfg.Print(os.Stdout)
+}
+
+// serve runs the API until SIGINT or SIGTERM. Shutdown happens in reverse
+// order of startup: the server drains in-flight requests, the workers stop,
+// and storage closes last. Errors are returned rather than logged fatally so
+// the deferred cleanup always runs.
+func serve(configFile string) error {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+
+	// The default logger also takes over the standard log package, so every
+	// line comes out in the same format.
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
 
-	companyRepo := &repositories.CompanyRepository{DB: db}
-	companyService := &services.CompanyService{Repo: companyRepo}
-	companyController := &controllers.CompanyController{Service: companyService}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}()
 
-	promoRepo := &repositories.PromotionRepository{DB: db}
-	promoService := &services.PromotionService{Repo: promoRepo}
-	promoController := &controllers.PromotionController{Service: promoService}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	r := mux.NewRouter()
-	r.Use(middlewares.ValidateContentType)
+	store, err := app.OpenStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Close()
 
-	authorized := r.PathPrefix("/").Subrouter()
-	authorized.Use(middlewares.ValidateAPIKey(companyRepo))
+	opts := app.OptionsFromConfig(cfg)
+	opts.Logger = logger
+	application, err := app.New(store, opts)
+	if err != nil {
+		return fmt.Errorf("failed to build the application: %w", err)
+	}
 
-	routes.ConfigurePromotionRoutes(authorized, promoController)
-	routes.ConfigureCompanyRoutes(authorized, companyController)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	application.StartWorkers(workerCtx)
+	defer func() {
+		stopWorkers()
+		application.WaitWorkers()
+		log.Println("Background workers stopped")
+	}()
 
-	log.Println("Server running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	serverOpts := server.OptionsFromConfig(cfg.HTTP)
+	serverOpts.OnShutdown = application.Health.ShutDown
+	return server.New(application.Handler, serverOpts).ListenAndServe(ctx)
 }
@@ -0,0 +1,157 @@
+// Package metrics exposes Prometheus metrics for the HTTP API, the database
+// pools and business events.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"promo-api/utils"
+)
+
+// UnmatchedRoute labels requests that matched no route, so probing random
+// paths cannot create unbounded series.
+const UnmatchedRoute = "unmatched"
+
+// Metrics holds the collectors of one application. Each has its own
+// registry, so several applications can run in one process, as in tests.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests          *prometheus.CounterVec
+	duration          *prometheus.HistogramVec
+	authFailures      *prometheus.CounterVec
+	rateLimited       *prometheus.CounterVec
+	promotionsCreated *prometheus.CounterVec
+	redemptions       *prometheus.CounterVec
+	rejections        *prometheus.CounterVec
+	discountGranted   *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route template and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_failures_total",
+			Help: "Rejected API keys by reason.",
+		}, []string{"reason"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rate_limited_requests_total",
+			Help: "Requests rejected by a rate limit, by its scope: ip or company.",
+		}, []string{"scope"}),
+		promotionsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "promotions_created_total",
+			Help: "Promotions created, by the company that created them.",
+		}, []string{"company"}),
+		redemptions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "promotion_redemptions_total",
+			Help: "Successful promotion redemptions, by the company that redeemed them.",
+		}, []string{"company"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "promotion_redemptions_rejected_total",
+			Help: "Refused promotion redemptions, by company and reason: not_found, not_active, exhausted or minimum_purchase.",
+		}, []string{"company", "reason"}),
+		discountGranted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "promotion_discount_amount_total",
+			Help: "Discount granted by successful redemptions, in the currency of the purchases, by company.",
+		}, []string{"company"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.authFailures,
+		m.rateLimited,
+		m.promotionsCreated,
+		m.redemptions,
+		m.rejections,
+		m.discountGranted,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled with
+// name, such as "primary".
+func (m *Metrics) RegisterDB(name string, db *sqlx.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db.DB, name))
+}
+
+// Instrument is a mux middleware counting requests and their latency by
+// route template, such as /promotions/{id}, rather than by path.
+func (m *Metrics) Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := UnmatchedRoute
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		m.observe(route, next, w, r)
+	})
+}
+
+// InstrumentUnmatched wraps the handler for requests that matched no route.
+func (m *Metrics) InstrumentUnmatched(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.observe(UnmatchedRoute, next, w, r)
+	})
+}
+
+func (m *Metrics) observe(route string, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := utils.NewResponseRecorder(w)
+	next.ServeHTTP(recorder, r)
+
+	status := strconv.Itoa(recorder.Status)
+	m.requests.WithLabelValues(r.Method, route, status).Inc()
+	m.duration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+}
+
+// AuthFailed counts an API key rejected for reason.
+func (m *Metrics) AuthFailed(reason string) {
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+// RateLimited counts a request rejected by the rate limit of scope.
+func (m *Metrics) RateLimited(scope string) {
+	m.rateLimited.WithLabelValues(scope).Inc()
+}
+
+// PromotionCreated counts a promotion created by company.
+func (m *Metrics) PromotionCreated(company string) {
+	m.promotionsCreated.WithLabelValues(company).Inc()
+}
+
+// PromotionRedeemed counts a redemption by company and the discount it
+// granted.
+func (m *Metrics) PromotionRedeemed(company string, discount float64) {
+	m.redemptions.WithLabelValues(company).Inc()
+	m.discountGranted.WithLabelValues(company).Add(discount)
+}
+
+// RedemptionRejected counts a redemption by company refused for reason.
+func (m *Metrics) RedemptionRejected(company, reason string) {
+	m.rejections.WithLabelValues(company, reason).Inc()
+}
@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"promo-api/utils"
+)
+
+const accessLogContextKey = contextKey("access_log")
+
+// accessLogEntry collects what inner middlewares learn about a request for
+// its access log line.
+type accessLogEntry struct {
+	companyID string
+}
+
+// setLogCompany records the authenticated company in the access log entry
+// of ctx, if there is one.
+func setLogCompany(ctx context.Context, companyID string) {
+	if entry, ok := ctx.Value(accessLogContextKey).(*accessLogEntry); ok {
+		entry.companyID = companyID
+	}
+}
+
+// AccessLog writes one line to logger per request once it is served: the
+// method, path, route template, status, response size, latency and, when
+// authenticated, the company. It must run after CaptureRequestInfo so the
+// line carries the request ID.
+func AccessLog(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &accessLogEntry{}
+			recorder := utils.NewResponseRecorder(w)
+			ctx := context.WithValue(r.Context(), accessLogContextKey, entry)
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			}
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					attrs = append(attrs, slog.String("route", template))
+				}
+			}
+			attrs = append(attrs,
+				slog.Int("status", recorder.Status),
+				slog.Int64("bytes", recorder.Bytes),
+				slog.Duration("latency", time.Since(start)),
+			)
+			if entry.companyID != "" {
+				attrs = append(attrs, slog.String("company_id", entry.companyID))
+			}
+
+			level := slog.LevelInfo
+			if recorder.Status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(ctx, level, "request", attrs...)
+		})
+	}
+}
@@ -1,33 +1,75 @@
 package middlewares
 
 import (
-	"context"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
 
 	"promo-api/repositories"
+	"promo-api/requestctx"
 )
 
 type contextKey string
 
-const CompanyContextKey = contextKey("company")
+func apiKeyHint(apiKey string) string {
+	if len(apiKey) <= 4 {
+		return "..."
+	}
+	return "..." + apiKey[len(apiKey)-4:]
+}
+
+// Reasons passed to the onFailure callback of ValidateAPIKey.
+const (
+	AuthFailureMissingKey      = "missing_key"
+	AuthFailureUnknownKey      = "unknown_key"
+	AuthFailureInactiveCompany = "inactive_company"
+	AuthFailureLookupError     = "lookup_error"
+)
+
+// ValidateAPIKey authenticates requests by their X-API-Key header, either as
+// a company or, when adminAPIKey is set and matches, as an administrator.
+// onFailure, when not nil, is told why each rejected request failed.
+func ValidateAPIKey(repo repositories.CompanyRepositoryInterface, adminAPIKey string, onFailure func(reason string)) func(next http.Handler) http.Handler {
+	fail := func(reason string) {
+		if onFailure != nil {
+			onFailure(reason)
+		}
+	}
 
-func ValidateAPIKey(repo repositories.CompanyRepositoryInterface) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			apiKey := r.Header.Get("X-API-Key")
 			if strings.TrimSpace(apiKey) == "" {
+				fail(AuthFailureMissingKey)
 				http.Error(w, "API key required", http.StatusUnauthorized)
 				return
 			}
 
+			ctx := requestctx.WithAPIKeyHint(r.Context(), apiKeyHint(apiKey))
+
+			if adminAPIKey != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(adminAPIKey)) == 1 {
+				ctx = requestctx.WithAdmin(ctx)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			company, err := repo.FindByAPIKey(r.Context(), apiKey)
+			switch {
+			case errors.Is(err, repositories.ErrNotFound) || (err == nil && company == nil):
+				fail(AuthFailureUnknownKey)
+			case err != nil:
+				fail(AuthFailureLookupError)
+			case !company.IsActive:
+				fail(AuthFailureInactiveCompany)
+			}
 			if err != nil || company == nil || !company.IsActive {
 				http.Error(w, "Invalid or inactive API key", http.StatusUnauthorized)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), CompanyContextKey, company)
+			ctx = requestctx.WithCompany(ctx, company)
+			setLogCompany(ctx, company.ID.String())
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
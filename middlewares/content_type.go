@@ -6,12 +6,21 @@ import (
 
 func ValidateContentType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost || r.Method == http.MethodPut {
-			if r.Header.Get("Content-Type") != "application/json" {
+		contentType := r.Header.Get("Content-Type")
+
+		// Bodiless actions such as POST /promotions/{id}:pause need no type.
+		if (r.Method == http.MethodPost || r.Method == http.MethodPut) && r.ContentLength != 0 {
+			if contentType != "application/json" {
 				http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
 				return
 			}
 		}
+		if r.Method == http.MethodPatch {
+			if contentType != "application/merge-patch+json" && contentType != "application/json" {
+				http.Error(w, "Content-Type must be application/merge-patch+json", http.StatusBadRequest)
+				return
+			}
+		}
 		next.ServeHTTP(w, r)
 	})
 }
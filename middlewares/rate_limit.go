@@ -0,0 +1,96 @@
+package middlewares
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"promo-api/ratelimit"
+	"promo-api/requestctx"
+)
+
+// Scopes passed to the onLimited callback of the rate limiters.
+const (
+	RateLimitScopeIP      = "ip"
+	RateLimitScopeCompany = "company"
+)
+
+// RateLimitByIP limits requests per client IP. It runs before
+// ValidateAPIKey, so clients cycling through bad keys cannot make it look
+// every one of them up. It must run after CaptureRequestInfo.
+func RateLimitByIP(store ratelimit.Store, limit ratelimit.Limit, onLimited func(scope string)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !limit.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info, _ := requestctx.Request(r.Context())
+			if !rateLimit(w, r, store, "ip:"+info.IP, limit, RateLimitScopeIP, onLimited) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitByCompany limits the requests of each company to the limit on its
+// record or, where it has none, to defaults. Administrators are not limited.
+// It must run after ValidateAPIKey.
+func RateLimitByCompany(store ratelimit.Store, defaults ratelimit.Limit, onLimited func(scope string)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			company, ok := requestctx.Company(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit := defaults
+			if company.RateLimit != nil {
+				limit.Rate = *company.RateLimit
+			}
+			if company.RateLimitBurst != nil {
+				limit.Burst = *company.RateLimitBurst
+			}
+			if limit.Enabled() && !rateLimit(w, r, store, "company:"+company.ID.String(), limit, RateLimitScopeCompany, onLimited) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimit takes a token for key and sets the RateLimit headers. When none
+// is left it answers 429 with Retry-After and returns false. A failing store
+// lets the request through: an outage of the limiter must not become an
+// outage of the API.
+func rateLimit(w http.ResponseWriter, r *http.Request, store ratelimit.Store, key string, limit ratelimit.Limit, scope string, onLimited func(scope string)) bool {
+	result, err := store.Take(r.Context(), key, limit)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Rate limiter unavailable, allowing request", "scope", scope, "error", err)
+		return true
+	}
+
+	w.Header().Set("RateLimit-Policy", limit.Policy())
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+	if result.Allowed {
+		return true
+	}
+
+	if onLimited != nil {
+		onLimited(scope)
+	}
+	w.Header().Set("Retry-After", seconds(result.RetryAfter))
+	http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
+
+// seconds formats d in whole seconds, rounding up so clients that wait that
+// long are not turned away again.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
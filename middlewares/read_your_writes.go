@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"promo-api/repositories"
+	"promo-api/requestctx"
+)
+
+// ReadYourWrites keeps reads on the primary database when a request must see
+// its client's writes despite replication lag: for the whole of every write
+// request, and for window after the same company, or the administrator,
+// last wrote. It must run after ValidateAPIKey.
+func ReadYourWrites(window time.Duration) func(next http.Handler) http.Handler {
+	var (
+		mu         sync.Mutex
+		lastWrites = map[string]time.Time{}
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := clientKey(r.Context())
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				mu.Lock()
+				wrote, ok := lastWrites[client]
+				mu.Unlock()
+				if ok && time.Since(wrote) < window {
+					r = r.WithContext(repositories.WithPrimary(r.Context()))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(repositories.WithPrimary(r.Context())))
+
+			now := time.Now()
+			mu.Lock()
+			defer mu.Unlock()
+			lastWrites[client] = now
+			for key, wrote := range lastWrites {
+				if now.Sub(wrote) >= window {
+					delete(lastWrites, key)
+				}
+			}
+		})
+	}
+}
+
+func clientKey(ctx context.Context) string {
+	if company, ok := requestctx.Company(ctx); ok {
+		return company.ID.String()
+	}
+	return "admin"
+}
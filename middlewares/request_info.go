@@ -0,0 +1,133 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"promo-api/logging"
+	"promo-api/requestctx"
+)
+
+// maxRequestIDLength bounds the X-Request-ID values accepted from clients.
+const maxRequestIDLength = 128
+
+// CaptureRequestInfo stores the request ID and client IP in the request
+// context. The request ID comes from the X-Request-ID header, so a caller's
+// ID follows the request across services; when it is missing or invalid a
+// new one is generated. Either way it is echoed in the response. The client
+// IP is the peer address unless that is one of trustedProxies; see clientIP.
+func CaptureRequestInfo(trustedProxies []netip.Prefix) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-ID")
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set("X-Request-ID", id)
+
+			info := requestctx.RequestInfo{ID: id, IP: clientIP(r, trustedProxies)}
+			ctx := requestctx.WithRequestInfo(r.Context(), info)
+			ctx = logging.WithRequestID(ctx, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// clientIP returns the address of the client behind r. Forwarding headers
+// are only believed when the peer is a trusted proxy, or any client could
+// pick the IP it is rate limited and audited as. X-Forwarded-For is then
+// walked from the right, past the trusted proxies that appended to it, to
+// the first hop none of them is; Forwarded is used when it is missing. A
+// malformed hop ends the walk at the last trusted address.
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !trusted(peer, trustedProxies) {
+		return host
+	}
+
+	hops := forwardedFor(r.Header)
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = hop
+		if !trusted(hop, trustedProxies) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func trusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor lists the hops recorded by X-Forwarded-For or, without it,
+// by the for parameters of Forwarded, client first.
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	if values := header.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, value := range values {
+			for _, hop := range strings.Split(value, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		return hops
+	}
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop parses one hop, with or without a port, as both headers write
+// them: "192.0.2.1", "192.0.2.1:80", "2001:db8::1" or "[2001:db8::1]:80".
+func parseHop(hop string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so a client
+// cannot forge log lines or flood them with a huge header.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS companies (
+	id UUID PRIMARY KEY,
+	name TEXT NOT NULL,
+	cnpj TEXT NOT NULL,
+	api_key TEXT NOT NULL,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS companies_api_key_idx ON companies (api_key);
+
+CREATE TABLE IF NOT EXISTS promotions (
+	id UUID PRIMARY KEY,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	discount_type TEXT NOT NULL,
+	discount_value NUMERIC NOT NULL,
+	start_date TIMESTAMPTZ NOT NULL,
+	end_date TIMESTAMPTZ NOT NULL,
+	minimum_purchase_amount NUMERIC,
+	max_usage INTEGER,
+	current_usage INTEGER NOT NULL DEFAULT 0,
+	coupon_code TEXT,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,6 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS schedule JSONB;
+
+CREATE INDEX IF NOT EXISTS promotions_active_window_idx
+	ON promotions (start_date, end_date) WHERE is_active;
@@ -0,0 +1,13 @@
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'draft';
+
+UPDATE promotions SET status = CASE
+	WHEN NOT is_active THEN 'paused'
+	WHEN end_date < now() THEN 'expired'
+	WHEN max_usage IS NOT NULL AND current_usage >= max_usage THEN 'exhausted'
+	WHEN start_date > now() THEN 'scheduled'
+	ELSE 'active'
+END;
+
+UPDATE promotions SET is_active = (status = 'active');
+
+CREATE INDEX IF NOT EXISTS promotions_status_idx ON promotions (status);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS promotion_transitions (
+	id UUID PRIMARY KEY,
+	promotion_id UUID NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+	action TEXT NOT NULL,
+	from_status TEXT NOT NULL,
+	to_status TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS promotion_transitions_promotion_idx
+	ON promotion_transitions (promotion_id, created_at);
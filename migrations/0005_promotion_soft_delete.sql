@@ -0,0 +1,4 @@
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS promotions_deleted_at_idx
+	ON promotions (deleted_at) WHERE deleted_at IS NOT NULL;
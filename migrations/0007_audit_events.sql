@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+	id UUID PRIMARY KEY,
+	actor TEXT NOT NULL,
+	api_key_hint TEXT,
+	action TEXT NOT NULL,
+	resource_type TEXT NOT NULL,
+	resource_id UUID NOT NULL,
+	changes JSONB NOT NULL,
+	request_id TEXT,
+	ip TEXT,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_resource_idx
+	ON audit_events (resource_type, resource_id, created_at);
+
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events (created_at);
+
+-- The audit log is append-only.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
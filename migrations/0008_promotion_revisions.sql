@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS promotion_revisions (
+	id UUID PRIMARY KEY,
+	promotion_id UUID NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	snapshot JSONB NOT NULL,
+	actor TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (promotion_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS promotion_revisions_as_of_idx
+	ON promotion_revisions (promotion_id, created_at);
+
+-- Existing promotions start their history with their current state.
+INSERT INTO promotion_revisions (id, promotion_id, revision, snapshot, actor, created_at)
+SELECT gen_random_uuid(), p.id, 1, to_jsonb(p) - 'version', 'system', p.updated_at
+FROM promotions p
+ON CONFLICT DO NOTHING;
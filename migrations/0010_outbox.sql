@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS outbox (
+	position BIGSERIAL PRIMARY KEY,
+	event_id UUID NOT NULL UNIQUE,
+	type TEXT NOT NULL,
+	aggregate_type TEXT NOT NULL,
+	aggregate_id UUID NOT NULL,
+	data JSONB NOT NULL,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	published_at TIMESTAMPTZ,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (position) WHERE published_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,36 @@
+-- Only one live company may hold a CNPJ; deactivated companies keep theirs
+-- for history.
+--
+-- Databases that already hold duplicates cannot take the index. Rather than
+-- fail on the first pair with a bare unique violation, list every CNPJ held
+-- by more than one live company, with their ids, oldest first. The same
+-- query finds them ahead of an upgrade:
+--
+--	SELECT cnpj, array_agg(id ORDER BY created_at, id) AS company_ids
+--	FROM companies WHERE deleted_at IS NULL
+--	GROUP BY cnpj HAVING count(*) > 1;
+--
+-- Deactivate, or correct the CNPJ of, all but one company of each and run
+-- the migrations again.
+DO $$
+DECLARE
+	conflicts TEXT;
+BEGIN
+	SELECT string_agg(format('%s: %s', cnpj, ids), '; ' ORDER BY cnpj)
+	INTO conflicts
+	FROM (
+		SELECT cnpj, string_agg(id::text, ', ' ORDER BY created_at, id) AS ids
+		FROM companies
+		WHERE deleted_at IS NULL
+		GROUP BY cnpj
+		HAVING count(*) > 1
+	) duplicates;
+
+	IF conflicts IS NOT NULL THEN
+		RAISE EXCEPTION 'cannot make company CNPJs unique, live companies share them (cnpj: company ids): %. Deactivate or correct all but one company of each CNPJ and migrate again', conflicts;
+	END IF;
+END;
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS companies_cnpj_key
+	ON companies (cnpj) WHERE deleted_at IS NULL;
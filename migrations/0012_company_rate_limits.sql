@@ -0,0 +1,3 @@
+-- Per-company rate limits; NULL falls back to the configured defaults.
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS rate_limit INTEGER CHECK (rate_limit > 0);
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS rate_limit_burst INTEGER CHECK (rate_limit_burst > 0);
@@ -0,0 +1,8 @@
+-- Messages that keep failing are dead-lettered after the configured number
+-- of attempts instead of being retried forever. They stay for inspection and
+-- are left out of the pending index.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (position)
+	WHERE published_at IS NULL AND dead_lettered_at IS NULL;
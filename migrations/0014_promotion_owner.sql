@@ -0,0 +1,6 @@
+-- The company that created a promotion owns it, and only its webhooks
+-- receive the promotion's events. Promotions created with the admin key, or
+-- before owners were recorded, have none. The outbox keeps the owner of each
+-- event so the relay need not look the aggregate up again.
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS company_id UUID REFERENCES companies (id);
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS company_id UUID;
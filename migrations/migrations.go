@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockKey is the advisory lock held while migrating, so instances starting
+// together apply each migration once, one after another.
+const lockKey = 0x6d696772617465
+
+const createVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+
+// Versions returns the embedded migration versions in the order they apply.
+func Versions() ([]string, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	versions := make([]string, 0, len(names))
+	for _, name := range names {
+		versions = append(versions, strings.TrimSuffix(name, ".sql"))
+	}
+	return versions, nil
+}
+
+// queryer is what Pending needs of a database or one of its connections.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// Pending returns the versions not yet recorded in schema_migrations.
+func Pending(ctx context.Context, db *sqlx.DB) ([]string, error) {
+	return pending(ctx, db)
+}
+
+func pending(ctx context.Context, db queryer) ([]string, error) {
+	if _, err := db.ExecContext(ctx, createVersionTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []string
+	if err := db.SelectContext(ctx, &applied, "SELECT version FROM schema_migrations"); err != nil {
+		return nil, fmt.Errorf("failed to fetch applied migrations: %w", err)
+	}
+	done := make(map[string]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	versions, err := Versions()
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, v := range versions {
+		if !done[v] {
+			pending = append(pending, v)
+		}
+	}
+	return pending, nil
+}
+
+// Apply runs every pending migration, each in its own transaction. It holds
+// the migration advisory lock on a dedicated connection throughout, waiting
+// for any other instance that is migrating, and reads what is pending only
+// once it has the lock.
+func Apply(ctx context.Context, db *sqlx.DB) error {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			slog.ErrorContext(ctx, "Failed to release migration lock", "error", err)
+		}
+	}()
+
+	pending, err := pending(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range pending {
+		script, err := files.ReadFile(version + ".sql")
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", version, err)
+		}
+
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %s: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", version, err)
+		}
+	}
+	return nil
+}
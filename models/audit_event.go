@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditActionCreate     = "create"
+	AuditActionUpdate     = "update"
+	AuditActionDelete     = "delete"
+	AuditActionRestore    = "restore"
+	AuditActionRotateKey  = "rotate_api_key"
+	AuditActionTransition = "transition"
+	AuditActionRollback   = "rollback"
+)
+
+const (
+	ResourceCompany   = "company"
+	ResourcePromotion = "promotion"
+)
+
+// AuditEvent is an append-only record of a mutation. Changes maps each
+// modified field to its [before, after] values.
+type AuditEvent struct {
+	ID           uuid.UUID       `json:"id" db:"id"`
+	Actor        string          `json:"actor" db:"actor"`
+	APIKeyHint   *string         `json:"api_key_hint,omitempty" db:"api_key_hint"`
+	Action       string          `json:"action" db:"action"`
+	ResourceType string          `json:"resource_type" db:"resource_type"`
+	ResourceID   uuid.UUID       `json:"resource_id" db:"resource_id"`
+	Changes      json.RawMessage `json:"changes" db:"changes"`
+	RequestID    *string         `json:"request_id,omitempty" db:"request_id"`
+	IP           *string         `json:"ip,omitempty" db:"ip"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter narrows an audit event query. Zero fields match everything.
+type AuditFilter struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   *uuid.UUID
+	From         *time.Time
+	To           *time.Time
+}
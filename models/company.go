@@ -10,9 +10,17 @@ type Company struct {
 	ID        uuid.UUID  `json:"id" db:"id"`
 	Name      string     `json:"name" db:"name"`
 	Cnpj      string     `json:"cnpj" db:"cnpj"`
-	APIKey    string     `json:"api_key" db:"api_key"`
+	APIKey    string     `json:"api_key,omitempty" db:"api_key"`
+	Timezone  string     `json:"timezone" db:"timezone"`
 	IsActive  bool       `json:"is_active" db:"is_active"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Version   int        `json:"-" db:"version"`
+
+	// RateLimit is how many requests per minute the company may make on
+	// average, and RateLimitBurst how many at once. Nil means the configured
+	// default. Only the administrator can change them.
+	RateLimit      *int `json:"rate_limit,omitempty" db:"rate_limit"`
+	RateLimitBurst *int `json:"rate_limit_burst,omitempty" db:"rate_limit_burst"`
 }
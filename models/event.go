@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventPromotionCreated   = "promotion.created"
+	EventPromotionUpdated   = "promotion.updated"
+	EventPromotionActivated = "promotion.activated"
+	EventPromotionScheduled = "promotion.scheduled"
+	EventPromotionExpired   = "promotion.expired"
+	EventPromotionExhausted = "promotion.exhausted"
+	EventPromotionPaused    = "promotion.paused"
+	EventPromotionResumed   = "promotion.resumed"
+	EventPromotionArchived  = "promotion.archived"
+	EventPromotionDeleted   = "promotion.deleted"
+	EventPromotionRestored  = "promotion.restored"
+	EventPromotionRedeemed  = "promotion.redeemed"
+)
+
+const (
+	EventCompanyCreated       = "company.created"
+	EventCompanyUpdated       = "company.updated"
+	EventCompanyDeactivated   = "company.deactivated"
+	EventCompanyAPIKeyRotated = "company.api_key_rotated"
+)
+
+const (
+	AggregatePromotion = "promotion"
+	AggregateCompany   = "company"
+)
+
+// Event is a domain event describing a change to an aggregate. CompanyID is
+// the company that owns the aggregate, when it has one; only its webhooks
+// receive the event.
+type Event struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	Type          string          `json:"type" db:"type"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id" db:"aggregate_id"`
+	CompanyID     *uuid.UUID      `json:"company_id,omitempty" db:"company_id"`
+	OccurredAt    time.Time       `json:"occurred_at" db:"occurred_at"`
+	Data          json.RawMessage `json:"data" db:"data"`
+}
+
+// NewEvent builds an event for the aggregate, marshaling data as its payload.
+func NewEvent(eventType, aggregateType string, aggregateID uuid.UUID, occurredAt time.Time, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:            uuid.New(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		OccurredAt:    occurredAt,
+		Data:          payload,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage is an event stored in the same transaction as the change
+// that produced it, waiting to be relayed. Position orders messages in the
+// order they were enqueued, which is not always the order their
+// transactions committed in.
+type OutboxMessage struct {
+	Position       int64           `json:"position" db:"position"`
+	EventID        uuid.UUID       `json:"event_id" db:"event_id"`
+	Type           string          `json:"type" db:"type"`
+	AggregateType  string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID    uuid.UUID       `json:"aggregate_id" db:"aggregate_id"`
+	CompanyID      *uuid.UUID      `json:"company_id,omitempty" db:"company_id"`
+	Data           json.RawMessage `json:"data" db:"data"`
+	OccurredAt     time.Time       `json:"occurred_at" db:"occurred_at"`
+	PublishedAt    *time.Time      `json:"published_at,omitempty" db:"published_at"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	DeadLetteredAt *time.Time      `json:"dead_lettered_at,omitempty" db:"dead_lettered_at"`
+}
+
+// Event returns the domain event carried by the message.
+func (m *OutboxMessage) Event() Event {
+	return Event{
+		ID:            m.EventID,
+		Type:          m.Type,
+		AggregateType: m.AggregateType,
+		AggregateID:   m.AggregateID,
+		CompanyID:     m.CompanyID,
+		OccurredAt:    m.OccurredAt,
+		Data:          m.Data,
+	}
+}
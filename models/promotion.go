@@ -1,6 +1,7 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,19 +12,61 @@ const (
 	DiscountTypeFixed      = "fixed"
 )
 
+// Promotion lifecycle statuses. Draft, paused and archived are set through
+// explicit transitions; the others follow from the date interval and usage.
+const (
+	PromotionStatusDraft     = "draft"
+	PromotionStatusScheduled = "scheduled"
+	PromotionStatusActive    = "active"
+	PromotionStatusPaused    = "paused"
+	PromotionStatusExpired   = "expired"
+	PromotionStatusExhausted = "exhausted"
+	PromotionStatusArchived  = "archived"
+)
+
 type Promotion struct {
-	ID                    uuid.UUID `json:"id" db:"id"`
-	Title                 string    `json:"title" db:"title"`
-	Description           string    `json:"description,omitempty" db:"description"`
-	DiscountType          string    `json:"discount_type" db:"discount_type"`
-	DiscountValue         float64   `json:"discount_value" db:"discount_value"`
-	StartDate             time.Time `json:"start_date" db:"start_date"`
-	EndDate               time.Time `json:"end_date" db:"end_date"`
-	MinimumPurchaseAmount *float64  `json:"minimum_purchase_amount,omitempty" db:"minimum_purchase_amount"`
-	MaxUsage              *int      `json:"max_usage,omitempty" db:"max_usage"`
-	CurrentUsage          int       `json:"current_usage" db:"current_usage"`
-	CouponCode            *string   `json:"coupon_code,omitempty" db:"coupon_code"`
-	IsActive              bool      `json:"is_active" db:"is_active"`
-	CreatedAt             time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
+	ID                    uuid.UUID          `json:"id" db:"id"`
+	CompanyID             *uuid.UUID         `json:"company_id,omitempty" db:"company_id"`
+	Title                 string             `json:"title" db:"title"`
+	Description           string             `json:"description,omitempty" db:"description"`
+	DiscountType          string             `json:"discount_type" db:"discount_type"`
+	DiscountValue         float64            `json:"discount_value" db:"discount_value"`
+	StartDate             time.Time          `json:"start_date" db:"start_date"`
+	EndDate               time.Time          `json:"end_date" db:"end_date"`
+	Schedule              *PromotionSchedule `json:"schedule,omitempty" db:"schedule"`
+	MinimumPurchaseAmount *float64           `json:"minimum_purchase_amount,omitempty" db:"minimum_purchase_amount"`
+	MaxUsage              *int               `json:"max_usage,omitempty" db:"max_usage"`
+	CurrentUsage          int                `json:"current_usage" db:"current_usage"`
+	CouponCode            *string            `json:"coupon_code,omitempty" db:"coupon_code"`
+	Status                string             `json:"status" db:"status"`
+	IsActive              bool               `json:"is_active" db:"is_active"`
+	CreatedAt             time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time          `json:"updated_at" db:"updated_at"`
+	DeletedAt             *time.Time         `json:"deleted_at,omitempty" db:"deleted_at"`
+	Version               int                `json:"-" db:"version"`
+}
+
+// Clone copies the values behind the pointers and slices of p, so the copy
+// shares no memory with it.
+func (p Promotion) Clone() Promotion {
+	if p.Schedule != nil {
+		schedule := *p.Schedule
+		schedule.DaysOfWeek = slices.Clone(schedule.DaysOfWeek)
+		schedule.TimeWindows = slices.Clone(schedule.TimeWindows)
+		p.Schedule = &schedule
+	}
+	p.CompanyID = clonePtr(p.CompanyID)
+	p.MinimumPurchaseAmount = clonePtr(p.MinimumPurchaseAmount)
+	p.MaxUsage = clonePtr(p.MaxUsage)
+	p.CouponCode = clonePtr(p.CouponCode)
+	p.DeletedAt = clonePtr(p.DeletedAt)
+	return p
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
 }
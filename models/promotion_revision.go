@@ -0,0 +1,19 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PromotionRevision is an immutable snapshot of a promotion taken whenever
+// its content is created or changed. Revisions are numbered from 1.
+type PromotionRevision struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	PromotionID uuid.UUID       `json:"promotion_id" db:"promotion_id"`
+	Revision    int             `json:"revision" db:"revision"`
+	Snapshot    json.RawMessage `json:"snapshot" db:"snapshot"`
+	Actor       string          `json:"actor" db:"actor"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
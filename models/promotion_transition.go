@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TransitionPause   = "pause"
+	TransitionResume  = "resume"
+	TransitionArchive = "archive"
+	TransitionSync    = "sync"
+)
+
+// PromotionTransition records a change of a promotion's stored status and
+// who performed it.
+type PromotionTransition struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	PromotionID uuid.UUID `json:"promotion_id" db:"promotion_id"`
+	Action      string    `json:"action" db:"action"`
+	FromStatus  string    `json:"from_status" db:"from_status"`
+	ToStatus    string    `json:"to_status" db:"to_status"`
+	Actor       string    `json:"actor" db:"actor"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
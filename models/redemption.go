@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Redemption is one use of a promotion on a purchase, with the discount it
+// granted.
+type Redemption struct {
+	PromotionID    uuid.UUID `json:"promotion_id"`
+	PurchaseAmount float64   `json:"purchase_amount"`
+	DiscountAmount float64   `json:"discount_amount"`
+	FinalAmount    float64   `json:"final_amount"`
+	RedeemedAt     time.Time `json:"redeemed_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// PromotionSchedule restricts when a promotion applies inside its
+// StartDate/EndDate interval. All fields are optional and combine with AND:
+// DaysOfWeek and RRule select the days, TimeWindows select the times of day,
+// evaluated in Timezone (IANA name, defaults to the company time zone).
+type PromotionSchedule struct {
+	Timezone    string       `json:"timezone,omitempty"`
+	DaysOfWeek  []string     `json:"days_of_week,omitempty"`
+	TimeWindows []TimeWindow `json:"time_windows,omitempty"`
+	RRule       string       `json:"rrule,omitempty"`
+}
+
+// TimeWindow is a local time range in "HH:MM" format. End is exclusive; a
+// window whose end is not after its start wraps past midnight.
+type TimeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (s PromotionSchedule) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	// A string keeps lib/pq from sending the JSON in binary bytea format.
+	return string(data), nil
+}
+
+func (s *PromotionSchedule) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into PromotionSchedule", src)
+	}
+}
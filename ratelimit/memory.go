@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"promo-api/utils"
+)
+
+// sweepInterval is how often MemoryStore forgets buckets that have filled
+// up again, which behave exactly like new ones.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps token buckets in process memory. Each instance of the
+// API then enforces its limits on its own.
+type MemoryStore struct {
+	Clock utils.Clock
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+func NewMemoryStore(clock utils.Clock) *MemoryStore {
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+	return &MemoryStore{Clock: clock, buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := s.Clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.refill(now, limit)
+
+	result := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = limit.refill(1 - b.tokens)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = limit.refill(float64(limit.Burst) - b.tokens)
+	return result, nil
+}
+
+// refill adds the tokens earned since the last update, up to the burst. A
+// changed limit applies from now on.
+func (b *bucket) refill(now time.Time, limit Limit) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens += elapsed.Minutes() * float64(limit.Rate)
+	}
+	b.tokens = math.Min(b.tokens, float64(limit.Burst))
+	b.updated = now
+	b.limit = limit
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.updated).Minutes()*float64(b.limit.Rate) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore(clock)
+	limit := Limit{Rate: 60, Burst: 2}
+	ctx := context.Background()
+
+	take := func() Result {
+		t.Helper()
+		result, err := store.Take(ctx, "k", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if r := take(); !r.Allowed || r.Remaining != 1 || r.Reset != time.Second {
+		t.Fatalf("first take = %+v, want allowed with 1 left, full in 1s", r)
+	}
+	if r := take(); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("second take = %+v, want allowed with none left", r)
+	}
+	if r := take(); r.Allowed || r.RetryAfter != time.Second || r.Reset != 2*time.Second {
+		t.Fatalf("third take = %+v, want denied, retry in 1s, full in 2s", r)
+	}
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	if r := take(); r.Allowed || r.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("take after 0.5s = %+v, want denied, retry in 0.5s", r)
+	}
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	if r := take(); !r.Allowed {
+		t.Fatalf("take after 1s = %+v, want allowed", r)
+	}
+
+	// Refills stop at the burst.
+	clock.now = clock.now.Add(time.Hour)
+	if r := take(); !r.Allowed || r.Remaining != 1 {
+		t.Fatalf("take after an hour = %+v, want allowed with 1 left", r)
+	}
+
+	// Keys have separate buckets.
+	if r, _ := store.Take(ctx, "other", limit); !r.Allowed || r.Remaining != 1 {
+		t.Fatalf("take on another key = %+v, want a full bucket", r)
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore(clock)
+	ctx := context.Background()
+
+	store.Take(ctx, "idle", Limit{Rate: 60, Burst: 10})
+	store.Take(ctx, "slow", Limit{Rate: 1, Burst: 10})
+	store.Take(ctx, "slow", Limit{Rate: 1, Burst: 10})
+	clock.now = clock.now.Add(sweepInterval)
+	store.Take(ctx, "new", Limit{Rate: 60, Burst: 10})
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := store.buckets["slow"]; !ok {
+		t.Error("bucket still refilling was swept")
+	}
+}
+
+func TestLimitPolicy(t *testing.T) {
+	if got := (Limit{Rate: 600, Burst: 100}).Policy(); got != "100;w=10" {
+		t.Errorf("Policy() = %q, want 100;w=10", got)
+	}
+}
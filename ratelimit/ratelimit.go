@@ -0,0 +1,56 @@
+// Package ratelimit implements token bucket rate limiting behind a Store
+// interface, so the buckets can live in process memory for a single
+// instance or, later, in a shared store such as Redis for several.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limit allows Rate requests per minute on average, and bursts of up to
+// Burst requests at once.
+type Limit struct {
+	Rate  int
+	Burst int
+}
+
+// Enabled reports whether the limit restricts anything. A zero Rate means no
+// limit.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// Policy describes the limit for the RateLimit-Policy header, such as
+// "100;w=60" for a burst of 100 refilled over a minute.
+func (l Limit) Policy() string {
+	return fmt.Sprintf("%d;w=%d", l.Burst, int(math.Ceil(l.refill(float64(l.Burst)).Seconds())))
+}
+
+// refill returns how long the bucket takes to gain tokens.
+func (l Limit) refill(tokens float64) time.Duration {
+	return time.Duration(tokens * float64(time.Minute) / float64(l.Rate))
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// RetryAfter is how long until a token is available again, zero when
+	// one is.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the token buckets. Implementations must be safe for
+// concurrent use, and a shared one must take tokens atomically, so that
+// instances sharing it enforce one limit together.
+type Store interface {
+	// Take takes a token from the bucket named key, refilled according to
+	// limit, if it has one.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
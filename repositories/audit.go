@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"promo-api/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepositoryInterface interface {
+	CreateEvent(ctx context.Context, event *models.AuditEvent) error
+	FindEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEvent, error)
+}
+
+type AuditRepository struct {
+	DB *sqlx.DB
+}
+
+var _ AuditRepositoryInterface = &AuditRepository{}
+
+func (r *AuditRepository) db(ctx context.Context) DBTX {
+	return traced(executor(ctx, r.DB))
+}
+
+func (r *AuditRepository) CreateEvent(ctx context.Context, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (
+			id, actor, api_key_hint, action, resource_type, resource_id, changes, request_id, ip, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		)`
+	_, err := r.db(ctx).ExecContext(ctx, query,
+		event.ID, event.Actor, event.APIKeyHint, event.Action, event.ResourceType, event.ResourceID,
+		string(event.Changes), event.RequestID, event.IP, event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+	return nil
+}
+
+func (r *AuditRepository) FindEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Actor != "" {
+		where("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		where("resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != nil {
+		where("resource_id = $%d", *filter.ResourceID)
+	}
+	if filter.From != nil {
+		where("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < $%d", *filter.To)
+	}
+
+	query := "SELECT * FROM audit_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	events := []models.AuditEvent{}
+	if err := r.db(ctx).SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to fetch audit events: %w", err)
+	}
+	return events, nil
+}
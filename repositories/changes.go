@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Writes notify these channels with the ID of the changed row, for other
+// instances to drop what they cached of it. Inside a transaction the
+// notification is only sent if it commits.
+const (
+	CompanyChangesChannel   = "company_changes"
+	PromotionChangesChannel = "promotion_changes"
+)
+
+// changesPing is how often an idle listener checks its connection, so a
+// dead one is noticed and replaced.
+const changesPing = 90 * time.Second
+
+func notifyChange(ctx context.Context, db DBTX, channel string, id uuid.UUID) error {
+	_, err := db.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to notify %s of %s: %w", channel, id, err)
+	}
+	return nil
+}
+
+// Change is a row changed on Channel. The zero Change means changes may have
+// been missed, after the connection was lost, so listeners should drop
+// everything they cached.
+type Change struct {
+	Channel string
+	ID      uuid.UUID
+}
+
+// Changes receives the notifications the repositories send, including those
+// of other instances.
+type Changes struct {
+	listener *pq.Listener
+}
+
+// ListenChanges subscribes listener to channels.
+func ListenChanges(listener *pq.Listener, channels ...string) (*Changes, error) {
+	for _, channel := range channels {
+		if err := listener.Listen(channel); err != nil {
+			return nil, fmt.Errorf("failed to listen to %s: %w", channel, err)
+		}
+	}
+	return &Changes{listener: listener}, nil
+}
+
+// Next waits for the next change. It fails once ctx is done or the listener
+// is closed.
+func (c *Changes) Next(ctx context.Context) (Change, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return Change{}, ctx.Err()
+		case n, ok := <-c.listener.Notify:
+			if !ok {
+				return Change{}, errors.New("changes listener closed")
+			}
+			if n == nil {
+				return Change{}, nil
+			}
+			id, err := uuid.Parse(n.Extra)
+			if err != nil {
+				slog.WarnContext(ctx, "Ignoring malformed change notification", "channel", n.Channel, "payload", n.Extra)
+				continue
+			}
+			return Change{Channel: n.Channel, ID: id}, nil
+		case <-time.After(changesPing):
+			if err := c.listener.Ping(); err != nil {
+				slog.WarnContext(ctx, "Changes listener is disconnected", "error", err)
+			}
+		}
+	}
+}
+
+func (c *Changes) Close() error {
+	return c.listener.Close()
+}
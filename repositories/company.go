@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -26,43 +28,67 @@ type CompanyRepositoryInterface interface {
 
 type CompanyRepository struct {
 	DB *sqlx.DB
+	// Replicas, when set, serve the lookups that tolerate replication lag.
+	Replicas *Replicas
 }
 
 var _ CompanyRepositoryInterface = &CompanyRepository{}
 
+func (r *CompanyRepository) db(ctx context.Context) DBTX {
+	return traced(executor(ctx, r.DB))
+}
+
+func (r *CompanyRepository) reader(ctx context.Context) DBTX {
+	return traced(reader(ctx, r.DB, r.Replicas))
+}
+
 func (r *CompanyRepository) CreateCompany(ctx context.Context, company *models.Company) error {
 	query := `
 		INSERT INTO companies (
-			id, name, cnpj, api_key, is_active, created_at, updated_at, deleted_at
+			id, name, cnpj, api_key, timezone, is_active, created_at, updated_at, deleted_at,
+			rate_limit, rate_limit_burst
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, NULL
+			$1, $2, $3, $4, $5, $6, $7, $8, NULL, $9, $10
 		)`
-	_, err := r.DB.ExecContext(ctx, query,
-		company.ID, company.Name, company.Cnpj, company.APIKey,
+	_, err := r.db(ctx).ExecContext(ctx, query,
+		company.ID, company.Name, company.Cnpj, company.APIKey, company.Timezone,
 		company.IsActive, company.CreatedAt, company.UpdatedAt,
+		company.RateLimit, company.RateLimitBurst,
 	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("company with CNPJ %s: %w", company.Cnpj, ErrDuplicate)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create company: %w", err)
 	}
-	return nil
+	return notifyChange(ctx, r.db(ctx), CompanyChangesChannel, company.ID)
 }
 
 func (r *CompanyRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Company, error) {
 	var company models.Company
 	query := "SELECT * FROM companies WHERE id = $1 AND deleted_at IS NULL"
-	err := r.DB.GetContext(ctx, &company, query, id)
+	err := r.reader(ctx).GetContext(ctx, &company, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("company not found with ID %s: %w", id, ErrNotFound)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("company not found with ID %s: %w", id, err)
+		return nil, fmt.Errorf("failed to fetch company with ID %s: %w", id, err)
 	}
 	return &company, nil
 }
 
+// FindByAPIKey always reads the primary. It authenticates requests, and a
+// replica that has not caught up with a rotation or deactivation would still
+// accept the old key, and hand the key cache the row it just invalidated.
 func (r *CompanyRepository) FindByAPIKey(ctx context.Context, apiKey string) (*models.Company, error) {
 	var company models.Company
 	query := "SELECT * FROM companies WHERE api_key = $1 AND deleted_at IS NULL"
-	err := r.DB.GetContext(ctx, &company, query, apiKey)
+	err := r.db(ctx).GetContext(ctx, &company, query, apiKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("company not found with API key: %w", ErrNotFound)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("company not found with API key: %w", err)
+		return nil, fmt.Errorf("failed to fetch company by API key: %w", err)
 	}
 	return &company, nil
 }
@@ -70,17 +96,20 @@ func (r *CompanyRepository) FindByAPIKey(ctx context.Context, apiKey string) (*m
 func (r *CompanyRepository) FindByCnpj(ctx context.Context, cnpj string) (*models.Company, error) {
 	var company models.Company
 	query := "SELECT * FROM companies WHERE cnpj = $1 AND deleted_at IS NULL"
-	err := r.DB.GetContext(ctx, &company, query, cnpj)
+	err := r.db(ctx).GetContext(ctx, &company, query, cnpj)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("company not found with CNPJ %s: %w", cnpj, ErrNotFound)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("company not found with CNPJ %s: %w", cnpj, err)
+		return nil, fmt.Errorf("failed to fetch company with CNPJ %s: %w", cnpj, err)
 	}
 	return &company, nil
 }
 
 func (r *CompanyRepository) FindAll(ctx context.Context, limit, offset int) ([]models.Company, error) {
 	var companies []models.Company
-	query := "SELECT * FROM companies WHERE deleted_at IS NULL LIMIT $1 OFFSET $2"
-	err := r.DB.SelectContext(ctx, &companies, query, limit, offset)
+	query := "SELECT * FROM companies WHERE deleted_at IS NULL ORDER BY created_at, id LIMIT $1 OFFSET $2"
+	err := r.reader(ctx).SelectContext(ctx, &companies, query, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch companies: %w", err)
 	}
@@ -90,28 +119,40 @@ func (r *CompanyRepository) FindAll(ctx context.Context, limit, offset int) ([]m
 func (r *CompanyRepository) UpdateCompany(ctx context.Context, company *models.Company) error {
 	query := `
 		UPDATE companies
-		SET name = $1, cnpj = $2, api_key = $3, is_active = $4, updated_at = $5
-		WHERE id = $6 AND deleted_at IS NULL`
-	_, err := r.DB.ExecContext(ctx, query,
-		company.Name, company.Cnpj, company.APIKey, company.IsActive, company.UpdatedAt, company.ID,
+		SET name = $1, cnpj = $2, api_key = $3, timezone = $4, is_active = $5, updated_at = $6,
+			rate_limit = $9, rate_limit_burst = $10, version = version + 1
+		WHERE id = $7 AND version = $8 AND deleted_at IS NULL`
+	result, err := r.db(ctx).ExecContext(ctx, query,
+		company.Name, company.Cnpj, company.APIKey, company.Timezone, company.IsActive, company.UpdatedAt, company.ID,
+		company.Version, company.RateLimit, company.RateLimitBurst,
 	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("company with CNPJ %s: %w", company.Cnpj, ErrDuplicate)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update company %s: %w", company.Name, err)
 	}
-	return nil
+	if err := expectVersion(result, fmt.Sprintf("company %s", company.ID), company.Version); err != nil {
+		return err
+	}
+	company.Version++
+	return notifyChange(ctx, r.db(ctx), CompanyChangesChannel, company.ID)
 }
 
 func (r *CompanyRepository) DeactivateCompany(ctx context.Context, id uuid.UUID) error {
 	query := `
 		UPDATE companies
-		SET is_active = false, deleted_at = $1, updated_at = $1
+		SET is_active = false, deleted_at = $1, updated_at = $1, version = version + 1
 		WHERE id = $2 AND deleted_at IS NULL`
 	now := time.Now()
-	_, err := r.DB.ExecContext(ctx, query, now, id)
+	result, err := r.db(ctx).ExecContext(ctx, query, now, id)
 	if err != nil {
 		return fmt.Errorf("failed to deactivate company with ID %s: %w", id, err)
 	}
-	return nil
+	if err := expectAffected(result, fmt.Sprintf("company not found with ID %s", id)); err != nil {
+		return err
+	}
+	return notifyChange(ctx, r.db(ctx), CompanyChangesChannel, id)
 }
 
 func (r *CompanyRepository) RotateAPIKey(ctx context.Context, id uuid.UUID) (string, error) {
@@ -122,12 +163,18 @@ func (r *CompanyRepository) RotateAPIKey(ctx context.Context, id uuid.UUID) (str
 
 	query := `
 		UPDATE companies
-		SET api_key = $1, updated_at = $2
+		SET api_key = $1, updated_at = $2, version = version + 1
 		WHERE id = $3 AND deleted_at IS NULL`
-	_, err = r.DB.ExecContext(ctx, query, newKey, time.Now(), id)
+	result, err := r.db(ctx).ExecContext(ctx, query, newKey, time.Now(), id)
 	if err != nil {
 		return "", fmt.Errorf("failed to rotate API key for company %s: %w", id, err)
 	}
+	if err := expectAffected(result, fmt.Sprintf("company not found with ID %s", id)); err != nil {
+		return "", err
+	}
+	if err := notifyChange(ctx, r.db(ctx), CompanyChangesChannel, id); err != nil {
+		return "", err
+	}
 
 	return newKey, nil
 }
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/cache"
+	"promo-api/models"
+	"promo-api/utils"
+)
+
+// CompanyCacheOptions bound the API key cache. A TTL of zero disables the
+// cache, and a NegativeTTL of zero disables caching unknown keys.
+type CompanyCacheOptions struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	Size        int
+	Clock       utils.Clock
+}
+
+// CachedCompanyRepository caches FindByAPIKey, which authenticates every
+// request, in process memory. Unknown keys are cached too, so guessing keys
+// does not cost a query each. Writes through it invalidate the company once
+// their transaction commits; Invalidate and Purge take the changes made by
+// other instances. Lookups inside a transaction bypass the cache, and the
+// ones that fill it read the primary, since a lagging replica could return
+// the row an invalidation just dropped.
+type CachedCompanyRepository struct {
+	CompanyRepositoryInterface
+
+	opts CompanyCacheOptions
+	// byKey maps API keys to their company, or to nil for unknown keys.
+	byKey *cache.LRU[string, *models.Company]
+
+	// generation counts invalidations. A lookup only fills the cache if
+	// none happened while it ran, or it could bring back a rotated key or
+	// a deactivated company. mu makes the check and the fill one step.
+	mu         sync.Mutex
+	generation uint64
+}
+
+var _ CompanyRepositoryInterface = &CachedCompanyRepository{}
+
+func NewCachedCompanyRepository(repo CompanyRepositoryInterface, opts CompanyCacheOptions) *CachedCompanyRepository {
+	return &CachedCompanyRepository{
+		CompanyRepositoryInterface: repo,
+		opts:                       opts,
+		byKey:                      cache.NewLRU[string, *models.Company](opts.Size, opts.Clock),
+	}
+}
+
+// FindByAPIKey returns a copy of the cached company, so callers cannot
+// change the cached one.
+func (r *CachedCompanyRepository) FindByAPIKey(ctx context.Context, apiKey string) (*models.Company, error) {
+	if InTx(ctx) {
+		return r.CompanyRepositoryInterface.FindByAPIKey(ctx, apiKey)
+	}
+	if company, ok := r.byKey.Get(apiKey); ok {
+		if company == nil {
+			return nil, errUnknownAPIKey
+		}
+		found := *company
+		return &found, nil
+	}
+
+	generation := r.currentGeneration()
+	company, err := r.CompanyRepositoryInterface.FindByAPIKey(WithPrimary(ctx), apiKey)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if r.opts.NegativeTTL > 0 {
+			r.fill(generation, apiKey, nil, r.opts.NegativeTTL)
+		}
+		return nil, err
+	case err != nil:
+		return nil, err
+	}
+	cached := *company
+	r.fill(generation, apiKey, &cached, r.opts.TTL)
+	return company, nil
+}
+
+func (r *CachedCompanyRepository) currentGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// fill caches company under apiKey unless the cache was invalidated since
+// generation.
+func (r *CachedCompanyRepository) fill(generation uint64, apiKey string, company *models.Company, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation == generation {
+		r.byKey.Set(apiKey, company, ttl)
+	}
+}
+
+var errUnknownAPIKey = fmt.Errorf("company not found with API key: %w", ErrNotFound)
+
+func (r *CachedCompanyRepository) CreateCompany(ctx context.Context, company *models.Company) error {
+	err := r.CompanyRepositoryInterface.CreateCompany(ctx, company)
+	AfterTx(ctx, func() { r.Invalidate(company.ID) })
+	return err
+}
+
+func (r *CachedCompanyRepository) UpdateCompany(ctx context.Context, company *models.Company) error {
+	err := r.CompanyRepositoryInterface.UpdateCompany(ctx, company)
+	AfterTx(ctx, func() { r.Invalidate(company.ID) })
+	return err
+}
+
+func (r *CachedCompanyRepository) DeactivateCompany(ctx context.Context, id uuid.UUID) error {
+	err := r.CompanyRepositoryInterface.DeactivateCompany(ctx, id)
+	AfterTx(ctx, func() { r.Invalidate(id) })
+	return err
+}
+
+func (r *CachedCompanyRepository) RotateAPIKey(ctx context.Context, id uuid.UUID) (string, error) {
+	key, err := r.CompanyRepositoryInterface.RotateAPIKey(ctx, id)
+	AfterTx(ctx, func() { r.Invalidate(id) })
+	return key, err
+}
+
+// Invalidate forgets the company id, under any key, and every unknown key,
+// one of which may now be its new key.
+func (r *CachedCompanyRepository) Invalidate(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.byKey.DeleteFunc(func(_ string, company *models.Company) bool {
+		return company == nil || company.ID == id
+	})
+}
+
+// Purge forgets everything, for when changes may have been missed.
+func (r *CachedCompanyRepository) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.byKey.Purge()
+}
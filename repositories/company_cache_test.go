@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+)
+
+// keyRepo serves FindByAPIKey from a map and counts the lookups. When block
+// is set, FindByAPIKey reads the map, signals started and waits for block to
+// be closed before answering.
+type keyRepo struct {
+	CompanyRepositoryInterface
+	companies map[string]models.Company
+	lookups   int
+	started   chan struct{}
+	block     chan struct{}
+}
+
+func (r *keyRepo) FindByAPIKey(ctx context.Context, apiKey string) (*models.Company, error) {
+	r.lookups++
+	company, ok := r.companies[apiKey]
+	if block := r.block; block != nil {
+		r.started <- struct{}{}
+		<-block
+	}
+	if !ok {
+		return nil, fmt.Errorf("company not found with API key: %w", ErrNotFound)
+	}
+	return &company, nil
+}
+
+func (r *keyRepo) RotateAPIKey(ctx context.Context, id uuid.UUID) (string, error) {
+	for key, company := range r.companies {
+		if company.ID == id {
+			delete(r.companies, key)
+			company.APIKey = "rotated-key"
+			r.companies[company.APIKey] = company
+			return company.APIKey, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+type fixedClock struct{ now time.Time }
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+func TestCachedCompanyRepository(t *testing.T) {
+	ctx := context.Background()
+	acme := models.Company{ID: uuid.New(), Name: "Acme", APIKey: "acme-key"}
+	repo := &keyRepo{companies: map[string]models.Company{"acme-key": acme}}
+	clock := &fixedClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	cached := NewCachedCompanyRepository(repo, CompanyCacheOptions{
+		TTL: time.Minute, NegativeTTL: 10 * time.Second, Size: 10, Clock: clock,
+	})
+
+	find := func(key string, wantLookups int) (*models.Company, error) {
+		t.Helper()
+		company, err := cached.FindByAPIKey(ctx, key)
+		if repo.lookups != wantLookups {
+			t.Fatalf("FindByAPIKey(%q): %d lookups, want %d", key, repo.lookups, wantLookups)
+		}
+		return company, err
+	}
+
+	company, err := find("acme-key", 1)
+	if err != nil || company.ID != acme.ID {
+		t.Fatalf("FindByAPIKey = %v, %v, want Acme", company, err)
+	}
+	company.Name = "changed by the caller"
+	if company, _ := find("acme-key", 1); company.Name != "Acme" {
+		t.Errorf("cached company name = %q, want Acme", company.Name)
+	}
+
+	// Unknown keys are cached for the shorter negative TTL.
+	for range 2 {
+		if _, err := find("bad-key", 2); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("FindByAPIKey(bad-key) = %v, want ErrNotFound", err)
+		}
+	}
+	clock.now = clock.now.Add(11 * time.Second)
+	find("bad-key", 3)
+	find("acme-key", 3)
+
+	// Invalidating a company drops it and every unknown key, one of which
+	// may be its new key.
+	repo.companies["bad-key"] = acme
+	cached.Invalidate(acme.ID)
+	if _, err := find("bad-key", 4); err != nil {
+		t.Errorf("FindByAPIKey(bad-key) after invalidation = %v, want the company", err)
+	}
+	find("acme-key", 5)
+
+	clock.now = clock.now.Add(time.Minute)
+	find("acme-key", 6)
+
+	cached.Purge()
+	find("acme-key", 7)
+}
+
+// TestCachedCompanyRepositoryRotationDuringLookup checks that a lookup that
+// read the old key before a rotation does not cache it after the rotation
+// invalidated it.
+func TestCachedCompanyRepositoryRotationDuringLookup(t *testing.T) {
+	ctx := context.Background()
+	acme := models.Company{ID: uuid.New(), Name: "Acme", APIKey: "acme-key"}
+	repo := &keyRepo{
+		companies: map[string]models.Company{"acme-key": acme},
+		started:   make(chan struct{}),
+		block:     make(chan struct{}),
+	}
+	cached := NewCachedCompanyRepository(repo, CompanyCacheOptions{TTL: time.Hour, NegativeTTL: time.Hour, Size: 10})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := cached.FindByAPIKey(ctx, "acme-key"); err != nil {
+			t.Errorf("FindByAPIKey before the rotation: %v", err)
+		}
+	}()
+	<-repo.started
+	if _, err := cached.RotateAPIKey(ctx, acme.ID); err != nil {
+		t.Fatalf("RotateAPIKey: %v", err)
+	}
+	close(repo.block)
+	<-done
+	repo.block = nil
+
+	if _, err := cached.FindByAPIKey(ctx, "acme-key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindByAPIKey(old key) after the rotation = %v, want ErrNotFound", err)
+	}
+	if company, err := cached.FindByAPIKey(ctx, "rotated-key"); err != nil || company.ID != acme.ID {
+		t.Errorf("FindByAPIKey(new key) = %v, %v, want Acme", company, err)
+	}
+}
+
+func TestCachedCompanyRepositoryBypassedInTx(t *testing.T) {
+	acme := models.Company{ID: uuid.New(), Name: "Acme", APIKey: "acme-key"}
+	repo := &keyRepo{companies: map[string]models.Company{"acme-key": acme}}
+	cached := NewCachedCompanyRepository(repo, CompanyCacheOptions{TTL: time.Hour, Size: 10})
+
+	err := TrackTx(funcTransactor{}).WithTx(context.Background(), func(ctx context.Context) error {
+		for range 2 {
+			if _, err := cached.FindByAPIKey(ctx, "acme-key"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || repo.lookups != 2 {
+		t.Errorf("lookups in a transaction = %d, %v, want 2 uncached", repo.lookups, err)
+	}
+}
+
+// laggingKeyRepo answers FindByAPIKey from a replica that never sees writes,
+// unless the context asks for the primary.
+type laggingKeyRepo struct {
+	keyRepo
+	replica map[string]models.Company
+}
+
+func (r *laggingKeyRepo) FindByAPIKey(ctx context.Context, apiKey string) (*models.Company, error) {
+	if usePrimary(ctx) {
+		return r.keyRepo.FindByAPIKey(ctx, apiKey)
+	}
+	r.lookups++
+	company, ok := r.replica[apiKey]
+	if !ok {
+		return nil, fmt.Errorf("company not found with API key: %w", ErrNotFound)
+	}
+	return &company, nil
+}
+
+// TestCachedCompanyRepositoryFillsFromPrimary checks that a lookup after an
+// invalidation does not cache the stale row of a lagging replica.
+func TestCachedCompanyRepositoryFillsFromPrimary(t *testing.T) {
+	ctx := context.Background()
+	acme := models.Company{ID: uuid.New(), Name: "Acme", APIKey: "acme-key"}
+	repo := &laggingKeyRepo{
+		keyRepo: keyRepo{companies: map[string]models.Company{"acme-key": acme}},
+		replica: map[string]models.Company{"acme-key": acme},
+	}
+	cached := NewCachedCompanyRepository(repo, CompanyCacheOptions{TTL: time.Hour, NegativeTTL: time.Hour, Size: 10})
+
+	if _, err := cached.FindByAPIKey(ctx, "acme-key"); err != nil {
+		t.Fatalf("FindByAPIKey before the rotation: %v", err)
+	}
+	if _, err := cached.RotateAPIKey(ctx, acme.ID); err != nil {
+		t.Fatalf("RotateAPIKey: %v", err)
+	}
+	for range 2 {
+		if company, err := cached.FindByAPIKey(ctx, "acme-key"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("FindByAPIKey(old key) after the rotation = %v, %v, want ErrNotFound", company, err)
+		}
+	}
+	if company, err := cached.FindByAPIKey(ctx, "rotated-key"); err != nil || company.ID != acme.ID {
+		t.Errorf("FindByAPIKey(new key) = %v, %v, want Acme", company, err)
+	}
+}
@@ -0,0 +1,65 @@
+package repositories_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+
+	"promo-api/migrations"
+	"promo-api/repositories"
+	"promo-api/repositories/repotest"
+)
+
+// TestContract runs the repository contract against the PostgreSQL database
+// at TEST_DATABASE_URL. Every table is truncated between subtests, so never
+// point it at a database holding data you want to keep.
+func TestContract(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sqlx.Connect("postgres", url)
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// Instances starting together migrate concurrently; each must wait for
+	// the others rather than fail on a migration they already applied.
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := migrations.Apply(context.Background(), db); err != nil {
+				t.Errorf("failed to migrate the test database: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		truncate := `
+			TRUNCATE companies, promotions, promotion_transitions, promotion_revisions,
+				audit_events, webhooks, webhook_deliveries, outbox
+			RESTART IDENTITY CASCADE`
+		if _, err := db.Exec(truncate); err != nil {
+			t.Fatalf("failed to truncate the test database: %v", err)
+		}
+		return repotest.Backend{
+			Companies:  &repositories.CompanyRepository{DB: db},
+			Promotions: &repositories.PromotionRepository{DB: db},
+			Audit:      &repositories.AuditRepository{DB: db},
+			Webhooks:   &repositories.WebhookRepository{DB: db},
+			Outbox:     &repositories.OutboxRepository{DB: db},
+			Tx:         &repositories.SQLTransactor{DB: db},
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is wrapped by lookups that match no row.
+	ErrNotFound = errors.New("not found")
+
+	// ErrVersionConflict is wrapped by conditional updates whose expected
+	// row version no longer matches.
+	ErrVersionConflict = errors.New("version conflict")
+
+	// ErrDuplicate is wrapped by writes rejected by a unique constraint.
+	ErrDuplicate = errors.New("already exists")
+)
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// expectAffected returns ErrNotFound, described by msg, when a write matched
+// no row.
+func expectAffected(result sql.Result, msg string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%s: %w", msg, ErrNotFound)
+	}
+	return nil
+}
+
+// expectVersion returns ErrVersionConflict when a conditional update on the
+// given version of resource matched no row.
+func expectVersion(result sql.Result, resource string, version int) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", resource, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%s is no longer at version %d: %w", resource, version, ErrVersionConflict)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"promo-api/models"
+	"promo-api/repositories"
+)
+
+type AuditRepository struct {
+	Store *Store
+}
+
+var _ repositories.AuditRepositoryInterface = &AuditRepository{}
+
+func (r *AuditRepository) CreateEvent(ctx context.Context, event *models.AuditEvent) error {
+	defer r.Store.lock(ctx)()
+	r.Store.data.audit = append(r.Store.data.audit, *event)
+	return nil
+}
+
+func (r *AuditRepository) FindEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEvent, error) {
+	defer r.Store.lock(ctx)()
+
+	var events []models.AuditEvent
+	for _, event := range r.Store.data.audit {
+		switch {
+		case filter.Actor != "" && event.Actor != filter.Actor,
+			filter.Action != "" && event.Action != filter.Action,
+			filter.ResourceType != "" && event.ResourceType != filter.ResourceType,
+			filter.ResourceID != nil && event.ResourceID != *filter.ResourceID,
+			filter.From != nil && event.CreatedAt.Before(*filter.From),
+			filter.To != nil && !event.CreatedAt.Before(*filter.To):
+			continue
+		}
+		events = append(events, event)
+	}
+	slices.SortFunc(events, func(a, b models.AuditEvent) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	return append([]models.AuditEvent{}, page(events, limit, offset)...), nil
+}
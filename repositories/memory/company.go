@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/utils"
+)
+
+type CompanyRepository struct {
+	Store *Store
+}
+
+var _ repositories.CompanyRepositoryInterface = &CompanyRepository{}
+
+// cnpjTaken reports whether a live company other than id holds cnpj, which
+// the companies_cnpj_key index forbids.
+func cnpjTaken(t *tables, cnpj string, id uuid.UUID) bool {
+	for _, c := range t.companies {
+		if c.Cnpj == cnpj && c.DeletedAt == nil && c.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CompanyRepository) CreateCompany(ctx context.Context, company *models.Company) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	if _, ok := t.companies[company.ID]; ok {
+		return fmt.Errorf("failed to create company: duplicate ID %s", company.ID)
+	}
+	if cnpjTaken(t, company.Cnpj, company.ID) {
+		return fmt.Errorf("company with CNPJ %s: %w", company.Cnpj, repositories.ErrDuplicate)
+	}
+
+	row := *company
+	row.Version = 1
+	row.DeletedAt = nil
+	t.companies[row.ID] = row
+	return nil
+}
+
+func (r *CompanyRepository) find(ctx context.Context, match func(*models.Company) bool) *models.Company {
+	defer r.Store.lock(ctx)()
+	for _, c := range r.Store.data.companies {
+		if c.DeletedAt == nil && match(&c) {
+			return &c
+		}
+	}
+	return nil
+}
+
+func (r *CompanyRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	company := r.find(ctx, func(c *models.Company) bool { return c.ID == id })
+	if company == nil {
+		return nil, fmt.Errorf("company not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	return company, nil
+}
+
+func (r *CompanyRepository) FindByAPIKey(ctx context.Context, apiKey string) (*models.Company, error) {
+	company := r.find(ctx, func(c *models.Company) bool { return c.APIKey == apiKey })
+	if company == nil {
+		return nil, fmt.Errorf("company not found with API key: %w", repositories.ErrNotFound)
+	}
+	return company, nil
+}
+
+func (r *CompanyRepository) FindByCnpj(ctx context.Context, cnpj string) (*models.Company, error) {
+	company := r.find(ctx, func(c *models.Company) bool { return c.Cnpj == cnpj })
+	if company == nil {
+		return nil, fmt.Errorf("company not found with CNPJ %s: %w", cnpj, repositories.ErrNotFound)
+	}
+	return company, nil
+}
+
+func (r *CompanyRepository) FindAll(ctx context.Context, limit, offset int) ([]models.Company, error) {
+	defer r.Store.lock(ctx)()
+
+	var companies []models.Company
+	for _, c := range r.Store.data.companies {
+		if c.DeletedAt == nil {
+			companies = append(companies, c)
+		}
+	}
+	slices.SortFunc(companies, func(a, b models.Company) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	return page(companies, limit, offset), nil
+}
+
+func (r *CompanyRepository) UpdateCompany(ctx context.Context, company *models.Company) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.companies[company.ID]
+	if !ok || row.DeletedAt != nil || row.Version != company.Version {
+		return fmt.Errorf("company %s is no longer at version %d: %w", company.ID, company.Version, repositories.ErrVersionConflict)
+	}
+	if cnpjTaken(t, company.Cnpj, company.ID) {
+		return fmt.Errorf("company with CNPJ %s: %w", company.Cnpj, repositories.ErrDuplicate)
+	}
+
+	row.Name = company.Name
+	row.Cnpj = company.Cnpj
+	row.APIKey = company.APIKey
+	row.Timezone = company.Timezone
+	row.IsActive = company.IsActive
+	row.UpdatedAt = company.UpdatedAt
+	row.RateLimit = company.RateLimit
+	row.RateLimitBurst = company.RateLimitBurst
+	row.Version++
+	t.companies[row.ID] = row
+	company.Version++
+	return nil
+}
+
+func (r *CompanyRepository) DeactivateCompany(ctx context.Context, id uuid.UUID) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.companies[id]
+	if !ok || row.DeletedAt != nil {
+		return fmt.Errorf("company not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	now := time.Now()
+	row.IsActive = false
+	row.DeletedAt = &now
+	row.UpdatedAt = now
+	row.Version++
+	t.companies[id] = row
+	return nil
+}
+
+func (r *CompanyRepository) RotateAPIKey(ctx context.Context, id uuid.UUID) (string, error) {
+	newKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate new API key: %w", err)
+	}
+
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.companies[id]
+	if !ok || row.DeletedAt != nil {
+		return "", fmt.Errorf("company not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	row.APIKey = newKey
+	row.UpdatedAt = time.Now()
+	row.Version++
+	t.companies[id] = row
+	return newKey, nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories/repotest"
+)
+
+func newBackend(t *testing.T) repotest.Backend {
+	store := NewStore()
+	return repotest.Backend{
+		Companies:  &CompanyRepository{Store: store},
+		Promotions: &PromotionRepository{Store: store},
+		Audit:      &AuditRepository{Store: store},
+		Webhooks:   &WebhookRepository{Store: store},
+		Outbox:     &OutboxRepository{Store: store},
+		Tx:         store,
+	}
+}
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, newBackend)
+}
+
+func TestConcurrentTransactions(t *testing.T) {
+	b := newBackend(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = b.Tx.WithTx(ctx, func(ctx context.Context) error {
+				company := &models.Company{ID: uuid.New(), Cnpj: fmt.Sprint(i % 5), CreatedAt: now, UpdatedAt: now}
+				if err := b.Companies.CreateCompany(ctx, company); err != nil {
+					return err
+				}
+				_, err := b.Companies.FindAll(ctx, n, 0)
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	companies, err := b.Companies.FindAll(ctx, n, 0)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if len(companies) != 5 {
+		t.Fatalf("stored %d companies, want one per CNPJ", len(companies))
+	}
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"promo-api/models"
+	"promo-api/repositories"
+)
+
+type OutboxRepository struct {
+	Store *Store
+}
+
+var _ repositories.OutboxRepositoryInterface = &OutboxRepository{}
+
+// Enqueue stores the event. Call it with the context of the transaction that
+// makes the change, so the event is committed or rolled back with it.
+func (r *OutboxRepository) Enqueue(ctx context.Context, event models.Event) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	for _, message := range t.outbox {
+		if message.EventID == event.ID {
+			return fmt.Errorf("failed to enqueue event %s: %w", event.Type, repositories.ErrDuplicate)
+		}
+	}
+	t.outboxSeq++
+	t.outbox = append(t.outbox, models.OutboxMessage{
+		Position:      t.outboxSeq,
+		EventID:       event.ID,
+		Type:          event.Type,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		CompanyID:     event.CompanyID,
+		Data:          event.Data,
+		OccurredAt:    event.OccurredAt,
+	})
+	return nil
+}
+
+// FindPending returns the messages after position after that are neither
+// published nor dead-lettered, by position.
+func (r *OutboxRepository) FindPending(ctx context.Context, after int64, limit int) ([]models.OutboxMessage, error) {
+	defer r.Store.lock(ctx)()
+
+	var messages []models.OutboxMessage
+	for _, message := range r.Store.data.outbox {
+		if message.Position > after && message.PublishedAt == nil && message.DeadLetteredAt == nil {
+			messages = append(messages, message)
+		}
+	}
+	return page(messages, limit, 0), nil
+}
+
+// update applies fn to the message at position.
+func (r *OutboxRepository) update(ctx context.Context, position int64, fn func(*models.OutboxMessage)) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	i := slices.IndexFunc(t.outbox, func(m models.OutboxMessage) bool { return m.Position == position })
+	if i < 0 {
+		return fmt.Errorf("outbox message %d: %w", position, repositories.ErrNotFound)
+	}
+	fn(&t.outbox[i])
+	return nil
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, position int64, at time.Time) error {
+	return r.update(ctx, position, func(m *models.OutboxMessage) {
+		m.PublishedAt = &at
+		m.Attempts++
+		m.LastError = nil
+	})
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, position int64, reason string) error {
+	return r.update(ctx, position, func(m *models.OutboxMessage) {
+		m.Attempts++
+		m.LastError = &reason
+	})
+}
+
+// MarkDead records a last failed attempt and dead-letters the message, which
+// is no longer pending.
+func (r *OutboxRepository) MarkDead(ctx context.Context, position int64, reason string, at time.Time) error {
+	return r.update(ctx, position, func(m *models.OutboxMessage) {
+		m.Attempts++
+		m.LastError = &reason
+		m.DeadLetteredAt = &at
+	})
+}
+
+// PurgePublished deletes messages published before the given instant.
+func (r *OutboxRepository) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	n := len(t.outbox)
+	t.outbox = slices.DeleteFunc(t.outbox, func(m models.OutboxMessage) bool {
+		return m.PublishedAt != nil && m.PublishedAt.Before(before)
+	})
+	return int64(n - len(t.outbox)), nil
+}
+
+// Lock takes the relay lock. ok is false when another relay holds it.
+func (r *OutboxRepository) Lock(ctx context.Context) (func(), bool, error) {
+	if !r.Store.relayMu.TryLock() {
+		return nil, false, nil
+	}
+	return r.Store.relayMu.Unlock, true, nil
+}
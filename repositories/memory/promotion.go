@@ -0,0 +1,340 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+)
+
+type PromotionRepository struct {
+	Store *Store
+}
+
+var _ repositories.PromotionRepositoryInterface = &PromotionRepository{}
+
+// computedStatus derives the lifecycle status of a promotion at the given
+// instant. It mirrors the SQL in repositories.computedStatus.
+func computedStatus(p *models.Promotion, at time.Time) string {
+	switch {
+	case p.Status == models.PromotionStatusDraft,
+		p.Status == models.PromotionStatusPaused,
+		p.Status == models.PromotionStatusArchived:
+		return p.Status
+	case p.EndDate.Before(at):
+		return models.PromotionStatusExpired
+	case p.MaxUsage != nil && p.CurrentUsage >= *p.MaxUsage:
+		return models.PromotionStatusExhausted
+	case p.StartDate.After(at):
+		return models.PromotionStatusScheduled
+	default:
+		return models.PromotionStatusActive
+	}
+}
+
+func byCreatedAt(a, b models.Promotion) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return compareIDs(a.ID, b.ID)
+}
+
+func byStartDate(a, b models.Promotion) int {
+	if c := a.StartDate.Compare(b.StartDate); c != 0 {
+		return c
+	}
+	return compareIDs(a.ID, b.ID)
+}
+
+// selectPromotions returns the promotions matching match, sorted by cmp.
+func (r *PromotionRepository) selectPromotions(ctx context.Context, match func(*models.Promotion) bool, cmp func(a, b models.Promotion) int) []models.Promotion {
+	defer r.Store.lock(ctx)()
+
+	var promotions []models.Promotion
+	for _, p := range r.Store.data.promotions {
+		if match(&p) {
+			promotions = append(promotions, p.Clone())
+		}
+	}
+	slices.SortFunc(promotions, cmp)
+	return promotions
+}
+
+func (r *PromotionRepository) CreatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	if _, ok := t.promotions[promotion.ID]; ok {
+		return fmt.Errorf("failed to create promotion: duplicate ID %s", promotion.ID)
+	}
+	if owner := promotion.CompanyID; owner != nil {
+		if _, ok := t.companies[*owner]; !ok {
+			return fmt.Errorf("failed to create promotion: company %s does not exist", *owner)
+		}
+	}
+	row := promotion.Clone()
+	row.Version = 1
+	row.DeletedAt = nil
+	t.promotions[row.ID] = row
+	return nil
+}
+
+func (r *PromotionRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
+	return r.findByID(ctx, id, false)
+}
+
+// FindByIDIncludingDeleted also returns soft-deleted promotions.
+func (r *PromotionRepository) FindByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
+	return r.findByID(ctx, id, true)
+}
+
+func (r *PromotionRepository) findByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*models.Promotion, error) {
+	defer r.Store.lock(ctx)()
+	row, ok := r.Store.data.promotions[id]
+	if !ok || (row.DeletedAt != nil && !includeDeleted) {
+		return nil, fmt.Errorf("promotion not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	promotion := row.Clone()
+	return &promotion, nil
+}
+
+func (r *PromotionRepository) FindAll(ctx context.Context, includeDeleted bool, limit, offset int) ([]models.Promotion, error) {
+	promotions := r.selectPromotions(ctx, func(p *models.Promotion) bool {
+		return includeDeleted || p.DeletedAt == nil
+	}, byCreatedAt)
+	return page(promotions, limit, offset), nil
+}
+
+// FindActiveAt returns promotions whose lifecycle status is active at the
+// given instant. Recurring schedules are evaluated by the caller.
+func (r *PromotionRepository) FindActiveAt(ctx context.Context, at time.Time, limit, offset int) ([]models.Promotion, error) {
+	return r.FindByStatus(ctx, models.PromotionStatusActive, at, limit, offset)
+}
+
+// FindByStatus returns promotions whose lifecycle status at the given
+// instant matches status.
+func (r *PromotionRepository) FindByStatus(ctx context.Context, status string, at time.Time, limit, offset int) ([]models.Promotion, error) {
+	promotions := r.selectPromotions(ctx, func(p *models.Promotion) bool {
+		return p.DeletedAt == nil && computedStatus(p, at) == status
+	}, byStartDate)
+	return page(promotions, limit, offset), nil
+}
+
+// FindStatusChanges returns promotions whose stored status no longer matches
+// their lifecycle status at the given instant.
+func (r *PromotionRepository) FindStatusChanges(ctx context.Context, at time.Time, limit int) ([]models.Promotion, error) {
+	promotions := r.selectPromotions(ctx, func(p *models.Promotion) bool {
+		return p.DeletedAt == nil && p.Status != computedStatus(p, at)
+	}, func(a, b models.Promotion) int { return compareIDs(a.ID, b.ID) })
+	return page(promotions, limit, 0), nil
+}
+
+// UpdateStatus moves a promotion from one stored status to another. It
+// reports false when the stored status was no longer from.
+func (r *PromotionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, from, to string, at time.Time) (bool, error) {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.promotions[id]
+	if !ok || row.DeletedAt != nil || row.Status != from {
+		return false, nil
+	}
+	row.Status = to
+	row.IsActive = to == models.PromotionStatusActive
+	row.UpdatedAt = at
+	row.Version++
+	t.promotions[id] = row
+	return true, nil
+}
+
+// IncrementUsage counts one redemption of a promotion. It reports false,
+// changing nothing, when the promotion has already reached its max_usage.
+func (r *PromotionRepository) IncrementUsage(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.promotions[id]
+	if !ok || row.DeletedAt != nil || (row.MaxUsage != nil && row.CurrentUsage >= *row.MaxUsage) {
+		return false, nil
+	}
+	row.CurrentUsage++
+	row.UpdatedAt = at
+	row.Version++
+	t.promotions[id] = row
+	return true, nil
+}
+
+func (r *PromotionRepository) RecordTransition(ctx context.Context, transition *models.PromotionTransition) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	if _, ok := t.promotions[transition.PromotionID]; !ok {
+		return fmt.Errorf("failed to record transition of promotion %s: promotion does not exist", transition.PromotionID)
+	}
+	t.transitions = append(t.transitions, *transition)
+	return nil
+}
+
+func (r *PromotionRepository) FindTransitions(ctx context.Context, promotionID uuid.UUID) ([]models.PromotionTransition, error) {
+	defer r.Store.lock(ctx)()
+
+	transitions := []models.PromotionTransition{}
+	for _, transition := range r.Store.data.transitions {
+		if transition.PromotionID == promotionID {
+			transitions = append(transitions, transition)
+		}
+	}
+	slices.SortFunc(transitions, func(a, b models.PromotionTransition) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	return transitions, nil
+}
+
+// CreateRevision stores a snapshot as the promotion's next revision and sets
+// revision.Revision to its number.
+func (r *PromotionRepository) CreateRevision(ctx context.Context, revision *models.PromotionRevision) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	if _, ok := t.promotions[revision.PromotionID]; !ok {
+		return fmt.Errorf("failed to create revision of promotion %s: promotion does not exist", revision.PromotionID)
+	}
+	next := 1
+	for _, existing := range t.revisions {
+		if existing.PromotionID == revision.PromotionID && existing.Revision >= next {
+			next = existing.Revision + 1
+		}
+	}
+	revision.Revision = next
+	t.revisions = append(t.revisions, *revision)
+	return nil
+}
+
+func (r *PromotionRepository) FindRevisions(ctx context.Context, promotionID uuid.UUID) ([]models.PromotionRevision, error) {
+	defer r.Store.lock(ctx)()
+
+	revisions := []models.PromotionRevision{}
+	for _, revision := range r.Store.data.revisions {
+		if revision.PromotionID == promotionID {
+			revisions = append(revisions, revision)
+		}
+	}
+	slices.SortFunc(revisions, func(a, b models.PromotionRevision) int { return a.Revision - b.Revision })
+	return revisions, nil
+}
+
+func (r *PromotionRepository) FindRevision(ctx context.Context, promotionID uuid.UUID, revision int) (*models.PromotionRevision, error) {
+	defer r.Store.lock(ctx)()
+
+	for _, existing := range r.Store.data.revisions {
+		if existing.PromotionID == promotionID && existing.Revision == revision {
+			return &existing, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d of promotion %s not found: %w", revision, promotionID, repositories.ErrNotFound)
+}
+
+// FindRevisionAsOf returns the revision that was current at the given instant.
+func (r *PromotionRepository) FindRevisionAsOf(ctx context.Context, promotionID uuid.UUID, at time.Time) (*models.PromotionRevision, error) {
+	defer r.Store.lock(ctx)()
+
+	var found *models.PromotionRevision
+	for _, existing := range r.Store.data.revisions {
+		if existing.PromotionID == promotionID && !existing.CreatedAt.After(at) &&
+			(found == nil || existing.Revision > found.Revision) {
+			found = &existing
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("revision of promotion %s as of %s not found: %w", promotionID, at.Format(time.RFC3339), repositories.ErrNotFound)
+	}
+	return found, nil
+}
+
+// FindByCoupon matches coupon codes containing coupon, ignoring case, like
+// ILIKE '%coupon%'.
+func (r *PromotionRepository) FindByCoupon(ctx context.Context, coupon string) ([]models.Promotion, error) {
+	coupon = strings.ToLower(coupon)
+	promotions := r.selectPromotions(ctx, func(p *models.Promotion) bool {
+		return p.DeletedAt == nil && p.CouponCode != nil && strings.Contains(strings.ToLower(*p.CouponCode), coupon)
+	}, byCreatedAt)
+	return promotions, nil
+}
+
+func (r *PromotionRepository) UpdatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.promotions[promotion.ID]
+	if !ok || row.DeletedAt != nil || row.Version != promotion.Version {
+		return fmt.Errorf("promotion %s is no longer at version %d: %w", promotion.ID, promotion.Version, repositories.ErrVersionConflict)
+	}
+
+	updated := promotion.Clone()
+	updated.CompanyID = row.CompanyID
+	updated.CreatedAt = row.CreatedAt
+	updated.DeletedAt = nil
+	updated.Version = row.Version + 1
+	t.promotions[row.ID] = updated
+	promotion.Version++
+	return nil
+}
+
+// DeletePromotion soft-deletes a promotion; PurgeDeleted removes it later.
+func (r *PromotionRepository) DeletePromotion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.promotions[id]
+	if !ok || row.DeletedAt != nil {
+		return fmt.Errorf("promotion not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	row.DeletedAt = &at
+	row.UpdatedAt = at
+	row.Version++
+	t.promotions[id] = row
+	return nil
+}
+
+func (r *PromotionRepository) RestorePromotion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.promotions[id]
+	if !ok || row.DeletedAt == nil {
+		return fmt.Errorf("deleted promotion not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	row.DeletedAt = nil
+	row.UpdatedAt = at
+	row.Version++
+	t.promotions[id] = row
+	return nil
+}
+
+// PurgeDeleted permanently removes promotions soft-deleted before the given
+// instant, with their transitions and revisions, and returns how many were
+// removed.
+func (r *PromotionRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	purged := map[uuid.UUID]bool{}
+	for id, p := range t.promotions {
+		if p.DeletedAt != nil && p.DeletedAt.Before(before) {
+			purged[id] = true
+			delete(t.promotions, id)
+		}
+	}
+	t.transitions = slices.DeleteFunc(t.transitions, func(tr models.PromotionTransition) bool { return purged[tr.PromotionID] })
+	t.revisions = slices.DeleteFunc(t.revisions, func(rev models.PromotionRevision) bool { return purged[rev.PromotionID] })
+	return int64(len(purged)), nil
+}
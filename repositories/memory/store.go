@@ -0,0 +1,134 @@
+// Package memory implements the repository interfaces in process memory,
+// with the same semantics as the PostgreSQL repositories: soft deletes,
+// filtering, ordering, pagination, optimistic versions and uniqueness. It
+// backs STORAGE=memory and the repository contract tests.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+)
+
+// tables holds the rows of every repository sharing a Store.
+type tables struct {
+	companies   map[uuid.UUID]models.Company
+	promotions  map[uuid.UUID]models.Promotion
+	transitions []models.PromotionTransition
+	revisions   []models.PromotionRevision
+	audit       []models.AuditEvent
+	webhooks    map[uuid.UUID]models.Webhook
+	deliveries  map[uuid.UUID]models.WebhookDelivery
+	outbox      []models.OutboxMessage
+	outboxSeq   int64
+}
+
+func (t *tables) clone() *tables {
+	return &tables{
+		companies:   maps.Clone(t.companies),
+		promotions:  maps.Clone(t.promotions),
+		transitions: slices.Clone(t.transitions),
+		revisions:   slices.Clone(t.revisions),
+		audit:       slices.Clone(t.audit),
+		webhooks:    maps.Clone(t.webhooks),
+		deliveries:  maps.Clone(t.deliveries),
+		outbox:      slices.Clone(t.outbox),
+		outboxSeq:   t.outboxSeq,
+	}
+}
+
+type txKey struct{}
+
+// Store is the in-memory database shared by the repositories and the
+// Transactor. Transactions are serialized: WithTx holds the store lock for
+// the whole transaction and restores a snapshot when it fails, so other
+// callers never observe uncommitted rows.
+type Store struct {
+	mu      sync.Mutex
+	relayMu sync.Mutex
+	data    *tables
+}
+
+var _ repositories.Transactor = &Store{}
+
+func NewStore() *Store {
+	return &Store{data: &tables{
+		companies:  map[uuid.UUID]models.Company{},
+		promotions: map[uuid.UUID]models.Promotion{},
+		webhooks:   map[uuid.UUID]models.Webhook{},
+		deliveries: map[uuid.UUID]models.WebhookDelivery{},
+	}}
+}
+
+func (s *Store) inTx(ctx context.Context) bool {
+	store, _ := ctx.Value(txKey{}).(*Store)
+	return store == s
+}
+
+// lock takes the store lock for one repository call, unless ctx belongs to a
+// transaction of this store, which already holds it.
+func (s *Store) lock(ctx context.Context) (unlock func()) {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// WithTx commits when fn succeeds and rolls back when it fails or panics.
+// Nested calls behave like savepoints: a failing nested call only undoes its
+// own writes.
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !s.inTx(ctx) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ctx = context.WithValue(ctx, txKey{}, s)
+	}
+
+	saved := s.data.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			s.data = saved
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		s.data = saved
+		return err
+	}
+	return nil
+}
+
+// page applies LIMIT and OFFSET to sorted rows. Like sqlx.Select into a nil
+// slice, it returns nil when no row is left.
+func page[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) || limit <= 0 {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// compareIDs orders UUIDs like PostgreSQL does.
+func compareIDs(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+)
+
+type WebhookRepository struct {
+	Store *Store
+}
+
+var _ repositories.WebhookRepositoryInterface = &WebhookRepository{}
+
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	if _, ok := t.webhooks[webhook.ID]; ok {
+		return fmt.Errorf("failed to create webhook: duplicate ID %s", webhook.ID)
+	}
+	if _, ok := t.companies[webhook.CompanyID]; !ok {
+		return fmt.Errorf("failed to create webhook: company %s does not exist", webhook.CompanyID)
+	}
+	row := *webhook
+	row.EventTypes = slices.Clone(webhook.EventTypes)
+	if row.EventTypes == nil {
+		row.EventTypes = []string{}
+	}
+	t.webhooks[row.ID] = row
+	return nil
+}
+
+func (r *WebhookRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	defer r.Store.lock(ctx)()
+	webhook, ok := r.Store.data.webhooks[id]
+	if !ok {
+		return nil, fmt.Errorf("webhook not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) FindByCompany(ctx context.Context, companyID uuid.UUID) ([]models.Webhook, error) {
+	defer r.Store.lock(ctx)()
+
+	webhooks := []models.Webhook{}
+	for _, webhook := range r.Store.data.webhooks {
+		if webhook.CompanyID == companyID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	slices.SortFunc(webhooks, func(a, b models.Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	return webhooks, nil
+}
+
+// FindSubscribed returns the active webhooks of the company that receive
+// events of the given type.
+func (r *WebhookRepository) FindSubscribed(ctx context.Context, companyID uuid.UUID, eventType string) ([]models.Webhook, error) {
+	defer r.Store.lock(ctx)()
+
+	var webhooks []models.Webhook
+	for _, webhook := range r.Store.data.webhooks {
+		if webhook.CompanyID == companyID && webhook.IsActive && webhook.Subscribes(eventType) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	if _, ok := t.webhooks[id]; !ok {
+		return fmt.Errorf("webhook %s: %w", id, repositories.ErrNotFound)
+	}
+	delete(t.webhooks, id)
+	for deliveryID, delivery := range t.deliveries {
+		if delivery.WebhookID == id {
+			delete(t.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// CreateDelivery enqueues a delivery. Enqueuing the same event for the same
+// webhook twice is a no-op.
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	if _, ok := t.webhooks[delivery.WebhookID]; !ok {
+		return fmt.Errorf("failed to create webhook delivery: webhook %s does not exist", delivery.WebhookID)
+	}
+	for _, existing := range t.deliveries {
+		if existing.WebhookID == delivery.WebhookID && existing.EventID == delivery.EventID {
+			return nil
+		}
+	}
+	t.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *WebhookRepository) FindDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	defer r.Store.lock(ctx)()
+	delivery, ok := r.Store.data.deliveries[id]
+	if !ok {
+		return nil, fmt.Errorf("webhook delivery not found with ID %s: %w", id, repositories.ErrNotFound)
+	}
+	return &delivery, nil
+}
+
+// selectDeliveries returns the deliveries matching match, sorted by cmp.
+func (r *WebhookRepository) selectDeliveries(ctx context.Context, match func(*models.WebhookDelivery) bool, cmp func(a, b models.WebhookDelivery) int) []models.WebhookDelivery {
+	defer r.Store.lock(ctx)()
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range r.Store.data.deliveries {
+		if match(&delivery) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	slices.SortFunc(deliveries, cmp)
+	return deliveries
+}
+
+// FindDeliveries lists the deliveries of a webhook, newest first. An empty
+// status matches every delivery.
+func (r *WebhookRepository) FindDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	deliveries := r.selectDeliveries(ctx, func(d *models.WebhookDelivery) bool {
+		return d.WebhookID == webhookID && (status == "" || d.Status == status)
+	}, func(a, b models.WebhookDelivery) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	return append([]models.WebhookDelivery{}, page(deliveries, limit, offset)...), nil
+}
+
+// FindDeadDeliveries lists the dead-lettered deliveries across the webhooks
+// of a company, newest first.
+func (r *WebhookRepository) FindDeadDeliveries(ctx context.Context, companyID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error) {
+	owned := map[uuid.UUID]bool{}
+	webhooks, _ := r.FindByCompany(ctx, companyID)
+	for _, webhook := range webhooks {
+		owned[webhook.ID] = true
+	}
+
+	deliveries := r.selectDeliveries(ctx, func(d *models.WebhookDelivery) bool {
+		return owned[d.WebhookID] && d.Status == models.WebhookDeliveryDead
+	}, func(a, b models.WebhookDelivery) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	return append([]models.WebhookDelivery{}, page(deliveries, limit, offset)...), nil
+}
+
+// ClaimDueDeliveries returns pending deliveries whose next attempt is due at
+// the given instant, oldest first, and postpones their next attempt to
+// until, so no other dispatcher claims them meanwhile.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, at, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range t.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(at) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	slices.SortFunc(deliveries, func(a, b models.WebhookDelivery) int {
+		if c := a.NextAttemptAt.Compare(*b.NextAttemptAt); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	deliveries = page(deliveries, limit, 0)
+	for i := range deliveries {
+		row := t.deliveries[deliveries[i].ID]
+		row.NextAttemptAt = clonePtr(&until)
+		t.deliveries[row.ID] = row
+		deliveries[i].NextAttemptAt = clonePtr(&until)
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	defer r.Store.lock(ctx)()
+	t := r.Store.data
+
+	row, ok := t.deliveries[delivery.ID]
+	if !ok {
+		return fmt.Errorf("webhook delivery %s: %w", delivery.ID, repositories.ErrNotFound)
+	}
+	row.Status = delivery.Status
+	row.Attempts = delivery.Attempts
+	row.NextAttemptAt = clonePtr(delivery.NextAttemptAt)
+	row.LastStatusCode = clonePtr(delivery.LastStatusCode)
+	row.LastError = clonePtr(delivery.LastError)
+	row.DeliveredAt = clonePtr(delivery.DeliveredAt)
+	row.UpdatedAt = delivery.UpdatedAt
+	t.deliveries[row.ID] = row
+	return nil
+}
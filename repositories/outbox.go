@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"promo-api/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// outboxLockKey is the advisory lock held by the instance relaying the
+// outbox, so only one relay publishes at a time and per-aggregate order
+// holds across instances.
+const outboxLockKey = 0x6f7574626f78
+
+type OutboxRepositoryInterface interface {
+	Enqueue(ctx context.Context, event models.Event) error
+	FindPending(ctx context.Context, after int64, limit int) ([]models.OutboxMessage, error)
+	MarkPublished(ctx context.Context, position int64, at time.Time) error
+	MarkFailed(ctx context.Context, position int64, reason string) error
+	MarkDead(ctx context.Context, position int64, reason string, at time.Time) error
+	PurgePublished(ctx context.Context, before time.Time) (int64, error)
+	Lock(ctx context.Context) (unlock func(), ok bool, err error)
+}
+
+type OutboxRepository struct {
+	DB *sqlx.DB
+}
+
+var _ OutboxRepositoryInterface = &OutboxRepository{}
+
+func (r *OutboxRepository) db(ctx context.Context) DBTX {
+	return traced(executor(ctx, r.DB))
+}
+
+// Enqueue stores the event. Call it with the context of the transaction that
+// makes the change, so the event is committed or rolled back with it.
+func (r *OutboxRepository) Enqueue(ctx context.Context, event models.Event) error {
+	query := `
+		INSERT INTO outbox (
+			event_id, type, aggregate_type, aggregate_id, company_id, data, occurred_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7
+		)`
+	_, err := r.db(ctx).ExecContext(ctx, query,
+		event.ID, event.Type, event.AggregateType, event.AggregateID, event.CompanyID, string(event.Data), event.OccurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue event %s: %w", event.Type, err)
+	}
+	return nil
+}
+
+// FindPending returns the messages after position after that are neither
+// published nor dead-lettered, by position.
+func (r *OutboxRepository) FindPending(ctx context.Context, after int64, limit int) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	query := `
+		SELECT * FROM outbox
+		WHERE position > $1 AND published_at IS NULL AND dead_lettered_at IS NULL
+		ORDER BY position LIMIT $2`
+	err := r.db(ctx).SelectContext(ctx, &messages, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending outbox messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, position int64, at time.Time) error {
+	query := "UPDATE outbox SET published_at = $1, attempts = attempts + 1, last_error = NULL WHERE position = $2"
+	result, err := r.db(ctx).ExecContext(ctx, query, at, position)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message %d as published: %w", position, err)
+	}
+	return expectAffected(result, fmt.Sprintf("outbox message %d", position))
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, position int64, reason string) error {
+	query := "UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE position = $2"
+	result, err := r.db(ctx).ExecContext(ctx, query, reason, position)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox message %d failure: %w", position, err)
+	}
+	return expectAffected(result, fmt.Sprintf("outbox message %d", position))
+}
+
+// MarkDead records a last failed attempt and dead-letters the message, which
+// is no longer pending.
+func (r *OutboxRepository) MarkDead(ctx context.Context, position int64, reason string, at time.Time) error {
+	query := "UPDATE outbox SET attempts = attempts + 1, last_error = $1, dead_lettered_at = $2 WHERE position = $3"
+	result, err := r.db(ctx).ExecContext(ctx, query, reason, at, position)
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter outbox message %d: %w", position, err)
+	}
+	return expectAffected(result, fmt.Sprintf("outbox message %d", position))
+}
+
+// PurgePublished deletes messages published before the given instant.
+func (r *OutboxRepository) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	query := "DELETE FROM outbox WHERE published_at < $1"
+	result, err := r.db(ctx).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge published outbox messages: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Lock takes the relay advisory lock on a dedicated connection. ok is false
+// when another session holds it. unlock releases the lock and the connection.
+func (r *OutboxRepository) Lock(ctx context.Context) (func(), bool, error) {
+	conn, err := r.DB.Connx(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire outbox lock connection: %w", err)
+	}
+
+	var ok bool
+	if err := conn.QueryRowxContext(ctx, "SELECT pg_try_advisory_lock($1)", outboxLockKey).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire outbox lock: %w", err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", outboxLockKey); err != nil {
+			slog.ErrorContext(ctx, "Failed to release outbox lock", "error", err)
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
@@ -2,7 +2,10 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"promo-api/models"
 
@@ -10,66 +13,293 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// computedStatus derives the lifecycle status of a row at the instant bound
+// to $1. It mirrors services.PromotionStatus.
+const computedStatus = `
+	CASE
+		WHEN status IN ('draft', 'paused', 'archived') THEN status
+		WHEN end_date < $1 THEN 'expired'
+		WHEN max_usage IS NOT NULL AND current_usage >= max_usage THEN 'exhausted'
+		WHEN start_date > $1 THEN 'scheduled'
+		ELSE 'active'
+	END`
+
 type PromotionRepositoryInterface interface {
 	CreatePromotion(ctx context.Context, promotion *models.Promotion) error
 	FindByID(ctx context.Context, id uuid.UUID) (*models.Promotion, error)
-	FindAll(ctx context.Context, limit, offset int) ([]models.Promotion, error)
+	FindByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (*models.Promotion, error)
+	FindAll(ctx context.Context, includeDeleted bool, limit, offset int) ([]models.Promotion, error)
+	FindActiveAt(ctx context.Context, at time.Time, limit, offset int) ([]models.Promotion, error)
+	FindByStatus(ctx context.Context, status string, at time.Time, limit, offset int) ([]models.Promotion, error)
+	FindStatusChanges(ctx context.Context, at time.Time, limit int) ([]models.Promotion, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, from, to string, at time.Time) (bool, error)
+	IncrementUsage(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	RecordTransition(ctx context.Context, transition *models.PromotionTransition) error
+	FindTransitions(ctx context.Context, promotionID uuid.UUID) ([]models.PromotionTransition, error)
+	CreateRevision(ctx context.Context, revision *models.PromotionRevision) error
+	FindRevisions(ctx context.Context, promotionID uuid.UUID) ([]models.PromotionRevision, error)
+	FindRevision(ctx context.Context, promotionID uuid.UUID, revision int) (*models.PromotionRevision, error)
+	FindRevisionAsOf(ctx context.Context, promotionID uuid.UUID, at time.Time) (*models.PromotionRevision, error)
 	FindByCoupon(ctx context.Context, coupon string) ([]models.Promotion, error)
 	UpdatePromotion(ctx context.Context, promotion *models.Promotion) error
-	DeletePromotion(ctx context.Context, id uuid.UUID) error
+	DeletePromotion(ctx context.Context, id uuid.UUID, at time.Time) error
+	RestorePromotion(ctx context.Context, id uuid.UUID, at time.Time) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
 }
 
 type PromotionRepository struct {
 	DB *sqlx.DB
+	// Replicas, when set, serve the catalog reads that tolerate replication
+	// lag. Lifecycle scans used by the workers stay on the primary.
+	Replicas *Replicas
 }
 
 var _ PromotionRepositoryInterface = &PromotionRepository{}
 
+func (r *PromotionRepository) db(ctx context.Context) DBTX {
+	return traced(executor(ctx, r.DB))
+}
+
+func (r *PromotionRepository) reader(ctx context.Context) DBTX {
+	return traced(reader(ctx, r.DB, r.Replicas))
+}
+
 func (r *PromotionRepository) CreatePromotion(ctx context.Context, promotion *models.Promotion) error {
 	query := `
 		INSERT INTO promotions (
-			id, title, description, discount_type, discount_value, start_date, end_date,
-			minimum_purchase_amount, max_usage, current_usage, coupon_code, is_active, created_at, updated_at
+			id, title, description, discount_type, discount_value, start_date, end_date, schedule,
+			minimum_purchase_amount, max_usage, current_usage, coupon_code, status, is_active, created_at, updated_at,
+			company_id
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
 		)`
-	_, err := r.DB.ExecContext(ctx, query,
+	_, err := r.db(ctx).ExecContext(ctx, query,
 		promotion.ID, promotion.Title, promotion.Description, promotion.DiscountType, promotion.DiscountValue,
-		promotion.StartDate, promotion.EndDate, promotion.MinimumPurchaseAmount, promotion.MaxUsage,
-		promotion.CurrentUsage, promotion.CouponCode, promotion.IsActive, promotion.CreatedAt, promotion.UpdatedAt,
+		promotion.StartDate, promotion.EndDate, promotion.Schedule, promotion.MinimumPurchaseAmount, promotion.MaxUsage,
+		promotion.CurrentUsage, promotion.CouponCode, promotion.Status, promotion.IsActive, promotion.CreatedAt, promotion.UpdatedAt,
+		promotion.CompanyID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create promotion: %w", err)
 	}
-	return nil
+	return notifyChange(ctx, r.db(ctx), PromotionChangesChannel, promotion.ID)
 }
 
 func (r *PromotionRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
+	return r.findByID(ctx, "SELECT * FROM promotions WHERE id = $1 AND deleted_at IS NULL", id)
+}
+
+// FindByIDIncludingDeleted also returns soft-deleted promotions.
+func (r *PromotionRepository) FindByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
+	return r.findByID(ctx, "SELECT * FROM promotions WHERE id = $1", id)
+}
+
+func (r *PromotionRepository) findByID(ctx context.Context, query string, id uuid.UUID) (*models.Promotion, error) {
 	var promotion models.Promotion
-	query := "SELECT * FROM promotions WHERE id = $1"
-	err := r.DB.GetContext(ctx, &promotion, query, id)
+	err := r.reader(ctx).GetContext(ctx, &promotion, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("promotion not found with ID %s: %w", id, ErrNotFound)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("promotion not found with ID %s: %w", id, err)
+		return nil, fmt.Errorf("failed to fetch promotion with ID %s: %w", id, err)
 	}
 	return &promotion, nil
 }
 
-func (r *PromotionRepository) FindAll(ctx context.Context, limit, offset int) ([]models.Promotion, error) {
+func (r *PromotionRepository) FindAll(ctx context.Context, includeDeleted bool, limit, offset int) ([]models.Promotion, error) {
 	var promotions []models.Promotion
-	query := "SELECT * FROM promotions LIMIT $1 OFFSET $2"
-	err := r.DB.SelectContext(ctx, &promotions, query, limit, offset)
+	query := "SELECT * FROM promotions WHERE deleted_at IS NULL ORDER BY created_at, id LIMIT $1 OFFSET $2"
+	if includeDeleted {
+		query = "SELECT * FROM promotions ORDER BY created_at, id LIMIT $1 OFFSET $2"
+	}
+	err := r.reader(ctx).SelectContext(ctx, &promotions, query, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch promotions: %w", err)
 	}
 	return promotions, nil
 }
 
+// FindActiveAt returns promotions whose lifecycle status is active at the
+// given instant. Recurring schedules are evaluated by the caller.
+func (r *PromotionRepository) FindActiveAt(ctx context.Context, at time.Time, limit, offset int) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	query := `
+		SELECT * FROM promotions
+		WHERE deleted_at IS NULL AND ` + computedStatus + ` = 'active'
+		ORDER BY start_date, id
+		LIMIT $2 OFFSET $3`
+	err := r.reader(ctx).SelectContext(ctx, &promotions, query, at, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch promotions active at %s: %w", at.Format(time.RFC3339), err)
+	}
+	return promotions, nil
+}
+
+// FindByStatus returns promotions whose lifecycle status at the given
+// instant matches status.
+func (r *PromotionRepository) FindByStatus(ctx context.Context, status string, at time.Time, limit, offset int) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	query := `
+		SELECT * FROM promotions
+		WHERE deleted_at IS NULL AND ` + computedStatus + ` = $2
+		ORDER BY start_date, id
+		LIMIT $3 OFFSET $4`
+	err := r.reader(ctx).SelectContext(ctx, &promotions, query, at, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch promotions with status %s: %w", status, err)
+	}
+	return promotions, nil
+}
+
+// FindStatusChanges returns promotions whose stored status no longer matches
+// their lifecycle status at the given instant.
+func (r *PromotionRepository) FindStatusChanges(ctx context.Context, at time.Time, limit int) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	query := `
+		SELECT * FROM promotions
+		WHERE deleted_at IS NULL AND status <> ` + computedStatus + `
+		ORDER BY id
+		LIMIT $2`
+	err := r.db(ctx).SelectContext(ctx, &promotions, query, at, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch promotion status changes: %w", err)
+	}
+	return promotions, nil
+}
+
+// UpdateStatus moves a promotion from one stored status to another. It
+// reports false when the stored status was no longer from.
+func (r *PromotionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, from, to string, at time.Time) (bool, error) {
+	query := `
+		UPDATE promotions
+		SET status = $1, is_active = ($1 = 'active'), updated_at = $2, version = version + 1
+		WHERE id = $3 AND status = $4 AND deleted_at IS NULL`
+	result, err := r.db(ctx).ExecContext(ctx, query, to, at, id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update status of promotion %s: %w", id, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update status of promotion %s: %w", id, err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+	return true, notifyChange(ctx, r.db(ctx), PromotionChangesChannel, id)
+}
+
+// IncrementUsage counts one redemption of a promotion. It reports false,
+// changing nothing, when the promotion has already reached its max_usage, so
+// concurrent redemptions cannot exceed it.
+func (r *PromotionRepository) IncrementUsage(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	query := `
+		UPDATE promotions
+		SET current_usage = current_usage + 1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND (max_usage IS NULL OR current_usage < max_usage)`
+	result, err := r.db(ctx).ExecContext(ctx, query, at, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to count usage of promotion %s: %w", id, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to count usage of promotion %s: %w", id, err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+	return true, notifyChange(ctx, r.db(ctx), PromotionChangesChannel, id)
+}
+
+func (r *PromotionRepository) RecordTransition(ctx context.Context, transition *models.PromotionTransition) error {
+	query := `
+		INSERT INTO promotion_transitions (
+			id, promotion_id, action, from_status, to_status, actor, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7
+		)`
+	_, err := r.db(ctx).ExecContext(ctx, query,
+		transition.ID, transition.PromotionID, transition.Action, transition.FromStatus,
+		transition.ToStatus, transition.Actor, transition.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record transition of promotion %s: %w", transition.PromotionID, err)
+	}
+	return nil
+}
+
+func (r *PromotionRepository) FindTransitions(ctx context.Context, promotionID uuid.UUID) ([]models.PromotionTransition, error) {
+	transitions := []models.PromotionTransition{}
+	query := "SELECT * FROM promotion_transitions WHERE promotion_id = $1 ORDER BY created_at, id"
+	err := r.reader(ctx).SelectContext(ctx, &transitions, query, promotionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transitions of promotion %s: %w", promotionID, err)
+	}
+	return transitions, nil
+}
+
+// CreateRevision stores a snapshot as the promotion's next revision and sets
+// revision.Revision to its number.
+func (r *PromotionRepository) CreateRevision(ctx context.Context, revision *models.PromotionRevision) error {
+	query := `
+		INSERT INTO promotion_revisions (
+			id, promotion_id, revision, snapshot, actor, created_at
+		)
+		SELECT $1, $2, COALESCE(MAX(revision), 0) + 1, $3, $4, $5
+		FROM promotion_revisions WHERE promotion_id = $2
+		RETURNING revision`
+	err := r.db(ctx).QueryRowxContext(ctx, query,
+		revision.ID, revision.PromotionID, string(revision.Snapshot), revision.Actor, revision.CreatedAt,
+	).Scan(&revision.Revision)
+	if err != nil {
+		return fmt.Errorf("failed to create revision of promotion %s: %w", revision.PromotionID, err)
+	}
+	return nil
+}
+
+func (r *PromotionRepository) FindRevisions(ctx context.Context, promotionID uuid.UUID) ([]models.PromotionRevision, error) {
+	revisions := []models.PromotionRevision{}
+	query := "SELECT * FROM promotion_revisions WHERE promotion_id = $1 ORDER BY revision"
+	err := r.reader(ctx).SelectContext(ctx, &revisions, query, promotionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revisions of promotion %s: %w", promotionID, err)
+	}
+	return revisions, nil
+}
+
+func (r *PromotionRepository) FindRevision(ctx context.Context, promotionID uuid.UUID, revision int) (*models.PromotionRevision, error) {
+	query := "SELECT * FROM promotion_revisions WHERE promotion_id = $1 AND revision = $2"
+	return r.findRevision(ctx, fmt.Sprintf("revision %d of promotion %s", revision, promotionID), query, promotionID, revision)
+}
+
+// FindRevisionAsOf returns the revision that was current at the given instant.
+func (r *PromotionRepository) FindRevisionAsOf(ctx context.Context, promotionID uuid.UUID, at time.Time) (*models.PromotionRevision, error) {
+	query := `
+		SELECT * FROM promotion_revisions
+		WHERE promotion_id = $1 AND created_at <= $2
+		ORDER BY revision DESC
+		LIMIT 1`
+	return r.findRevision(ctx, fmt.Sprintf("revision of promotion %s as of %s", promotionID, at.Format(time.RFC3339)), query, promotionID, at)
+}
+
+func (r *PromotionRepository) findRevision(ctx context.Context, description, query string, args ...any) (*models.PromotionRevision, error) {
+	var revision models.PromotionRevision
+	err := r.reader(ctx).GetContext(ctx, &revision, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s not found: %w", description, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", description, err)
+	}
+	return &revision, nil
+}
+
 func (r *PromotionRepository) FindByCoupon(ctx context.Context, coupon string) ([]models.Promotion, error) {
 	var promotions []models.Promotion
 	query := `
-		SELECT * FROM promotions 
-		WHERE coupon_code ILIKE $1`
-	err := r.DB.SelectContext(ctx, &promotions, query, "%"+coupon+"%")
+		SELECT * FROM promotions
+		WHERE coupon_code ILIKE $1 AND deleted_at IS NULL
+		ORDER BY created_at, id`
+	err := r.reader(ctx).SelectContext(ctx, &promotions, query, "%"+coupon+"%")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch promotions by filter: %w", err)
 	}
@@ -80,25 +310,68 @@ func (r *PromotionRepository) UpdatePromotion(ctx context.Context, promotion *mo
 	query := `
 		UPDATE promotions
 		SET title = $1, description = $2, discount_type = $3, discount_value = $4,
-			start_date = $5, end_date = $6, minimum_purchase_amount = $7, max_usage = $8,
-			current_usage = $9, coupon_code = $10, is_active = $11, updated_at = $12
-		WHERE id = $13`
-	_, err := r.DB.ExecContext(ctx, query,
+			start_date = $5, end_date = $6, schedule = $7, minimum_purchase_amount = $8, max_usage = $9,
+			current_usage = $10, coupon_code = $11, status = $12, is_active = $13, updated_at = $14,
+			version = version + 1
+		WHERE id = $15 AND version = $16 AND deleted_at IS NULL`
+	result, err := r.db(ctx).ExecContext(ctx, query,
 		promotion.Title, promotion.Description, promotion.DiscountType, promotion.DiscountValue,
-		promotion.StartDate, promotion.EndDate, promotion.MinimumPurchaseAmount, promotion.MaxUsage,
-		promotion.CurrentUsage, promotion.CouponCode, promotion.IsActive, promotion.UpdatedAt, promotion.ID,
+		promotion.StartDate, promotion.EndDate, promotion.Schedule, promotion.MinimumPurchaseAmount, promotion.MaxUsage,
+		promotion.CurrentUsage, promotion.CouponCode, promotion.Status, promotion.IsActive, promotion.UpdatedAt, promotion.ID,
+		promotion.Version,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update promotion %s: %w", promotion.Title, err)
 	}
-	return nil
+	if err := expectVersion(result, fmt.Sprintf("promotion %s", promotion.ID), promotion.Version); err != nil {
+		return err
+	}
+	promotion.Version++
+	return notifyChange(ctx, r.db(ctx), PromotionChangesChannel, promotion.ID)
 }
 
-func (r *PromotionRepository) DeletePromotion(ctx context.Context, id uuid.UUID) error {
-	query := "DELETE FROM promotions WHERE id = $1"
-	_, err := r.DB.ExecContext(ctx, query, id)
+// DeletePromotion soft-deletes a promotion; PurgeDeleted removes it later.
+func (r *PromotionRepository) DeletePromotion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE promotions
+		SET deleted_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db(ctx).ExecContext(ctx, query, at, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete promotion with ID %s: %w", id, err)
 	}
-	return nil
+	if err := expectAffected(result, fmt.Sprintf("promotion not found with ID %s", id)); err != nil {
+		return err
+	}
+	return notifyChange(ctx, r.db(ctx), PromotionChangesChannel, id)
+}
+
+func (r *PromotionRepository) RestorePromotion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE promotions
+		SET deleted_at = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NOT NULL`
+	result, err := r.db(ctx).ExecContext(ctx, query, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore promotion with ID %s: %w", id, err)
+	}
+	if err := expectAffected(result, fmt.Sprintf("deleted promotion not found with ID %s", id)); err != nil {
+		return err
+	}
+	return notifyChange(ctx, r.db(ctx), PromotionChangesChannel, id)
+}
+
+// PurgeDeleted permanently removes promotions soft-deleted before the given
+// instant and returns how many were removed.
+func (r *PromotionRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := "DELETE FROM promotions WHERE deleted_at < $1"
+	result, err := r.db(ctx).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted promotions: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted promotions: %w", err)
+	}
+	return rows, nil
 }
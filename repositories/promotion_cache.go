@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/cache"
+	"promo-api/models"
+	"promo-api/utils"
+)
+
+// PromotionCacheOptions bound the promotion cache. TTL is the longest a
+// cached promotion is served if its invalidation is missed.
+type PromotionCacheOptions struct {
+	TTL   time.Duration
+	Size  int
+	Clock utils.Clock
+}
+
+// CachedPromotionRepository caches FindByID and FindByCoupon, which coupon
+// validation at checkout calls on every request, in process memory. Misses
+// for the same key load once however many requests wait for them. Writes
+// through it invalidate the promotion once their transaction commits, and any
+// write drops every cached coupon search, whose results it may change;
+// Invalidate and Purge take the changes made by other instances. Reads
+// inside a transaction bypass the cache.
+type CachedPromotionRepository struct {
+	PromotionRepositoryInterface
+
+	ttl      time.Duration
+	byID     *cache.LRU[uuid.UUID, models.Promotion]
+	byCoupon *cache.LRU[string, []models.Promotion]
+
+	idLoads     cache.Group[uuid.UUID, models.Promotion]
+	couponLoads cache.Group[string, []models.Promotion]
+
+	// generation counts invalidations. A load only fills the cache if none
+	// happened while it ran, or it could keep what the invalidation dropped.
+	// mu makes the check and the fill one step.
+	mu         sync.Mutex
+	generation uint64
+}
+
+var _ PromotionRepositoryInterface = &CachedPromotionRepository{}
+
+func NewCachedPromotionRepository(repo PromotionRepositoryInterface, opts PromotionCacheOptions) *CachedPromotionRepository {
+	return &CachedPromotionRepository{
+		PromotionRepositoryInterface: repo,
+		ttl:                          opts.TTL,
+		byID:                         cache.NewLRU[uuid.UUID, models.Promotion](opts.Size, opts.Clock),
+		byCoupon:                     cache.NewLRU[string, []models.Promotion](opts.Size, opts.Clock),
+	}
+}
+
+// FindByID returns a copy of the cached promotion, so callers cannot change
+// the cached one.
+func (r *CachedPromotionRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
+	if InTx(ctx) {
+		return r.PromotionRepositoryInterface.FindByID(ctx, id)
+	}
+	if promotion, ok := r.byID.Get(id); ok {
+		promotion = promotion.Clone()
+		return &promotion, nil
+	}
+
+	promotion, err, _ := r.idLoads.Do(id, func() (models.Promotion, error) {
+		generation := r.currentGeneration()
+		promotion, err := r.PromotionRepositoryInterface.FindByID(loadContext(ctx), id)
+		if err != nil {
+			return models.Promotion{}, err
+		}
+		r.fill(generation, func() { r.byID.Set(id, *promotion, r.ttl) })
+		return *promotion, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	promotion = promotion.Clone()
+	return &promotion, nil
+}
+
+// FindByCoupon returns copies of the cached promotions.
+func (r *CachedPromotionRepository) FindByCoupon(ctx context.Context, coupon string) ([]models.Promotion, error) {
+	if InTx(ctx) {
+		return r.PromotionRepositoryInterface.FindByCoupon(ctx, coupon)
+	}
+	promotions, ok := r.byCoupon.Get(coupon)
+	if !ok {
+		var err error
+		promotions, err, _ = r.couponLoads.Do(coupon, func() ([]models.Promotion, error) {
+			generation := r.currentGeneration()
+			promotions, err := r.PromotionRepositoryInterface.FindByCoupon(loadContext(ctx), coupon)
+			if err != nil {
+				return nil, err
+			}
+			r.fill(generation, func() { r.byCoupon.Set(coupon, promotions, r.ttl) })
+			return promotions, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if promotions == nil {
+		return nil, nil
+	}
+	found := make([]models.Promotion, len(promotions))
+	for i, promotion := range promotions {
+		found[i] = promotion.Clone()
+	}
+	return found, nil
+}
+
+// loadContext returns the context of a load that fills the cache. The load is
+// shared, so one caller giving up must not fail the others. It reads the
+// primary, since a lagging replica could return what an invalidation just
+// dropped.
+func loadContext(ctx context.Context) context.Context {
+	return WithPrimary(context.WithoutCancel(ctx))
+}
+
+func (r *CachedPromotionRepository) currentGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// fill runs set unless the cache was invalidated since generation.
+func (r *CachedPromotionRepository) fill(generation uint64, set func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation == generation {
+		set()
+	}
+}
+
+func (r *CachedPromotionRepository) CreatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	err := r.PromotionRepositoryInterface.CreatePromotion(ctx, promotion)
+	AfterTx(ctx, func() { r.Invalidate(promotion.ID) })
+	return err
+}
+
+func (r *CachedPromotionRepository) UpdatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	err := r.PromotionRepositoryInterface.UpdatePromotion(ctx, promotion)
+	AfterTx(ctx, func() { r.Invalidate(promotion.ID) })
+	return err
+}
+
+func (r *CachedPromotionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, from, to string, at time.Time) (bool, error) {
+	updated, err := r.PromotionRepositoryInterface.UpdateStatus(ctx, id, from, to, at)
+	AfterTx(ctx, func() { r.Invalidate(id) })
+	return updated, err
+}
+
+func (r *CachedPromotionRepository) IncrementUsage(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	counted, err := r.PromotionRepositoryInterface.IncrementUsage(ctx, id, at)
+	AfterTx(ctx, func() { r.Invalidate(id) })
+	return counted, err
+}
+
+func (r *CachedPromotionRepository) DeletePromotion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	err := r.PromotionRepositoryInterface.DeletePromotion(ctx, id, at)
+	AfterTx(ctx, func() { r.Invalidate(id) })
+	return err
+}
+
+func (r *CachedPromotionRepository) RestorePromotion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	err := r.PromotionRepositoryInterface.RestorePromotion(ctx, id, at)
+	AfterTx(ctx, func() { r.Invalidate(id) })
+	return err
+}
+
+// Invalidate forgets the promotion id and every coupon search.
+func (r *CachedPromotionRepository) Invalidate(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.byID.Delete(id)
+	r.byCoupon.Purge()
+}
+
+// Purge forgets everything, for when changes may have been missed.
+func (r *CachedPromotionRepository) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.byID.Purge()
+	r.byCoupon.Purge()
+}
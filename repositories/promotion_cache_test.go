@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+)
+
+// promotionRepo serves FindByID and FindByCoupon from a map and counts the
+// lookups. When block is set, FindByID signals started and waits for block
+// to be closed.
+type promotionRepo struct {
+	PromotionRepositoryInterface
+	promotions map[uuid.UUID]models.Promotion
+	lookups    int
+	started    chan struct{}
+	block      chan struct{}
+}
+
+func (r *promotionRepo) FindByID(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
+	r.lookups++
+	if block := r.block; block != nil {
+		r.started <- struct{}{}
+		<-block
+	}
+	promotion, ok := r.promotions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &promotion, nil
+}
+
+func (r *promotionRepo) FindByCoupon(ctx context.Context, coupon string) ([]models.Promotion, error) {
+	r.lookups++
+	var found []models.Promotion
+	for _, promotion := range r.promotions {
+		if promotion.CouponCode != nil && *promotion.CouponCode == coupon {
+			found = append(found, promotion)
+		}
+	}
+	return found, nil
+}
+
+func (r *promotionRepo) UpdatePromotion(ctx context.Context, promotion *models.Promotion) error {
+	r.promotions[promotion.ID] = *promotion
+	return nil
+}
+
+// funcTransactor runs transactions without a backend.
+type funcTransactor struct{}
+
+func (funcTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestCachedPromotionRepository(t *testing.T) {
+	ctx := context.Background()
+	coupon := "SAVE10"
+	promotion := models.Promotion{ID: uuid.New(), Title: "Sale", CouponCode: &coupon, MaxUsage: new(int)}
+	repo := &promotionRepo{promotions: map[uuid.UUID]models.Promotion{promotion.ID: promotion}}
+	clock := &fixedClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	cached := NewCachedPromotionRepository(repo, PromotionCacheOptions{TTL: time.Minute, Size: 10, Clock: clock})
+
+	expectLookups := func(want int) {
+		t.Helper()
+		if repo.lookups != want {
+			t.Fatalf("%d lookups, want %d", repo.lookups, want)
+		}
+	}
+
+	found, err := cached.FindByID(ctx, promotion.ID)
+	if err != nil || found.Title != "Sale" {
+		t.Fatalf("FindByID = %v, %v, want Sale", found, err)
+	}
+	*found.MaxUsage = 5
+	found, _ = cached.FindByID(ctx, promotion.ID)
+	expectLookups(1)
+	if *found.MaxUsage != 0 {
+		t.Errorf("cached max usage = %d, want 0", *found.MaxUsage)
+	}
+
+	for range 2 {
+		if found, err := cached.FindByCoupon(ctx, coupon); err != nil || len(found) != 1 {
+			t.Fatalf("FindByCoupon = %v, %v, want the promotion", found, err)
+		}
+	}
+	expectLookups(2)
+
+	// Reads inside a transaction bypass the cache, and its writes only
+	// invalidate once it commits.
+	tx := TrackTx(funcTransactor{})
+	err = tx.WithTx(ctx, func(ctx context.Context) error {
+		cached.FindByID(ctx, promotion.ID)
+		expectLookups(3)
+
+		updated := promotion
+		updated.CurrentUsage = 1
+		cached.UpdatePromotion(ctx, &updated)
+		if found, _ := cached.FindByID(context.Background(), promotion.ID); found.CurrentUsage != 0 {
+			t.Error("the update was visible before its transaction ended")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if found, _ := cached.FindByID(ctx, promotion.ID); found.CurrentUsage != 1 {
+		t.Errorf("current usage after the transaction = %d, want 1", found.CurrentUsage)
+	}
+	cached.FindByCoupon(ctx, coupon)
+	expectLookups(5)
+
+	clock.now = clock.now.Add(time.Minute)
+	cached.FindByID(ctx, promotion.ID)
+	expectLookups(6)
+
+	if _, err := cached.FindByID(ctx, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindByID of a missing promotion = %v, want ErrNotFound", err)
+	}
+}
+
+// TestCachedPromotionRepositoryInvalidatedLoad checks that a load overtaken
+// by an invalidation does not fill the cache with what it read.
+func TestCachedPromotionRepositoryInvalidatedLoad(t *testing.T) {
+	ctx := context.Background()
+	promotion := models.Promotion{ID: uuid.New(), Title: "Sale"}
+	repo := &promotionRepo{
+		promotions: map[uuid.UUID]models.Promotion{promotion.ID: promotion},
+		started:    make(chan struct{}),
+		block:      make(chan struct{}),
+	}
+	cached := NewCachedPromotionRepository(repo, PromotionCacheOptions{TTL: time.Minute, Size: 10})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := cached.FindByID(ctx, promotion.ID); err != nil {
+			t.Errorf("FindByID: %v", err)
+		}
+	}()
+	<-repo.started
+	cached.Invalidate(promotion.ID)
+	close(repo.block)
+	<-done
+
+	repo.block = nil
+	cached.FindByID(ctx, promotion.ID)
+	if repo.lookups != 2 {
+		t.Errorf("%d lookups after an invalidated load, want 2", repo.lookups)
+	}
+}
+
+// laggingPromotionRepo answers FindByID from a replica that never sees
+// writes, unless the context asks for the primary.
+type laggingPromotionRepo struct {
+	promotionRepo
+	replica map[uuid.UUID]models.Promotion
+}
+
+func (r *laggingPromotionRepo) FindByID(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
+	if usePrimary(ctx) {
+		return r.promotionRepo.FindByID(ctx, id)
+	}
+	r.lookups++
+	promotion, ok := r.replica[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &promotion, nil
+}
+
+// TestCachedPromotionRepositoryFillsFromPrimary checks that a load after an
+// invalidation does not cache the stale row of a lagging replica.
+func TestCachedPromotionRepositoryFillsFromPrimary(t *testing.T) {
+	ctx := context.Background()
+	promotion := models.Promotion{ID: uuid.New(), Title: "Sale"}
+	repo := &laggingPromotionRepo{
+		promotionRepo: promotionRepo{promotions: map[uuid.UUID]models.Promotion{promotion.ID: promotion}},
+		replica:       map[uuid.UUID]models.Promotion{promotion.ID: promotion},
+	}
+	cached := NewCachedPromotionRepository(repo, PromotionCacheOptions{TTL: time.Hour, Size: 10})
+
+	if _, err := cached.FindByID(ctx, promotion.ID); err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	updated := promotion
+	updated.Title = "Bigger sale"
+	if err := cached.UpdatePromotion(ctx, &updated); err != nil {
+		t.Fatalf("UpdatePromotion: %v", err)
+	}
+	for range 2 {
+		found, err := cached.FindByID(ctx, promotion.ID)
+		if err != nil || found.Title != "Bigger sale" {
+			t.Fatalf("FindByID after the update = %v, %v, want Bigger sale", found, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary even when
+// replicas are configured, for callers that must see their own writes.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// Replicas is a set of read replicas used round-robin. Replicas that fail
+// their health check are skipped until they pass again; with none healthy,
+// reads fall back to the primary.
+type Replicas struct {
+	dbs     []*sqlx.DB
+	healthy []atomic.Bool
+	next    atomic.Uint64
+}
+
+// NewReplicas returns the replica set for dbs, all assumed healthy until
+// the first CheckHealth.
+func NewReplicas(dbs []*sqlx.DB) *Replicas {
+	r := &Replicas{dbs: dbs, healthy: make([]atomic.Bool, len(dbs))}
+	for i := range r.healthy {
+		r.healthy[i].Store(true)
+	}
+	return r
+}
+
+// pick returns the next healthy replica, or nil when there is none.
+func (r *Replicas) pick() *sqlx.DB {
+	if r == nil {
+		return nil
+	}
+	n := uint64(len(r.dbs))
+	for range n {
+		i := r.next.Add(1) % n
+		if r.healthy[i].Load() {
+			return r.dbs[i]
+		}
+	}
+	return nil
+}
+
+// CheckHealth pings every replica, waiting at most timeout for each, and
+// marks it healthy or not. Changes are logged.
+func (r *Replicas) CheckHealth(ctx context.Context, timeout time.Duration) {
+	for i, db := range r.dbs {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+
+		was := r.healthy[i].Swap(err == nil)
+		switch {
+		case err != nil && was:
+			slog.WarnContext(ctx, "Read replica is unhealthy, routing its reads elsewhere", "replica", i, "error", err)
+		case err == nil && !was:
+			slog.InfoContext(ctx, "Read replica is healthy again", "replica", i)
+		}
+	}
+}
+
+// Healthy returns the number of replicas that passed their last check and
+// the total.
+func (r *Replicas) Healthy() (healthy, total int) {
+	for i := range r.healthy {
+		if r.healthy[i].Load() {
+			healthy++
+		}
+	}
+	return healthy, len(r.dbs)
+}
+
+// DBs returns the replica pools, in the order they were configured.
+func (r *Replicas) DBs() []*sqlx.DB {
+	return r.dbs
+}
+
+// reader returns where a read-only query should run: the transaction carried
+// by ctx, the primary when ctx asks for it, or else a healthy replica,
+// falling back to the primary.
+func reader(ctx context.Context, primary *sqlx.DB, replicas *Replicas) DBTX {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	if usePrimary(ctx) {
+		return primary
+	}
+	if replica := replicas.pick(); replica != nil {
+		return replica
+	}
+	return primary
+}
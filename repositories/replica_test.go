@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lazyDB returns a pool that never connects unless used, enough to tell
+// pools apart.
+func lazyDB(t *testing.T, port string) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("postgres", "host=127.0.0.1 port="+port+" sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReplicasRoundRobin(t *testing.T) {
+	a, b, c := lazyDB(t, "1"), lazyDB(t, "2"), lazyDB(t, "3")
+	replicas := NewReplicas([]*sqlx.DB{a, b, c})
+
+	seen := map[*sqlx.DB]int{}
+	for range 6 {
+		seen[replicas.pick()]++
+	}
+	if seen[a] != 2 || seen[b] != 2 || seen[c] != 2 {
+		t.Errorf("picks = %v, want each replica twice", seen)
+	}
+
+	replicas.healthy[1].Store(false)
+	for range 4 {
+		if replicas.pick() == b {
+			t.Fatal("picked an unhealthy replica")
+		}
+	}
+	if healthy, total := replicas.Healthy(); healthy != 2 || total != 3 {
+		t.Errorf("Healthy = %d/%d, want 2/3", healthy, total)
+	}
+
+	replicas.healthy[0].Store(false)
+	replicas.healthy[2].Store(false)
+	if db := replicas.pick(); db != nil {
+		t.Errorf("pick with no healthy replica = %p, want nil", db)
+	}
+}
+
+func TestReader(t *testing.T) {
+	primary, replica := lazyDB(t, "1"), lazyDB(t, "2")
+	replicas := NewReplicas([]*sqlx.DB{replica})
+	ctx := context.Background()
+
+	if got := reader(ctx, primary, nil); got != primary {
+		t.Error("reader without replicas did not use the primary")
+	}
+	if got := reader(ctx, primary, replicas); got != replica {
+		t.Error("reader did not use the replica")
+	}
+	if got := reader(WithPrimary(ctx), primary, replicas); got != primary {
+		t.Error("reader ignored WithPrimary")
+	}
+	tx := &sqlx.Tx{}
+	if got := reader(context.WithValue(ctx, txKey{}, &txState{tx: tx}), primary, replicas); got != tx {
+		t.Error("reader did not use the transaction")
+	}
+
+	replicas.healthy[0].Store(false)
+	if got := reader(ctx, primary, replicas); got != primary {
+		t.Error("reader did not fall back to the primary")
+	}
+}
+
+func TestReplicasCheckHealth(t *testing.T) {
+	replicas := NewReplicas([]*sqlx.DB{lazyDB(t, "1")})
+	replicas.CheckHealth(context.Background(), 2*time.Second)
+	if healthy, _ := replicas.Healthy(); healthy != 0 {
+		t.Error("unreachable replica still marked healthy")
+	}
+}
@@ -0,0 +1,152 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/repositories"
+)
+
+func testCompanies(t *testing.T, b Backend) {
+	ctx := context.Background()
+	company := mustCreateCompany(t, b, newCompany("11111111000111", base))
+
+	if found, err := b.Companies.FindByAPIKey(ctx, company.APIKey); err != nil || found.ID != company.ID {
+		t.Fatalf("FindByAPIKey = %v, %v; want %s", found, err, company.ID)
+	}
+	if found, err := b.Companies.FindByCnpj(ctx, company.Cnpj); err != nil || found.ID != company.ID {
+		t.Fatalf("FindByCnpj = %v, %v; want %s", found, err, company.ID)
+	}
+
+	stored, err := b.Companies.FindByID(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if stored.Name != company.Name || stored.APIKey != company.APIKey || !stored.CreatedAt.Equal(base) || stored.Version != 1 {
+		t.Fatalf("FindByID = %+v, want the created company at version 1", stored)
+	}
+
+	stale := *stored
+	stored.Name = "Renamed"
+	stored.UpdatedAt = base.Add(time.Hour)
+	if err := b.Companies.UpdateCompany(ctx, stored); err != nil {
+		t.Fatalf("UpdateCompany: %v", err)
+	}
+	if stored.Version != 2 {
+		t.Fatalf("UpdateCompany left version %d, want 2", stored.Version)
+	}
+	stale.Name = "Lost update"
+	wantErr(t, "UpdateCompany with a stale version", b.Companies.UpdateCompany(ctx, &stale), repositories.ErrVersionConflict)
+
+	key, err := b.Companies.RotateAPIKey(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("RotateAPIKey: %v", err)
+	}
+	if _, err := b.Companies.FindByAPIKey(ctx, company.APIKey); err == nil {
+		t.Fatal("FindByAPIKey still accepts the rotated key")
+	}
+	rotated, err := b.Companies.FindByAPIKey(ctx, key)
+	if err != nil {
+		t.Fatalf("FindByAPIKey with the new key: %v", err)
+	}
+	if rotated.Name != "Renamed" || rotated.Version != 3 {
+		t.Fatalf("after rotation got %q at version %d, want %q at version 3", rotated.Name, rotated.Version, "Renamed")
+	}
+
+	if rotated.RateLimit != nil || rotated.RateLimitBurst != nil {
+		t.Fatalf("new company has rate limits %v/%v, want none", rotated.RateLimit, rotated.RateLimitBurst)
+	}
+	rate, burst := 120, 20
+	rotated.RateLimit, rotated.RateLimitBurst = &rate, &burst
+	if err := b.Companies.UpdateCompany(ctx, rotated); err != nil {
+		t.Fatalf("UpdateCompany with rate limits: %v", err)
+	}
+	limited, err := b.Companies.FindByAPIKey(ctx, key)
+	if err != nil {
+		t.Fatalf("FindByAPIKey after setting rate limits: %v", err)
+	}
+	if limited.RateLimit == nil || *limited.RateLimit != rate || limited.RateLimitBurst == nil || *limited.RateLimitBurst != burst {
+		t.Fatalf("stored rate limits = %v/%v, want %d/%d", limited.RateLimit, limited.RateLimitBurst, rate, burst)
+	}
+
+	if err := b.Companies.DeactivateCompany(ctx, company.ID); err != nil {
+		t.Fatalf("DeactivateCompany: %v", err)
+	}
+	_, err = b.Companies.FindByID(ctx, company.ID)
+	wantErr(t, "FindByID after deactivation", err, repositories.ErrNotFound)
+	_, err = b.Companies.FindByAPIKey(ctx, key)
+	wantErr(t, "FindByAPIKey after deactivation", err, repositories.ErrNotFound)
+	_, err = b.Companies.FindByCnpj(ctx, company.Cnpj)
+	wantErr(t, "FindByCnpj after deactivation", err, repositories.ErrNotFound)
+	wantErr(t, "DeactivateCompany twice", b.Companies.DeactivateCompany(ctx, company.ID), repositories.ErrNotFound)
+	_, err = b.Companies.RotateAPIKey(ctx, company.ID)
+	wantErr(t, "RotateAPIKey after deactivation", err, repositories.ErrNotFound)
+	_, err = b.Companies.RotateAPIKey(ctx, uuid.New())
+	wantErr(t, "RotateAPIKey of an unknown company", err, repositories.ErrNotFound)
+
+	rotated.Name = "Ghost"
+	wantErr(t, "UpdateCompany after deactivation", b.Companies.UpdateCompany(ctx, rotated), repositories.ErrVersionConflict)
+}
+
+func testCompanyUniqueness(t *testing.T, b Backend) {
+	ctx := context.Background()
+	first := mustCreateCompany(t, b, newCompany("22222222000122", base))
+	second := mustCreateCompany(t, b, newCompany("33333333000133", base))
+
+	wantErr(t, "CreateCompany with a taken CNPJ",
+		b.Companies.CreateCompany(ctx, newCompany(first.Cnpj, base)), repositories.ErrDuplicate)
+
+	stored, err := b.Companies.FindByID(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	stored.Cnpj = first.Cnpj
+	wantErr(t, "UpdateCompany to a taken CNPJ", b.Companies.UpdateCompany(ctx, stored), repositories.ErrDuplicate)
+
+	// Deactivating a company releases its CNPJ.
+	if err := b.Companies.DeactivateCompany(ctx, first.ID); err != nil {
+		t.Fatalf("DeactivateCompany: %v", err)
+	}
+	reused := mustCreateCompany(t, b, newCompany(first.Cnpj, base))
+	found, err := b.Companies.FindByCnpj(ctx, first.Cnpj)
+	if err != nil {
+		t.Fatalf("FindByCnpj: %v", err)
+	}
+	if found.ID != reused.ID {
+		t.Fatalf("FindByCnpj = %s, want the live company %s", found.ID, reused.ID)
+	}
+}
+
+func testCompanyPagination(t *testing.T, b Backend) {
+	ctx := context.Background()
+	c1 := mustCreateCompany(t, b, newCompany("40000000000101", base.Add(2*time.Hour)))
+	c2 := mustCreateCompany(t, b, newCompany("40000000000102", base))
+	c3 := mustCreateCompany(t, b, newCompany("40000000000103", base.Add(time.Hour)))
+	c4 := mustCreateCompany(t, b, newCompany("40000000000104", base.Add(3*time.Hour)))
+	if err := b.Companies.DeactivateCompany(ctx, c4.ID); err != nil {
+		t.Fatalf("DeactivateCompany: %v", err)
+	}
+
+	all, err := b.Companies.FindAll(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	wantIDs(t, "FindAll", all, companyID, c2.ID, c3.ID, c1.ID)
+
+	page, err := b.Companies.FindAll(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	wantIDs(t, "FindAll(limit 2, offset 1)", page, companyID, c3.ID, c1.ID)
+
+	past, err := b.Companies.FindAll(ctx, 10, 3)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if len(past) != 0 {
+		t.Fatalf("FindAll past the end returned %d companies", len(past))
+	}
+}
@@ -0,0 +1,506 @@
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+)
+
+func testAudit(t *testing.T, b Backend) {
+	ctx := context.Background()
+	resource := uuid.New()
+	newEvent := func(actor, action string, resourceID uuid.UUID, at time.Time) *models.AuditEvent {
+		event := &models.AuditEvent{
+			ID:           uuid.New(),
+			Actor:        actor,
+			Action:       action,
+			ResourceType: "promotion",
+			ResourceID:   resourceID,
+			Changes:      json.RawMessage(`{}`),
+			CreatedAt:    at,
+		}
+		if err := b.Audit.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("CreateEvent: %v", err)
+		}
+		return event
+	}
+	oldest := newEvent("admin", "create", resource, base)
+	middle := newEvent("admin", "update", resource, base.Add(time.Hour))
+	other := newEvent("company", "update", uuid.New(), base.Add(2*time.Hour))
+	newest := newEvent("admin", "delete", resource, base.Add(3*time.Hour))
+
+	from, to := base.Add(time.Hour), base.Add(3*time.Hour)
+	for _, tt := range []struct {
+		name   string
+		filter models.AuditFilter
+		want   []uuid.UUID
+	}{
+		{"everything", models.AuditFilter{}, []uuid.UUID{newest.ID, other.ID, middle.ID, oldest.ID}},
+		{"actor", models.AuditFilter{Actor: "admin"}, []uuid.UUID{newest.ID, middle.ID, oldest.ID}},
+		{"action", models.AuditFilter{Action: "update"}, []uuid.UUID{other.ID, middle.ID}},
+		{"resource", models.AuditFilter{ResourceType: "promotion", ResourceID: &resource}, []uuid.UUID{newest.ID, middle.ID, oldest.ID}},
+		{"interval", models.AuditFilter{From: &from, To: &to}, []uuid.UUID{other.ID, middle.ID}},
+		{"no match", models.AuditFilter{Actor: "nobody"}, []uuid.UUID{}},
+	} {
+		events, err := b.Audit.FindEvents(ctx, tt.filter, 10, 0)
+		if err != nil {
+			t.Fatalf("FindEvents(%s): %v", tt.name, err)
+		}
+		if events == nil {
+			t.Fatalf("FindEvents(%s) returned nil, want an empty list", tt.name)
+		}
+		wantIDs(t, "FindEvents("+tt.name+")", events, auditID, tt.want...)
+	}
+
+	events, err := b.Audit.FindEvents(ctx, models.AuditFilter{}, 2, 1)
+	if err != nil {
+		t.Fatalf("FindEvents: %v", err)
+	}
+	wantIDs(t, "FindEvents(limit 2, offset 1)", events, auditID, other.ID, middle.ID)
+}
+
+func testWebhooks(t *testing.T, b Backend) {
+	ctx := context.Background()
+	company := mustCreateCompany(t, b, newCompany("50000000000105", base))
+	otherCompany := mustCreateCompany(t, b, newCompany("50000000000106", base))
+
+	newWebhook := func(companyID uuid.UUID, active bool, createdAt time.Time, eventTypes ...string) *models.Webhook {
+		webhook := &models.Webhook{
+			ID:         uuid.New(),
+			CompanyID:  companyID,
+			URL:        "https://example.com/hooks",
+			Secret:     "secret",
+			EventTypes: eventTypes,
+			IsActive:   active,
+			CreatedAt:  createdAt,
+			UpdatedAt:  createdAt,
+		}
+		if err := b.Webhooks.CreateWebhook(ctx, webhook); err != nil {
+			t.Fatalf("CreateWebhook: %v", err)
+		}
+		return webhook
+	}
+	all := newWebhook(company.ID, true, base.Add(time.Hour))
+	created := newWebhook(company.ID, true, base, models.EventPromotionCreated)
+	inactive := newWebhook(otherCompany.ID, false, base)
+
+	webhooks, err := b.Webhooks.FindByCompany(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("FindByCompany: %v", err)
+	}
+	wantIDs(t, "FindByCompany", webhooks, webhookID, created.ID, all.ID)
+	if webhooks, err := b.Webhooks.FindByCompany(ctx, uuid.New()); err != nil || webhooks == nil || len(webhooks) != 0 {
+		t.Fatalf("FindByCompany of an unknown company = %v, %v; want an empty list", webhooks, err)
+	}
+
+	subscribed, err := b.Webhooks.FindSubscribed(ctx, company.ID, models.EventPromotionUpdated)
+	if err != nil {
+		t.Fatalf("FindSubscribed: %v", err)
+	}
+	wantIDs(t, "FindSubscribed", subscribed, webhookID, all.ID)
+	subscribed, err = b.Webhooks.FindSubscribed(ctx, company.ID, models.EventPromotionCreated)
+	if err != nil {
+		t.Fatalf("FindSubscribed: %v", err)
+	}
+	if len(subscribed) != 2 {
+		t.Fatalf("FindSubscribed(%s) returned %d webhooks, want 2", models.EventPromotionCreated, len(subscribed))
+	}
+	otherActive := newWebhook(otherCompany.ID, true, base, models.EventPromotionCreated)
+	subscribed, err = b.Webhooks.FindSubscribed(ctx, otherCompany.ID, models.EventPromotionCreated)
+	if err != nil {
+		t.Fatalf("FindSubscribed: %v", err)
+	}
+	wantIDs(t, "FindSubscribed of the other company", subscribed, webhookID, otherActive.ID)
+
+	eventID := uuid.New()
+	newDelivery := func(webhookID uuid.UUID, eventID uuid.UUID, status string, at time.Time) *models.WebhookDelivery {
+		delivery := &models.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     webhookID,
+			EventID:       eventID,
+			EventType:     models.EventPromotionCreated,
+			Payload:       json.RawMessage(`{}`),
+			Status:        status,
+			NextAttemptAt: &at,
+			CreatedAt:     at,
+			UpdatedAt:     at,
+		}
+		if err := b.Webhooks.CreateDelivery(ctx, delivery); err != nil {
+			t.Fatalf("CreateDelivery: %v", err)
+		}
+		return delivery
+	}
+	first := newDelivery(all.ID, eventID, models.WebhookDeliveryPending, base.Add(time.Minute))
+	duplicate := newDelivery(all.ID, eventID, models.WebhookDeliveryPending, base)
+	second := newDelivery(all.ID, uuid.New(), models.WebhookDeliveryPending, base)
+	dead := newDelivery(created.ID, uuid.New(), models.WebhookDeliveryDead, base)
+	newDelivery(inactive.ID, uuid.New(), models.WebhookDeliveryDead, base)
+
+	_, err = b.Webhooks.FindDelivery(ctx, duplicate.ID)
+	wantErr(t, "FindDelivery of a duplicate event", err, repositories.ErrNotFound)
+
+	claimedUntil := base.Add(time.Hour)
+	due, err := b.Webhooks.ClaimDueDeliveries(ctx, base, claimedUntil, 10)
+	if err != nil {
+		t.Fatalf("ClaimDueDeliveries: %v", err)
+	}
+	wantIDs(t, "ClaimDueDeliveries", due, deliveryID, second.ID)
+	if !due[0].NextAttemptAt.Equal(claimedUntil) {
+		t.Fatalf("claimed delivery is next attempted at %v, want %v", due[0].NextAttemptAt, claimedUntil)
+	}
+	// Claimed deliveries are not due again until the claim expires.
+	due, err = b.Webhooks.ClaimDueDeliveries(ctx, base.Add(time.Minute), claimedUntil.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("ClaimDueDeliveries: %v", err)
+	}
+	wantIDs(t, "ClaimDueDeliveries after a claim", due, deliveryID, first.ID)
+	due, err = b.Webhooks.ClaimDueDeliveries(ctx, claimedUntil.Add(time.Minute), claimedUntil.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("ClaimDueDeliveries: %v", err)
+	}
+	wantIDs(t, "ClaimDueDeliveries once the claims expire", due, deliveryID, second.ID, first.ID)
+
+	code, reason := 500, "server error"
+	second.Status = models.WebhookDeliveryDead
+	second.Attempts = 3
+	second.NextAttemptAt = nil
+	second.LastStatusCode = &code
+	second.LastError = &reason
+	second.UpdatedAt = base.Add(time.Hour)
+	if err := b.Webhooks.UpdateDelivery(ctx, second); err != nil {
+		t.Fatalf("UpdateDelivery: %v", err)
+	}
+	stored, err := b.Webhooks.FindDelivery(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("FindDelivery: %v", err)
+	}
+	if stored.Status != models.WebhookDeliveryDead || stored.Attempts != 3 || stored.NextAttemptAt != nil ||
+		stored.LastStatusCode == nil || *stored.LastStatusCode != code || !stored.UpdatedAt.Equal(second.UpdatedAt) {
+		t.Fatalf("FindDelivery = %+v, want the update", stored)
+	}
+	missing := *second
+	missing.ID = uuid.New()
+	wantErr(t, "UpdateDelivery of a missing delivery", b.Webhooks.UpdateDelivery(ctx, &missing), repositories.ErrNotFound)
+
+	deliveries, err := b.Webhooks.FindDeliveries(ctx, all.ID, "", 10, 0)
+	if err != nil {
+		t.Fatalf("FindDeliveries: %v", err)
+	}
+	wantIDs(t, "FindDeliveries", deliveries, deliveryID, first.ID, second.ID)
+	deliveries, err = b.Webhooks.FindDeliveries(ctx, all.ID, models.WebhookDeliveryPending, 10, 0)
+	if err != nil {
+		t.Fatalf("FindDeliveries: %v", err)
+	}
+	wantIDs(t, "FindDeliveries(pending)", deliveries, deliveryID, first.ID)
+
+	deadLetters, err := b.Webhooks.FindDeadDeliveries(ctx, company.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("FindDeadDeliveries: %v", err)
+	}
+	wantIDs(t, "FindDeadDeliveries", deadLetters, deliveryID, second.ID, dead.ID)
+	deadLetters, err = b.Webhooks.FindDeadDeliveries(ctx, company.ID, 1, 1)
+	if err != nil {
+		t.Fatalf("FindDeadDeliveries: %v", err)
+	}
+	wantIDs(t, "FindDeadDeliveries(limit 1, offset 1)", deadLetters, deliveryID, dead.ID)
+
+	if err := b.Webhooks.DeleteWebhook(ctx, all.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	wantErr(t, "DeleteWebhook twice", b.Webhooks.DeleteWebhook(ctx, all.ID), repositories.ErrNotFound)
+	_, err = b.Webhooks.FindByID(ctx, all.ID)
+	wantErr(t, "FindByID after delete", err, repositories.ErrNotFound)
+	_, err = b.Webhooks.FindDelivery(ctx, first.ID)
+	wantErr(t, "FindDelivery after deleting its webhook", err, repositories.ErrNotFound)
+}
+
+// testConcurrentDeliveryClaims runs two dispatchers claiming the same due
+// deliveries at once, as two instances do, and requires every delivery to
+// be claimed exactly once.
+func testConcurrentDeliveryClaims(t *testing.T, b Backend) {
+	ctx := context.Background()
+	company := mustCreateCompany(t, b, newCompany("50000000000107", base))
+	webhook := &models.Webhook{
+		ID: uuid.New(), CompanyID: company.ID, URL: "https://example.com/hooks", Secret: "secret",
+		EventTypes: []string{}, IsActive: true, CreatedAt: base, UpdatedAt: base,
+	}
+	if err := b.Webhooks.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	const total = 40
+	for i := range total {
+		at := base.Add(time.Duration(i) * time.Second)
+		delivery := &models.WebhookDelivery{
+			ID: uuid.New(), WebhookID: webhook.ID, EventID: uuid.New(), EventType: models.EventPromotionCreated,
+			Payload: json.RawMessage(`{}`), Status: models.WebhookDeliveryPending, NextAttemptAt: &at,
+			CreatedAt: at, UpdatedAt: at,
+		}
+		if err := b.Webhooks.CreateDelivery(ctx, delivery); err != nil {
+			t.Fatalf("CreateDelivery: %v", err)
+		}
+	}
+
+	now := base.Add(time.Hour)
+	var mu sync.Mutex
+	claims := map[uuid.UUID]int{}
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				due, err := b.Webhooks.ClaimDueDeliveries(ctx, now, now.Add(time.Hour), 3)
+				if err != nil {
+					t.Errorf("ClaimDueDeliveries: %v", err)
+					return
+				}
+				if len(due) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, delivery := range due {
+					claims[delivery.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claims) != total {
+		t.Errorf("claimed %d deliveries, want %d", len(claims), total)
+	}
+	for id, n := range claims {
+		if n != 1 {
+			t.Errorf("delivery %s claimed %d times, want once", id, n)
+		}
+	}
+}
+
+func testOutbox(t *testing.T, b Backend) {
+	ctx := context.Background()
+	aggregate := uuid.New()
+	var events []models.Event
+	for i := 0; i < 3; i++ {
+		event, err := models.NewEvent(models.EventPromotionUpdated, models.AggregatePromotion, aggregate, base, map[string]int{"n": i})
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		if err := b.Outbox.Enqueue(ctx, event); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+		events = append(events, event)
+	}
+	if err := b.Outbox.Enqueue(ctx, events[0]); err == nil {
+		t.Fatal("Enqueue accepted the same event twice")
+	}
+
+	pending, err := b.Outbox.FindPending(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	if len(pending) != 3 {
+		t.Fatalf("FindPending returned %d messages, want 3", len(pending))
+	}
+	for i, message := range pending {
+		var data map[string]int
+		if err := json.Unmarshal(message.Data, &data); err != nil {
+			t.Fatalf("message %d data: %v", i, err)
+		}
+		if message.EventID != events[i].ID || data["n"] != i || (i > 0 && message.Position <= pending[i-1].Position) {
+			t.Fatalf("FindPending is not in enqueue order at message %d", i)
+		}
+	}
+
+	if err := b.Outbox.MarkFailed(ctx, pending[0].Position, "broker down"); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+	if err := b.Outbox.MarkPublished(ctx, pending[1].Position, base.Add(time.Hour)); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	wantErr(t, "MarkPublished of a missing message", b.Outbox.MarkPublished(ctx, -1, base), repositories.ErrNotFound)
+	wantErr(t, "MarkFailed of a missing message", b.Outbox.MarkFailed(ctx, -1, "gone"), repositories.ErrNotFound)
+
+	pending, err = b.Outbox.FindPending(ctx, 0, 1)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].EventID != events[0].ID || pending[0].Attempts != 1 ||
+		pending[0].LastError == nil || *pending[0].LastError != "broker down" {
+		t.Fatalf("FindPending = %+v, want the failed message first", pending)
+	}
+	pending, err = b.Outbox.FindPending(ctx, pending[0].Position, 10)
+	if err != nil {
+		t.Fatalf("FindPending after the first message: %v", err)
+	}
+	if len(pending) != 1 || pending[0].EventID != events[2].ID {
+		t.Fatalf("FindPending after the first message = %+v, want the last one", pending)
+	}
+
+	if err := b.Outbox.MarkDead(ctx, pending[0].Position, "rejected", base.Add(time.Hour)); err != nil {
+		t.Fatalf("MarkDead: %v", err)
+	}
+	wantErr(t, "MarkDead of a missing message", b.Outbox.MarkDead(ctx, -1, "gone", base), repositories.ErrNotFound)
+	pending, err = b.Outbox.FindPending(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].EventID != events[0].ID {
+		t.Fatalf("FindPending = %+v, want the dead-lettered message left out", pending)
+	}
+
+	if n, err := b.Outbox.PurgePublished(ctx, base.Add(time.Hour)); err != nil || n != 0 {
+		t.Fatalf("PurgePublished before publication = %d, %v; want 0", n, err)
+	}
+	if n, err := b.Outbox.PurgePublished(ctx, base.Add(2*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("PurgePublished = %d, %v; want 1, keeping the dead-lettered message", n, err)
+	}
+
+	unlock, ok, err := b.Outbox.Lock(ctx)
+	if err != nil || !ok {
+		t.Fatalf("Lock = %v, %v; want the lock", ok, err)
+	}
+	if _, ok, err := b.Outbox.Lock(ctx); err != nil || ok {
+		t.Fatalf("second Lock = %v, %v; want it refused", ok, err)
+	}
+	unlock()
+	unlock, ok, err = b.Outbox.Lock(ctx)
+	if err != nil || !ok {
+		t.Fatalf("Lock after unlock = %v, %v; want the lock", ok, err)
+	}
+	unlock()
+}
+
+func testTransactions(t *testing.T, b Backend) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	company := newCompany("60000000000106", base)
+	promotion := newPromotion("Rolled back", base, base.Add(time.Hour))
+	err := b.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := b.Companies.CreateCompany(ctx, company); err != nil {
+			return err
+		}
+		if err := b.Promotions.CreatePromotion(ctx, promotion); err != nil {
+			return err
+		}
+		if _, err := b.Promotions.FindByID(ctx, promotion.ID); err != nil {
+			t.Errorf("FindByID inside the transaction: %v", err)
+		}
+		return errAbort
+	})
+	wantErr(t, "WithTx", err, errAbort)
+	_, err = b.Companies.FindByID(ctx, company.ID)
+	wantErr(t, "FindByID of a rolled back company", err, repositories.ErrNotFound)
+	_, err = b.Promotions.FindByIDIncludingDeleted(ctx, promotion.ID)
+	wantErr(t, "FindByID of a rolled back promotion", err, repositories.ErrNotFound)
+
+	// A failing nested transaction only undoes its own writes.
+	inner := newPromotion("Inner", base, base.Add(time.Hour))
+	err = b.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := b.Companies.CreateCompany(ctx, company); err != nil {
+			return err
+		}
+		err := b.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := b.Promotions.CreatePromotion(ctx, inner); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Errorf("nested WithTx: err = %v, want %v", err, errAbort)
+		}
+		return b.Promotions.CreatePromotion(ctx, promotion)
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := b.Companies.FindByID(ctx, company.ID); err != nil {
+		t.Fatalf("FindByID of a committed company: %v", err)
+	}
+	if _, err := b.Promotions.FindByID(ctx, promotion.ID); err != nil {
+		t.Fatalf("FindByID of a committed promotion: %v", err)
+	}
+	_, err = b.Promotions.FindByID(ctx, inner.ID)
+	wantErr(t, "FindByID of a promotion rolled back to its savepoint", err, repositories.ErrNotFound)
+
+	// Rolling back a savepoint undoes the savepoints released inside it, and
+	// a panic in a nested call rolls back its own savepoint only.
+	outer := newPromotion("Outer", base, base.Add(time.Hour))
+	middle := newPromotion("Middle", base, base.Add(time.Hour))
+	innermost := newPromotion("Innermost", base, base.Add(time.Hour))
+	panicked := newPromotion("Panicked", base, base.Add(time.Hour))
+	err = b.Tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := b.Promotions.CreatePromotion(ctx, outer); err != nil {
+			return err
+		}
+		err := b.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := b.Promotions.CreatePromotion(ctx, middle); err != nil {
+				return err
+			}
+			if err := b.Tx.WithTx(ctx, func(ctx context.Context) error {
+				return b.Promotions.CreatePromotion(ctx, innermost)
+			}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Errorf("nested WithTx: err = %v, want %v", err, errAbort)
+		}
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("the panic of a nested WithTx did not reach its caller")
+				}
+			}()
+			b.Tx.WithTx(ctx, func(ctx context.Context) error {
+				if err := b.Promotions.CreatePromotion(ctx, panicked); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := b.Promotions.FindByID(ctx, outer.ID); err != nil {
+		t.Fatalf("FindByID of a committed promotion: %v", err)
+	}
+	for _, p := range []*models.Promotion{middle, innermost, panicked} {
+		_, err = b.Promotions.FindByID(ctx, p.ID)
+		wantErr(t, "FindByID of "+p.Title+" after its savepoint rolled back", err, repositories.ErrNotFound)
+	}
+
+	// A panic rolls the whole transaction back and leaves the backend
+	// usable.
+	escaped := newPromotion("Escaped", base, base.Add(time.Hour))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic of WithTx did not reach its caller")
+			}
+		}()
+		b.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := b.Promotions.CreatePromotion(ctx, escaped); err != nil {
+				return err
+			}
+			return b.Tx.WithTx(ctx, func(ctx context.Context) error {
+				panic("boom")
+			})
+		})
+	}()
+	_, err = b.Promotions.FindByIDIncludingDeleted(ctx, escaped.ID)
+	wantErr(t, "FindByID of a promotion whose transaction panicked", err, repositories.ErrNotFound)
+}
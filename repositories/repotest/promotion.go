@@ -0,0 +1,490 @@
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/services"
+)
+
+func testPromotionSoftDelete(t *testing.T, b Backend) {
+	ctx := context.Background()
+	p := mustCreatePromotion(t, b, newPromotion("Soft", base.Add(-time.Hour), base.Add(time.Hour)))
+	if err := b.Promotions.CreateRevision(ctx, &models.PromotionRevision{
+		ID: uuid.New(), PromotionID: p.ID, Snapshot: json.RawMessage(`{}`), Actor: "admin", CreatedAt: base,
+	}); err != nil {
+		t.Fatalf("CreateRevision: %v", err)
+	}
+
+	deletedAt := base.Add(time.Hour)
+	if err := b.Promotions.DeletePromotion(ctx, p.ID, deletedAt); err != nil {
+		t.Fatalf("DeletePromotion: %v", err)
+	}
+	wantErr(t, "DeletePromotion twice", b.Promotions.DeletePromotion(ctx, p.ID, deletedAt), repositories.ErrNotFound)
+
+	_, err := b.Promotions.FindByID(ctx, p.ID)
+	wantErr(t, "FindByID after delete", err, repositories.ErrNotFound)
+	deleted, err := b.Promotions.FindByIDIncludingDeleted(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindByIDIncludingDeleted: %v", err)
+	}
+	if deleted.DeletedAt == nil || !deleted.DeletedAt.Equal(deletedAt) || deleted.Version != 2 {
+		t.Fatalf("deleted promotion has deleted_at %v at version %d, want %v at version 2", deleted.DeletedAt, deleted.Version, deletedAt)
+	}
+
+	live, err := b.Promotions.FindAll(ctx, false, 10, 0)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	wantIDs(t, "FindAll without deleted", live, promotionID)
+	all, err := b.Promotions.FindAll(ctx, true, 10, 0)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	wantIDs(t, "FindAll with deleted", all, promotionID, p.ID)
+	active, err := b.Promotions.FindByStatus(ctx, models.PromotionStatusActive, base, 10, 0)
+	if err != nil {
+		t.Fatalf("FindByStatus: %v", err)
+	}
+	wantIDs(t, "FindByStatus after delete", active, promotionID)
+
+	wantErr(t, "UpdatePromotion after delete", b.Promotions.UpdatePromotion(ctx, deleted), repositories.ErrVersionConflict)
+	changed, err := b.Promotions.UpdateStatus(ctx, p.ID, p.Status, models.PromotionStatusPaused, deletedAt)
+	if err != nil || changed {
+		t.Fatalf("UpdateStatus after delete = %v, %v; want false", changed, err)
+	}
+
+	if err := b.Promotions.RestorePromotion(ctx, p.ID, base.Add(2*time.Hour)); err != nil {
+		t.Fatalf("RestorePromotion: %v", err)
+	}
+	wantErr(t, "RestorePromotion twice", b.Promotions.RestorePromotion(ctx, p.ID, base), repositories.ErrNotFound)
+	restored, err := b.Promotions.FindByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindByID after restore: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 {
+		t.Fatalf("restored promotion has deleted_at %v at version %d, want nil at version 3", restored.DeletedAt, restored.Version)
+	}
+
+	if err := b.Promotions.DeletePromotion(ctx, p.ID, base.Add(3*time.Hour)); err != nil {
+		t.Fatalf("DeletePromotion: %v", err)
+	}
+	if n, err := b.Promotions.PurgeDeleted(ctx, base.Add(3*time.Hour)); err != nil || n != 0 {
+		t.Fatalf("PurgeDeleted before the deletion = %d, %v; want 0", n, err)
+	}
+	if n, err := b.Promotions.PurgeDeleted(ctx, base.Add(4*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("PurgeDeleted = %d, %v; want 1", n, err)
+	}
+	_, err = b.Promotions.FindByIDIncludingDeleted(ctx, p.ID)
+	wantErr(t, "FindByIDIncludingDeleted after purge", err, repositories.ErrNotFound)
+	revisions, err := b.Promotions.FindRevisions(ctx, p.ID)
+	if err != nil || len(revisions) != 0 {
+		t.Fatalf("FindRevisions after purge = %d revisions, %v; want none", len(revisions), err)
+	}
+}
+
+func testPromotionVersions(t *testing.T, b Backend) {
+	ctx := context.Background()
+	p := mustCreatePromotion(t, b, newPromotion("Versioned", base, base.Add(time.Hour)))
+
+	stored, err := b.Promotions.FindByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if stored.Version != 1 {
+		t.Fatalf("created promotion is at version %d, want 1", stored.Version)
+	}
+
+	stale := *stored
+	code := "SUMMER"
+	stored.Title = "Renamed"
+	stored.CouponCode = &code
+	stored.CreatedAt = base.Add(time.Hour)
+	stored.UpdatedAt = base.Add(time.Hour)
+	if err := b.Promotions.UpdatePromotion(ctx, stored); err != nil {
+		t.Fatalf("UpdatePromotion: %v", err)
+	}
+	if stored.Version != 2 {
+		t.Fatalf("UpdatePromotion left version %d, want 2", stored.Version)
+	}
+	wantErr(t, "UpdatePromotion with a stale version", b.Promotions.UpdatePromotion(ctx, &stale), repositories.ErrVersionConflict)
+
+	updated, err := b.Promotions.FindByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if updated.Title != "Renamed" || updated.CouponCode == nil || *updated.CouponCode != code || updated.Version != 2 {
+		t.Fatalf("FindByID = %+v, want the update at version 2", updated)
+	}
+	if !updated.CreatedAt.Equal(base) {
+		t.Fatalf("UpdatePromotion changed created_at to %v", updated.CreatedAt)
+	}
+
+	// Callers never share memory with stored rows.
+	*stored.CouponCode = "MUTATED"
+	again, err := b.Promotions.FindByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if *again.CouponCode != "SUMMER" {
+		t.Fatalf("coupon code changed to %q without an update", *again.CouponCode)
+	}
+
+	changed, err := b.Promotions.UpdateStatus(ctx, p.ID, models.PromotionStatusActive, models.PromotionStatusPaused, base)
+	if err != nil || changed {
+		t.Fatalf("UpdateStatus from the wrong status = %v, %v; want false", changed, err)
+	}
+	changed, err = b.Promotions.UpdateStatus(ctx, p.ID, models.PromotionStatusScheduled, models.PromotionStatusActive, base)
+	if err != nil || !changed {
+		t.Fatalf("UpdateStatus = %v, %v; want true", changed, err)
+	}
+	activated, err := b.Promotions.FindByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if activated.Status != models.PromotionStatusActive || !activated.IsActive || activated.Version != 3 {
+		t.Fatalf("after UpdateStatus got status %s, is_active %v, version %d", activated.Status, activated.IsActive, activated.Version)
+	}
+}
+
+func testPromotionUsage(t *testing.T, b Backend) {
+	ctx := context.Background()
+	limit := 2
+	limited := newPromotion("Limited", base, base.Add(time.Hour))
+	limited.MaxUsage = &limit
+	mustCreatePromotion(t, b, limited)
+
+	for n := 1; n <= limit; n++ {
+		counted, err := b.Promotions.IncrementUsage(ctx, limited.ID, base.Add(time.Duration(n)*time.Minute))
+		if err != nil || !counted {
+			t.Fatalf("IncrementUsage %d = %v, %v; want true", n, counted, err)
+		}
+	}
+	counted, err := b.Promotions.IncrementUsage(ctx, limited.ID, base.Add(time.Hour))
+	if err != nil || counted {
+		t.Fatalf("IncrementUsage past max_usage = %v, %v; want false", counted, err)
+	}
+	stored, err := b.Promotions.FindByID(ctx, limited.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if stored.CurrentUsage != limit || stored.Version != 1+limit || !stored.UpdatedAt.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("after IncrementUsage got usage %d at version %d, updated at %v", stored.CurrentUsage, stored.Version, stored.UpdatedAt)
+	}
+
+	deleted := mustCreatePromotion(t, b, newPromotion("Deleted", base, base.Add(time.Hour)))
+	if err := b.Promotions.DeletePromotion(ctx, deleted.ID, base); err != nil {
+		t.Fatalf("DeletePromotion: %v", err)
+	}
+	for _, id := range []uuid.UUID{deleted.ID, uuid.New()} {
+		if counted, err := b.Promotions.IncrementUsage(ctx, id, base); err != nil || counted {
+			t.Fatalf("IncrementUsage of a missing promotion = %v, %v; want false", counted, err)
+		}
+	}
+}
+
+func testPromotionFiltering(t *testing.T, b Backend) {
+	ctx := context.Background()
+	usage := 5
+	scheduled := mustCreatePromotion(t, b, newPromotion("Scheduled", base.Add(time.Hour), base.Add(2*time.Hour)))
+	active := mustCreatePromotion(t, b, newPromotion("Active", base.Add(-time.Hour), base.Add(time.Hour)))
+	earlier := mustCreatePromotion(t, b, newPromotion("Earlier", base.Add(-2*time.Hour), base.Add(time.Hour)))
+	expired := mustCreatePromotion(t, b, newPromotion("Expired", base.Add(-2*time.Hour), base.Add(-time.Hour)))
+	exhausted := newPromotion("Exhausted", base.Add(-time.Hour), base.Add(time.Hour))
+	exhausted.MaxUsage, exhausted.CurrentUsage = &usage, usage
+	mustCreatePromotion(t, b, exhausted)
+	paused := newPromotion("Paused", base.Add(-time.Hour), base.Add(time.Hour))
+	paused.Status = models.PromotionStatusPaused
+	mustCreatePromotion(t, b, paused)
+
+	for _, tt := range []struct {
+		status string
+		want   []uuid.UUID
+	}{
+		{models.PromotionStatusScheduled, []uuid.UUID{scheduled.ID}},
+		{models.PromotionStatusActive, []uuid.UUID{earlier.ID, active.ID}},
+		{models.PromotionStatusExpired, []uuid.UUID{expired.ID}},
+		{models.PromotionStatusExhausted, []uuid.UUID{exhausted.ID}},
+		{models.PromotionStatusPaused, []uuid.UUID{paused.ID}},
+		{models.PromotionStatusDraft, nil},
+	} {
+		got, err := b.Promotions.FindByStatus(ctx, tt.status, base, 10, 0)
+		if err != nil {
+			t.Fatalf("FindByStatus(%s): %v", tt.status, err)
+		}
+		wantIDs(t, "FindByStatus("+tt.status+")", got, promotionID, tt.want...)
+	}
+
+	got, err := b.Promotions.FindActiveAt(ctx, base, 1, 1)
+	if err != nil {
+		t.Fatalf("FindActiveAt: %v", err)
+	}
+	wantIDs(t, "FindActiveAt(limit 1, offset 1)", got, promotionID, active.ID)
+
+	// Every fixture but paused was stored as scheduled.
+	changes, err := b.Promotions.FindStatusChanges(ctx, base, 10)
+	if err != nil {
+		t.Fatalf("FindStatusChanges: %v", err)
+	}
+	if len(changes) != 4 {
+		t.Fatalf("FindStatusChanges returned %d promotions, want 4", len(changes))
+	}
+	for i := 1; i < len(changes); i++ {
+		if changes[i-1].ID.String() > changes[i].ID.String() {
+			t.Fatalf("FindStatusChanges is not ordered by ID")
+		}
+	}
+
+	upper, lower := "BLACK-FRIDAY", "black-week"
+	first := newPromotion("First coupon", base, base.Add(time.Hour))
+	first.CouponCode = &upper
+	mustCreatePromotion(t, b, first)
+	second := newPromotion("Second coupon", base, base.Add(time.Hour))
+	second.CouponCode = &lower
+	second.CreatedAt = base.Add(time.Minute)
+	mustCreatePromotion(t, b, second)
+
+	coupons, err := b.Promotions.FindByCoupon(ctx, "Black")
+	if err != nil {
+		t.Fatalf("FindByCoupon: %v", err)
+	}
+	wantIDs(t, "FindByCoupon", coupons, promotionID, first.ID, second.ID)
+	coupons, err = b.Promotions.FindByCoupon(ctx, "friday")
+	if err != nil {
+		t.Fatalf("FindByCoupon: %v", err)
+	}
+	wantIDs(t, "FindByCoupon", coupons, promotionID, first.ID)
+}
+
+func testPromotionPagination(t *testing.T, b Backend) {
+	ctx := context.Background()
+	var want []uuid.UUID
+	for i := 0; i < 5; i++ {
+		p := newPromotion("Page", base, base.Add(time.Hour))
+		p.CreatedAt = base.Add(time.Duration(5-i) * time.Minute)
+		mustCreatePromotion(t, b, p)
+		want = append([]uuid.UUID{p.ID}, want...)
+	}
+
+	for _, tt := range []struct{ limit, offset int }{{2, 0}, {2, 2}, {2, 4}, {10, 5}} {
+		got, err := b.Promotions.FindAll(ctx, false, tt.limit, tt.offset)
+		if err != nil {
+			t.Fatalf("FindAll: %v", err)
+		}
+		end := min(tt.offset+tt.limit, len(want))
+		wantIDs(t, "FindAll", got, promotionID, want[tt.offset:end]...)
+	}
+}
+
+func testPromotionHistory(t *testing.T, b Backend) {
+	ctx := context.Background()
+	p := mustCreatePromotion(t, b, newPromotion("History", base, base.Add(time.Hour)))
+
+	for i := 0; i < 2; i++ {
+		revision := &models.PromotionRevision{
+			ID:          uuid.New(),
+			PromotionID: p.ID,
+			Snapshot:    json.RawMessage(`{"title": "History"}`),
+			Actor:       "admin",
+			CreatedAt:   base.Add(time.Duration(i) * time.Hour),
+		}
+		if err := b.Promotions.CreateRevision(ctx, revision); err != nil {
+			t.Fatalf("CreateRevision: %v", err)
+		}
+		if revision.Revision != i+1 {
+			t.Fatalf("CreateRevision numbered revision %d, want %d", revision.Revision, i+1)
+		}
+	}
+
+	revisions, err := b.Promotions.FindRevisions(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindRevisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Revision != 1 || revisions[1].Revision != 2 {
+		t.Fatalf("FindRevisions = %+v, want revisions 1 and 2", revisions)
+	}
+	if _, err := b.Promotions.FindRevision(ctx, p.ID, 2); err != nil {
+		t.Fatalf("FindRevision: %v", err)
+	}
+	_, err = b.Promotions.FindRevision(ctx, p.ID, 3)
+	wantErr(t, "FindRevision of a missing revision", err, repositories.ErrNotFound)
+
+	_, err = b.Promotions.FindRevisionAsOf(ctx, p.ID, base.Add(-time.Second))
+	wantErr(t, "FindRevisionAsOf before the first revision", err, repositories.ErrNotFound)
+	for _, tt := range []struct {
+		at   time.Time
+		want int
+	}{
+		{base, 1},
+		{base.Add(59 * time.Minute), 1},
+		{base.Add(time.Hour), 2},
+	} {
+		revision, err := b.Promotions.FindRevisionAsOf(ctx, p.ID, tt.at)
+		if err != nil {
+			t.Fatalf("FindRevisionAsOf: %v", err)
+		}
+		if revision.Revision != tt.want {
+			t.Fatalf("FindRevisionAsOf(%v) = revision %d, want %d", tt.at, revision.Revision, tt.want)
+		}
+	}
+
+	transitions, err := b.Promotions.FindTransitions(ctx, p.ID)
+	if err != nil || transitions == nil || len(transitions) != 0 {
+		t.Fatalf("FindTransitions without transitions = %v, %v; want an empty list", transitions, err)
+	}
+	later := &models.PromotionTransition{
+		ID: uuid.New(), PromotionID: p.ID, Action: "pause", FromStatus: "active", ToStatus: "paused", Actor: "admin", CreatedAt: base.Add(time.Hour),
+	}
+	earlier := &models.PromotionTransition{
+		ID: uuid.New(), PromotionID: p.ID, Action: "publish", FromStatus: "draft", ToStatus: "active", Actor: "admin", CreatedAt: base,
+	}
+	for _, transition := range []*models.PromotionTransition{later, earlier} {
+		if err := b.Promotions.RecordTransition(ctx, transition); err != nil {
+			t.Fatalf("RecordTransition: %v", err)
+		}
+	}
+	transitions, err = b.Promotions.FindTransitions(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("FindTransitions: %v", err)
+	}
+	if len(transitions) != 2 || transitions[0].ID != earlier.ID || transitions[1].ID != later.ID {
+		t.Fatalf("FindTransitions = %+v, want the transitions oldest first", transitions)
+	}
+}
+
+// testStatusAgreement holds the status filters of the repository to
+// services.PromotionStatus, the status the API reports, on the instants where
+// they could disagree.
+func testStatusAgreement(t *testing.T, b Backend) {
+	ctx := context.Background()
+	usage := 5
+	tick := time.Microsecond
+	fixture := func(title string, start, end time.Time, change func(p *models.Promotion)) *models.Promotion {
+		p := newPromotion(title, start, end)
+		if change != nil {
+			change(p)
+		}
+		return mustCreatePromotion(t, b, p)
+	}
+	exhaust := func(p *models.Promotion) { p.MaxUsage, p.CurrentUsage = &usage, usage }
+	status := func(s string) func(p *models.Promotion) {
+		return func(p *models.Promotion) { p.Status = s }
+	}
+
+	promotions := []*models.Promotion{
+		fixture("Starts now", base, base.Add(time.Hour), nil),
+		fixture("Ends now", base.Add(-time.Hour), base, nil),
+		fixture("Starts and ends now", base, base, nil),
+		fixture("Starts next tick", base.Add(tick), base.Add(time.Hour), nil),
+		fixture("Ended last tick", base.Add(-time.Hour), base.Add(-tick), nil),
+		fixture("Exhausted", base.Add(-time.Hour), base.Add(time.Hour), exhaust),
+		fixture("Exhausted, starts now", base, base.Add(time.Hour), exhaust),
+		fixture("Exhausted, ends now", base.Add(-time.Hour), base, exhaust),
+		fixture("Exhausted, ended", base.Add(-time.Hour), base.Add(-tick), exhaust),
+		fixture("Under usage", base.Add(-time.Hour), base.Add(time.Hour), func(p *models.Promotion) {
+			limit := usage + 1
+			p.MaxUsage, p.CurrentUsage = &limit, usage
+		}),
+		fixture("Paused, starts now", base, base.Add(time.Hour), status(models.PromotionStatusPaused)),
+		fixture("Paused, ends now", base.Add(-time.Hour), base, status(models.PromotionStatusPaused)),
+		fixture("Paused, ended", base.Add(-time.Hour), base.Add(-tick), status(models.PromotionStatusPaused)),
+		fixture("Archived, active dates", base, base.Add(time.Hour), status(models.PromotionStatusArchived)),
+		fixture("Draft, active dates", base, base.Add(time.Hour), status(models.PromotionStatusDraft)),
+		fixture("Stored active, starts next tick", base.Add(tick), base.Add(time.Hour), status(models.PromotionStatusActive)),
+		fixture("Stored active, ended", base.Add(-time.Hour), base.Add(-tick), status(models.PromotionStatusActive)),
+	}
+	deleted := []*models.Promotion{
+		fixture("Deleted, starts now", base, base.Add(time.Hour), nil),
+		fixture("Deleted, paused", base, base.Add(time.Hour), status(models.PromotionStatusPaused)),
+	}
+	for _, p := range deleted {
+		if err := b.Promotions.DeletePromotion(ctx, p.ID, base.Add(-time.Hour)); err != nil {
+			t.Fatalf("DeletePromotion: %v", err)
+		}
+	}
+
+	agree := func(t *testing.T, at time.Time) {
+		t.Helper()
+		stored := map[uuid.UUID]string{}
+		for _, s := range []string{
+			models.PromotionStatusDraft, models.PromotionStatusScheduled, models.PromotionStatusActive,
+			models.PromotionStatusPaused, models.PromotionStatusExpired, models.PromotionStatusExhausted,
+			models.PromotionStatusArchived,
+		} {
+			found, err := b.Promotions.FindByStatus(ctx, s, at, 100, 0)
+			if err != nil {
+				t.Fatalf("FindByStatus(%s): %v", s, err)
+			}
+			for _, p := range found {
+				if previous, ok := stored[p.ID]; ok {
+					t.Fatalf("%q is both %s and %s at %s", p.Title, previous, s, at)
+				}
+				stored[p.ID] = s
+			}
+		}
+		active, err := b.Promotions.FindActiveAt(ctx, at, 100, 0)
+		if err != nil {
+			t.Fatalf("FindActiveAt: %v", err)
+		}
+		isActive := map[uuid.UUID]bool{}
+		for _, p := range active {
+			isActive[p.ID] = true
+		}
+		changes, err := b.Promotions.FindStatusChanges(ctx, at, 100)
+		if err != nil {
+			t.Fatalf("FindStatusChanges: %v", err)
+		}
+		changed := map[uuid.UUID]bool{}
+		for _, p := range changes {
+			changed[p.ID] = true
+		}
+
+		for _, p := range promotions {
+			want := services.PromotionStatus(p, at)
+			if stored[p.ID] != want {
+				t.Errorf("%q at %s: repository status %q, service status %q", p.Title, at, stored[p.ID], want)
+			}
+			if isActive[p.ID] != (want == models.PromotionStatusActive) {
+				t.Errorf("%q at %s: FindActiveAt includes it = %v, service status %q", p.Title, at, isActive[p.ID], want)
+			}
+			if changed[p.ID] != (p.Status != want) {
+				t.Errorf("%q at %s: FindStatusChanges includes it = %v, stored %q, service status %q", p.Title, at, changed[p.ID], p.Status, want)
+			}
+		}
+		for _, p := range deleted {
+			if s, ok := stored[p.ID]; ok || isActive[p.ID] || changed[p.ID] {
+				t.Errorf("deleted %q at %s is listed as %q", p.Title, at, s)
+			}
+		}
+	}
+
+	for _, at := range []time.Time{base.Add(-tick), base, base.Add(tick)} {
+		agree(t, at)
+	}
+
+	// A resume stores the status the service computes without the manual
+	// one, which the repository must then report unchanged.
+	for _, p := range promotions {
+		if p.Status != models.PromotionStatusPaused {
+			continue
+		}
+		resumed := *p
+		resumed.Status = ""
+		to := services.PromotionStatus(&resumed, base)
+		if to == models.PromotionStatusExpired {
+			continue
+		}
+		ok, err := b.Promotions.UpdateStatus(ctx, p.ID, p.Status, to, base)
+		if err != nil || !ok {
+			t.Fatalf("UpdateStatus(%q): %v, %v", p.Title, ok, err)
+		}
+		p.Status = to
+	}
+	agree(t, base)
+}
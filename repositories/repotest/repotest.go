@@ -0,0 +1,129 @@
+// Package repotest is the contract every repository backend must honour.
+// Backends run it from their own tests with Run, so the PostgreSQL and
+// in-memory repositories are held to the same semantics.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+)
+
+// Backend is one set of repositories sharing a store. Tx must make the
+// repositories take part in its transactions.
+type Backend struct {
+	Companies  repositories.CompanyRepositoryInterface
+	Promotions repositories.PromotionRepositoryInterface
+	Audit      repositories.AuditRepositoryInterface
+	Webhooks   repositories.WebhookRepositoryInterface
+	Outbox     repositories.OutboxRepositoryInterface
+	Tx         repositories.Transactor
+}
+
+// base is the reference instant of every fixture. Times are kept to whole
+// microseconds, the resolution of PostgreSQL timestamps.
+var base = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Run runs the contract against the backends built by newBackend. Each
+// subtest gets a fresh, empty backend.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b Backend)
+	}{
+		{"Companies", testCompanies},
+		{"CompanyUniqueness", testCompanyUniqueness},
+		{"CompanyPagination", testCompanyPagination},
+		{"PromotionSoftDelete", testPromotionSoftDelete},
+		{"PromotionVersions", testPromotionVersions},
+		{"PromotionUsage", testPromotionUsage},
+		{"PromotionFiltering", testPromotionFiltering},
+		{"PromotionPagination", testPromotionPagination},
+		{"StatusAgreement", testStatusAgreement},
+		{"PromotionHistory", testPromotionHistory},
+		{"Audit", testAudit},
+		{"Webhooks", testWebhooks},
+		{"ConcurrentDeliveryClaims", testConcurrentDeliveryClaims},
+		{"Outbox", testOutbox},
+		{"Transactions", testTransactions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newBackend(t))
+		})
+	}
+}
+
+func newCompany(cnpj string, createdAt time.Time) *models.Company {
+	return &models.Company{
+		ID:        uuid.New(),
+		Name:      "Company " + cnpj,
+		Cnpj:      cnpj,
+		APIKey:    uuid.NewString(),
+		Timezone:  "UTC",
+		IsActive:  true,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+}
+
+func newPromotion(title string, start, end time.Time) *models.Promotion {
+	return &models.Promotion{
+		ID:            uuid.New(),
+		Title:         title,
+		DiscountType:  models.DiscountTypePercentage,
+		DiscountValue: 10,
+		StartDate:     start,
+		EndDate:       end,
+		Status:        models.PromotionStatusScheduled,
+		CreatedAt:     base,
+		UpdatedAt:     base,
+	}
+}
+
+func mustCreateCompany(t *testing.T, b Backend, company *models.Company) *models.Company {
+	t.Helper()
+	if err := b.Companies.CreateCompany(context.Background(), company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	return company
+}
+
+func mustCreatePromotion(t *testing.T, b Backend, promotion *models.Promotion) *models.Promotion {
+	t.Helper()
+	if err := b.Promotions.CreatePromotion(context.Background(), promotion); err != nil {
+		t.Fatalf("CreatePromotion: %v", err)
+	}
+	return promotion
+}
+
+func wantErr(t *testing.T, op string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("%s: err = %v, want %v", op, err, want)
+	}
+}
+
+func wantIDs[T any](t *testing.T, op string, rows []T, id func(T) uuid.UUID, want ...uuid.UUID) {
+	t.Helper()
+	got := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		got[i] = id(row)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("%s = %v, want %v", op, got, want)
+	}
+}
+
+func companyID(c models.Company) uuid.UUID          { return c.ID }
+func promotionID(p models.Promotion) uuid.UUID      { return p.ID }
+func auditID(e models.AuditEvent) uuid.UUID         { return e.ID }
+func webhookID(w models.Webhook) uuid.UUID          { return w.ID }
+func deliveryID(d models.WebhookDelivery) uuid.UUID { return d.ID }
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"promo-api/tracing"
+)
+
+// tracedDB records a span for every query run through it.
+type tracedDB struct {
+	DBTX
+}
+
+// traced wraps db so its queries are traced.
+func traced(db DBTX) DBTX {
+	return tracedDB{db}
+}
+
+func (db tracedDB) GetContext(ctx context.Context, dest any, query string, args ...any) (err error) {
+	ctx, end := startQuery(ctx, query)
+	defer end(&err)
+	return db.DBTX.GetContext(ctx, dest, query, args...)
+}
+
+func (db tracedDB) SelectContext(ctx context.Context, dest any, query string, args ...any) (err error) {
+	ctx, end := startQuery(ctx, query)
+	defer end(&err)
+	return db.DBTX.SelectContext(ctx, dest, query, args...)
+}
+
+func (db tracedDB) QueryContext(ctx context.Context, query string, args ...any) (_ *sql.Rows, err error) {
+	ctx, end := startQuery(ctx, query)
+	defer end(&err)
+	return db.DBTX.QueryContext(ctx, query, args...)
+}
+
+func (db tracedDB) QueryxContext(ctx context.Context, query string, args ...any) (_ *sqlx.Rows, err error) {
+	ctx, end := startQuery(ctx, query)
+	defer end(&err)
+	return db.DBTX.QueryxContext(ctx, query, args...)
+}
+
+// QueryRowxContext's span ends before the row is scanned, so it records
+// failures to run the query but not sql.ErrNoRows.
+func (db tracedDB) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	ctx, end := startQuery(ctx, query)
+	row := db.DBTX.QueryRowxContext(ctx, query, args...)
+	err := row.Err()
+	end(&err)
+	return row
+}
+
+func (db tracedDB) ExecContext(ctx context.Context, query string, args ...any) (_ sql.Result, err error) {
+	ctx, end := startQuery(ctx, query)
+	defer end(&err)
+	return db.DBTX.ExecContext(ctx, query, args...)
+}
+
+// startQuery starts the span of a query, named after its operation, such as
+// SELECT. A query that finds no rows has not failed, so sql.ErrNoRows is not
+// recorded as an error.
+func startQuery(ctx context.Context, query string) (context.Context, func(*error)) {
+	statement := sanitizeSQL(query)
+	operation, _, _ := strings.Cut(statement, " ")
+	operation = strings.ToUpper(operation)
+	ctx, end := tracing.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(statement),
+		),
+	)
+	return ctx, func(errp *error) {
+		if errp != nil && errors.Is(*errp, sql.ErrNoRows) {
+			errp = nil
+		}
+		end(errp)
+	}
+}
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumberLiteral  = regexp.MustCompile(`(\$?)\b\d+(?:\.\d+)?\b`)
+	sqlWhitespaceRuns = regexp.MustCompile(`\s+`)
+)
+
+// sanitizeSQL prepares a statement for a span: literals, which could hold
+// personal data, become ?, and whitespace is collapsed. Placeholders such as
+// $1 are kept; their values are never recorded.
+func sanitizeSQL(query string) string {
+	query = sqlStringLiteral.ReplaceAllString(query, "?")
+	query = sqlNumberLiteral.ReplaceAllStringFunc(query, func(literal string) string {
+		if strings.HasPrefix(literal, "$") {
+			return literal
+		}
+		return "?"
+	})
+	return strings.TrimSpace(sqlWhitespaceRuns.ReplaceAllString(query, " "))
+}
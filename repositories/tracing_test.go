@@ -0,0 +1,28 @@
+package repositories
+
+import "testing"
+
+func TestSanitizeSQL(t *testing.T) {
+	for _, tc := range []struct{ query, want string }{
+		{
+			"SELECT * FROM promotions\n\t\tWHERE id = $1 AND deleted_at IS NULL",
+			"SELECT * FROM promotions WHERE id = $1 AND deleted_at IS NULL",
+		},
+		{
+			"UPDATE companies SET api_key = 'secret', is_active = false WHERE cnpj = '11222333000181'",
+			"UPDATE companies SET api_key = ?, is_active = false WHERE cnpj = ?",
+		},
+		{
+			"SELECT name FROM companies WHERE name = 'O''Brien' LIMIT 10 OFFSET 2.5",
+			"SELECT name FROM companies WHERE name = ? LIMIT ? OFFSET ?",
+		},
+		{
+			"ROLLBACK TO SAVEPOINT sp_1",
+			"ROLLBACK TO SAVEPOINT sp_1",
+		},
+	} {
+		if got := sanitizeSQL(tc.query); got != tc.want {
+			t.Errorf("sanitizeSQL(%q)\n got %q\nwant %q", tc.query, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+
+	"promo-api/tracing"
+)
+
+// DBTX is the query interface shared by *sqlx.DB and *sqlx.Tx.
+type DBTX interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+var (
+	_ DBTX = (*sqlx.DB)(nil)
+	_ DBTX = (*sqlx.Tx)(nil)
+)
+
+type txKey struct{}
+
+// txState is carried by the context of a transaction. depth counts the
+// savepoints opened by nested WithTx calls.
+type txState struct {
+	tx    *sqlx.Tx
+	depth int
+}
+
+// Transactor runs a function inside a transaction. Repositories called with
+// the context passed to fn take part in that transaction.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type SQLTransactor struct {
+	DB *sqlx.DB
+}
+
+var _ Transactor = &SQLTransactor{}
+
+// WithTx commits when fn succeeds and rolls back when it fails or panics.
+// Calls made with a context that already carries a transaction run inside a
+// savepoint of it instead: a failing nested call only undoes its own work,
+// and the caller decides whether the outer transaction still commits.
+func (t *SQLTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if outer, ok := ctx.Value(txKey{}).(*txState); ok {
+		return withSavepoint(ctx, outer, fn)
+	}
+
+	ctx, end := tracing.Start(ctx, "transaction")
+	defer end(&err)
+
+	tx, err := t.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(ctx, tx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx})); err != nil {
+		rollback(ctx, tx)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// rollback rolls tx back. A failure is only logged: the caller is already
+// returning the error that caused the rollback, and the server discards the
+// transaction when the connection is reset anyway.
+func rollback(ctx context.Context, tx *sqlx.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		slog.ErrorContext(ctx, "Failed to roll back transaction", "error", err)
+	}
+}
+
+// withSavepoint runs fn inside a savepoint of the outer transaction, rolling
+// back to it when fn fails or panics and releasing it otherwise.
+func withSavepoint(ctx context.Context, outer *txState, fn func(ctx context.Context) error) error {
+	inner := &txState{tx: outer.tx, depth: outer.depth + 1}
+	name := fmt.Sprintf("sp_%d", inner.depth)
+
+	if _, err := outer.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			outer.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, inner)); err != nil {
+		if _, rbErr := outer.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	if _, err := outer.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// executor returns the transaction carried by ctx, or db outside of one.
+func executor(ctx context.Context, db *sqlx.DB) DBTX {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return db
+}
+
+type trackedTxKey struct{}
+
+// trackedTx is carried by the context of a transaction run by TrackTx.
+type trackedTx struct {
+	mu    sync.Mutex
+	after []func()
+}
+
+// TrackTx wraps t so InTx recognizes the contexts of its transactions and
+// AfterTx can defer work until they commit, whatever the backend. Caches need
+// both: they must not serve or keep what a transaction reads, which may be
+// rolled back, and must drop rows only once the new values are visible.
+func TrackTx(t Transactor) Transactor {
+	return txTracker{t}
+}
+
+type txTracker struct {
+	Transactor
+}
+
+// WithTx runs the AfterTx work of fn once the outermost transaction commits.
+// A nested call that fails rolls back its savepoint, and its work is dropped
+// with it.
+func (t txTracker) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	outer, nested := ctx.Value(trackedTxKey{}).(*trackedTx)
+
+	tracked := &trackedTx{}
+	if err := t.Transactor.WithTx(context.WithValue(ctx, trackedTxKey{}, tracked), fn); err != nil {
+		return err
+	}
+	if nested {
+		outer.mu.Lock()
+		defer outer.mu.Unlock()
+		outer.after = append(outer.after, tracked.after...)
+		return nil
+	}
+	for _, fn := range tracked.after {
+		fn()
+	}
+	return nil
+}
+
+// InTx reports whether ctx belongs to a transaction run by TrackTx.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(trackedTxKey{}).(*trackedTx)
+	return ok
+}
+
+// AfterTx runs fn once the transaction run by TrackTx that ctx belongs to
+// has committed, or at once outside of one. fn never runs if the transaction,
+// or the nested call that registered it, rolls back.
+func AfterTx(ctx context.Context, fn func()) {
+	tracked, ok := ctx.Value(trackedTxKey{}).(*trackedTx)
+	if !ok {
+		fn()
+		return
+	}
+	tracked.mu.Lock()
+	defer tracked.mu.Unlock()
+	tracked.after = append(tracked.after, fn)
+}
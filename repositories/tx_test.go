@@ -0,0 +1,195 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/repositories/memory"
+)
+
+var errAbort = errors.New("abort")
+
+// trackedStore is a memory store whose transactions run through TrackTx, as
+// in the application.
+func trackedStore() (repositories.Transactor, *memory.CompanyRepository) {
+	store := memory.NewStore()
+	return repositories.TrackTx(store), &memory.CompanyRepository{Store: store}
+}
+
+func newCompany(cnpj string) *models.Company {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	return &models.Company{ID: uuid.New(), Name: "Company " + cnpj, Cnpj: cnpj, APIKey: uuid.NewString(), IsActive: true, CreatedAt: now, UpdatedAt: now}
+}
+
+func stored(t *testing.T, companies *memory.CompanyRepository, company *models.Company) bool {
+	t.Helper()
+	_, err := companies.FindByID(context.Background(), company.ID)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("FindByID: %v", err)
+	}
+	return err == nil
+}
+
+func TestTrackTxRunsAfterTxOnCommit(t *testing.T) {
+	tx, companies := trackedStore()
+	var ran []string
+
+	err := tx.WithTx(context.Background(), func(ctx context.Context) error {
+		if !repositories.InTx(ctx) {
+			t.Error("InTx is false inside the transaction")
+		}
+		repositories.AfterTx(ctx, func() { ran = append(ran, "outer") })
+		err := tx.WithTx(ctx, func(ctx context.Context) error {
+			repositories.AfterTx(ctx, func() { ran = append(ran, "inner") })
+			return companies.CreateCompany(ctx, newCompany("1"))
+		})
+		if len(ran) != 0 {
+			t.Errorf("AfterTx ran %v before the transaction committed", ran)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if !slices.Equal(ran, []string{"outer", "inner"}) {
+		t.Errorf("AfterTx ran %v, want [outer inner]", ran)
+	}
+
+	ran = nil
+	repositories.AfterTx(context.Background(), func() { ran = append(ran, "now") })
+	if len(ran) != 1 {
+		t.Error("AfterTx outside a transaction did not run at once")
+	}
+}
+
+func TestTrackTxSkipsAfterTxOnRollback(t *testing.T) {
+	tx, companies := trackedStore()
+	company := newCompany("1")
+	ran := false
+
+	err := tx.WithTx(context.Background(), func(ctx context.Context) error {
+		repositories.AfterTx(ctx, func() { ran = true })
+		if err := companies.CreateCompany(ctx, company); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx = %v, want %v", err, errAbort)
+	}
+	if ran {
+		t.Error("AfterTx ran after a rollback")
+	}
+	if stored(t, companies, company) {
+		t.Error("a rolled back company was stored")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic did not reach the caller")
+			}
+		}()
+		tx.WithTx(context.Background(), func(ctx context.Context) error {
+			repositories.AfterTx(ctx, func() { ran = true })
+			companies.CreateCompany(ctx, company)
+			panic("boom")
+		})
+	}()
+	if ran {
+		t.Error("AfterTx ran after a panic")
+	}
+	if stored(t, companies, company) {
+		t.Error("the company of a panicking transaction was stored")
+	}
+}
+
+// TestTrackTxNestedFailure checks that a failing nested call only undoes its
+// own writes and drops its own AfterTx work, while the outer transaction
+// still commits.
+func TestTrackTxNestedFailure(t *testing.T) {
+	tx, companies := trackedStore()
+	outer, middle, inner := newCompany("1"), newCompany("2"), newCompany("3")
+	var ran []string
+
+	err := tx.WithTx(context.Background(), func(ctx context.Context) error {
+		repositories.AfterTx(ctx, func() { ran = append(ran, "outer") })
+		if err := companies.CreateCompany(ctx, outer); err != nil {
+			return err
+		}
+		err := tx.WithTx(ctx, func(ctx context.Context) error {
+			repositories.AfterTx(ctx, func() { ran = append(ran, "middle") })
+			if err := companies.CreateCompany(ctx, middle); err != nil {
+				return err
+			}
+			// The innermost call succeeds, but its savepoint is rolled
+			// back with the middle one.
+			if err := tx.WithTx(ctx, func(ctx context.Context) error {
+				repositories.AfterTx(ctx, func() { ran = append(ran, "inner") })
+				return companies.CreateCompany(ctx, inner)
+			}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Errorf("nested WithTx = %v, want %v", err, errAbort)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if !stored(t, companies, outer) {
+		t.Error("the outer company was not committed")
+	}
+	if stored(t, companies, middle) || stored(t, companies, inner) {
+		t.Error("the companies of the failed nested call were stored")
+	}
+	if !slices.Equal(ran, []string{"outer"}) {
+		t.Errorf("AfterTx ran %v, want [outer]", ran)
+	}
+}
+
+// TestTrackTxNestedPanic checks that a panic in a nested call rolls back its
+// savepoint, and that the outer transaction commits when it recovers.
+func TestTrackTxNestedPanic(t *testing.T) {
+	tx, companies := trackedStore()
+	outer, inner := newCompany("1"), newCompany("2")
+	var ran []string
+
+	err := tx.WithTx(context.Background(), func(ctx context.Context) error {
+		repositories.AfterTx(ctx, func() { ran = append(ran, "outer") })
+		if err := companies.CreateCompany(ctx, outer); err != nil {
+			return err
+		}
+		func() {
+			defer func() { recover() }()
+			tx.WithTx(ctx, func(ctx context.Context) error {
+				repositories.AfterTx(ctx, func() { ran = append(ran, "inner") })
+				companies.CreateCompany(ctx, inner)
+				panic("boom")
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if !stored(t, companies, outer) {
+		t.Error("the outer company was not committed")
+	}
+	if stored(t, companies, inner) {
+		t.Error("the company of the panicking nested call was stored")
+	}
+	if !slices.Equal(ran, []string{"outer"}) {
+		t.Errorf("AfterTx ran %v, want [outer]", ran)
+	}
+}
@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"promo-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type WebhookRepositoryInterface interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error)
+	FindByCompany(ctx context.Context, companyID uuid.UUID) ([]models.Webhook, error)
+	FindSubscribed(ctx context.Context, companyID uuid.UUID, eventType string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	FindDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error)
+	FindDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error)
+	FindDeadDeliveries(ctx context.Context, companyID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error)
+	ClaimDueDeliveries(ctx context.Context, at, until time.Time, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+type WebhookRepository struct {
+	DB *sqlx.DB
+}
+
+var _ WebhookRepositoryInterface = &WebhookRepository{}
+
+func (r *WebhookRepository) db(ctx context.Context) DBTX {
+	return traced(executor(ctx, r.DB))
+}
+
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (
+			id, company_id, url, secret, event_types, is_active, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)`
+	_, err := r.db(ctx).ExecContext(ctx, query,
+		webhook.ID, webhook.CompanyID, webhook.URL, webhook.Secret, webhook.EventTypes,
+		webhook.IsActive, webhook.CreatedAt, webhook.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	var webhook models.Webhook
+	query := "SELECT * FROM webhooks WHERE id = $1"
+	err := r.db(ctx).GetContext(ctx, &webhook, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("webhook not found with ID %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook with ID %s: %w", id, err)
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) FindByCompany(ctx context.Context, companyID uuid.UUID) ([]models.Webhook, error) {
+	webhooks := []models.Webhook{}
+	query := "SELECT * FROM webhooks WHERE company_id = $1 ORDER BY created_at, id"
+	err := r.db(ctx).SelectContext(ctx, &webhooks, query, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks of company %s: %w", companyID, err)
+	}
+	return webhooks, nil
+}
+
+// FindSubscribed returns the active webhooks of the company that receive
+// events of the given type.
+func (r *WebhookRepository) FindSubscribed(ctx context.Context, companyID uuid.UUID, eventType string) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	query := `
+		SELECT * FROM webhooks
+		WHERE company_id = $1 AND is_active AND (cardinality(event_types) = 0 OR $2 = ANY (event_types))`
+	err := r.db(ctx).SelectContext(ctx, &webhooks, query, companyID, eventType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks subscribed to %s: %w", eventType, err)
+	}
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db(ctx).ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook with ID %s: %w", id, err)
+	}
+	return expectAffected(result, fmt.Sprintf("webhook %s", id))
+}
+
+// CreateDelivery enqueues a delivery. Enqueuing the same event for the same
+// webhook twice is a no-op.
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (
+			id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			last_status_code, last_error, delivered_at, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+		)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`
+	_, err := r.db(ctx).ExecContext(ctx, query,
+		delivery.ID, delivery.WebhookID, delivery.EventID, delivery.EventType, string(delivery.Payload),
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode,
+		delivery.LastError, delivery.DeliveredAt, delivery.CreatedAt, delivery.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) FindDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	query := "SELECT * FROM webhook_deliveries WHERE id = $1"
+	err := r.db(ctx).GetContext(ctx, &delivery, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("webhook delivery not found with ID %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook delivery with ID %s: %w", id, err)
+	}
+	return &delivery, nil
+}
+
+// FindDeliveries lists the deliveries of a webhook, newest first. An empty
+// status matches every delivery.
+func (r *WebhookRepository) FindDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	query := `
+		SELECT * FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4`
+	err := r.db(ctx).SelectContext(ctx, &deliveries, query, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deliveries of webhook %s: %w", webhookID, err)
+	}
+	return deliveries, nil
+}
+
+// FindDeadDeliveries lists the dead-lettered deliveries across the webhooks
+// of a company, newest first.
+func (r *WebhookRepository) FindDeadDeliveries(ctx context.Context, companyID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	query := `
+		SELECT d.* FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE w.company_id = $1 AND d.status = $2
+		ORDER BY d.updated_at DESC, d.id
+		LIMIT $3 OFFSET $4`
+	err := r.db(ctx).SelectContext(ctx, &deliveries, query, companyID, models.WebhookDeliveryDead, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch dead webhook deliveries of company %s: %w", companyID, err)
+	}
+	return deliveries, nil
+}
+
+// ClaimDueDeliveries returns pending deliveries whose next attempt is due at
+// the given instant, oldest first, and postpones their next attempt to
+// until. Every instance dispatches webhooks, and the claim keeps them from
+// sending the same delivery: claimed rows are not due again until the claim
+// expires, which only matters if the claimer dies before recording the
+// attempt. Rows being claimed by another instance are skipped.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, at, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	query := `
+		WITH due AS (
+			SELECT id, next_attempt_at AS due_at FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= $2
+			ORDER BY next_attempt_at, id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d SET next_attempt_at = $3
+			FROM due WHERE d.id = due.id
+			RETURNING d.*
+		)
+		SELECT claimed.* FROM claimed JOIN due ON due.id = claimed.id
+		ORDER BY due.due_at, claimed.id`
+	err := r.db(ctx).SelectContext(ctx, &deliveries, query, models.WebhookDeliveryPending, at, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5,
+			delivered_at = $6, updated_at = $7
+		WHERE id = $8`
+	result, err := r.db(ctx).ExecContext(ctx, query,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError,
+		delivery.DeliveredAt, delivery.UpdatedAt, delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery %s: %w", delivery.ID, err)
+	}
+	return expectAffected(result, fmt.Sprintf("webhook delivery %s", delivery.ID))
+}
@@ -0,0 +1,69 @@
+// Package requestctx carries who made a request, and from where, in its
+// context. The middlewares fill it in; services read it without depending on
+// the HTTP layer.
+package requestctx
+
+import (
+	"context"
+
+	"promo-api/models"
+)
+
+type (
+	companyKey    struct{}
+	adminKey      struct{}
+	apiKeyHintKey struct{}
+	requestKey    struct{}
+)
+
+// WithCompany returns a context authenticated as company.
+func WithCompany(ctx context.Context, company *models.Company) context.Context {
+	return context.WithValue(ctx, companyKey{}, company)
+}
+
+// Company returns the company the request was authenticated as.
+func Company(ctx context.Context) (*models.Company, bool) {
+	company, ok := ctx.Value(companyKey{}).(*models.Company)
+	return company, ok && company != nil
+}
+
+// WithAdmin returns a context authenticated with the admin key.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin reports whether the request was authenticated with the admin key.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
+
+// WithAPIKeyHint returns a context carrying the hint of the API key used to
+// authenticate.
+func WithAPIKeyHint(ctx context.Context, hint string) context.Context {
+	return context.WithValue(ctx, apiKeyHintKey{}, hint)
+}
+
+// APIKeyHint returns the last characters of the API key used to
+// authenticate, enough to tell keys apart without revealing them.
+func APIKeyHint(ctx context.Context) (string, bool) {
+	hint, ok := ctx.Value(apiKeyHintKey{}).(string)
+	return hint, ok
+}
+
+// RequestInfo identifies the request behind a change for the audit log.
+type RequestInfo struct {
+	ID string
+	IP string
+}
+
+// WithRequestInfo returns a context carrying info.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestKey{}, info)
+}
+
+// Request returns the request details captured by the middlewares.
+func Request(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestKey{}).(RequestInfo)
+	return info, ok
+}
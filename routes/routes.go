@@ -11,10 +11,20 @@ import (
 func ConfigurePromotionRoutes(r *mux.Router, controller *controllers.PromotionController) {
 	r.HandleFunc("/promotions", controller.CreatePromotion).Methods(http.MethodPost)
 	r.HandleFunc("/promotions", controller.GetAllPromotions).Methods(http.MethodGet)
-	r.HandleFunc("/promotions/{id}", controller.GetPromotion).Methods(http.MethodGet)
+	// Registered before /promotions/{id} so "coupon" is not parsed as an ID.
 	r.HandleFunc("/promotions/coupon", controller.GetPromotionsByCoupon).Methods(http.MethodGet)
+	r.HandleFunc("/promotions/{id}", controller.GetPromotion).Methods(http.MethodGet)
 	r.HandleFunc("/promotions/{id}", controller.UpdatePromotion).Methods(http.MethodPut)
+	r.HandleFunc("/promotions/{id}", controller.PatchPromotion).Methods(http.MethodPatch)
 	r.HandleFunc("/promotions/{id}", controller.DeletePromotion).Methods(http.MethodDelete)
+	r.HandleFunc("/promotions/{id}:pause", controller.PausePromotion).Methods(http.MethodPost)
+	r.HandleFunc("/promotions/{id}:resume", controller.ResumePromotion).Methods(http.MethodPost)
+	r.HandleFunc("/promotions/{id}:archive", controller.ArchivePromotion).Methods(http.MethodPost)
+	r.HandleFunc("/promotions/{id}:restore", controller.RestorePromotion).Methods(http.MethodPost)
+	r.HandleFunc("/promotions/{id}:redeem", controller.RedeemPromotion).Methods(http.MethodPost)
+	r.HandleFunc("/promotions/{id}/transitions", controller.GetPromotionTransitions).Methods(http.MethodGet)
+	r.HandleFunc("/promotions/{id}/revisions", controller.GetPromotionRevisions).Methods(http.MethodGet)
+	r.HandleFunc("/promotions/{id}/revisions/{revision}:rollback", controller.RollbackPromotion).Methods(http.MethodPost)
 }
 
 func ConfigureCompanyRoutes(r *mux.Router, controller *controllers.CompanyController) {
@@ -22,6 +32,35 @@ func ConfigureCompanyRoutes(r *mux.Router, controller *controllers.CompanyContro
 	r.HandleFunc("/companies", controller.GetAllCompanies).Methods(http.MethodGet)
 	r.HandleFunc("/companies/{id}", controller.GetCompany).Methods(http.MethodGet)
 	r.HandleFunc("/companies/{id}", controller.UpdateCompany).Methods(http.MethodPut)
+	r.HandleFunc("/companies/{id}", controller.PatchCompany).Methods(http.MethodPatch)
 	r.HandleFunc("/companies/{id}", controller.DeactivateCompany).Methods(http.MethodDelete)
 	r.HandleFunc("/companies/{id}/rotate-api-key", controller.RotateAPIKey).Methods(http.MethodPost)
 }
+
+func ConfigureAuditRoutes(r *mux.Router, controller *controllers.AuditController) {
+	r.HandleFunc("/audit-events", controller.GetAuditEvents).Methods(http.MethodGet)
+}
+
+func ConfigureWebhookRoutes(r *mux.Router, controller *controllers.WebhookController) {
+	r.HandleFunc("/webhooks", controller.CreateWebhook).Methods(http.MethodPost)
+	r.HandleFunc("/webhooks", controller.GetWebhooks).Methods(http.MethodGet)
+	// Registered before /webhooks/{id} so "dead-letters" is not parsed as an ID.
+	r.HandleFunc("/webhooks/dead-letters", controller.GetDeadDeliveries).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{id}", controller.GetWebhook).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{id}", controller.DeleteWebhook).Methods(http.MethodDelete)
+	r.HandleFunc("/webhooks/{id}/deliveries", controller.GetDeliveries).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{id}/deliveries/{delivery_id}:redeliver", controller.Redeliver).Methods(http.MethodPost)
+}
+
+// ConfigureHealthRoutes registers the probes. They go on the root router,
+// outside the authenticated subrouter, so orchestrators need no API key.
+func ConfigureHealthRoutes(r *mux.Router, controller *controllers.HealthController) {
+	r.HandleFunc("/healthz", controller.Healthz).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/readyz", controller.Readyz).Methods(http.MethodGet, http.MethodHead)
+}
+
+// ConfigureMetricsRoutes registers the Prometheus scrape endpoint, also
+// outside authentication. Keep it off public listeners.
+func ConfigureMetricsRoutes(r *mux.Router, handler http.Handler) {
+	r.Handle("/metrics", handler).Methods(http.MethodGet)
+}
@@ -0,0 +1,126 @@
+// Package server runs the HTTP server with production timeouts and shuts it
+// down gracefully, draining in-flight requests, when its context is done.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"promo-api/config"
+)
+
+// Options configure the HTTP server. OptionsFromConfig fills them from the
+// loaded configuration.
+type Options struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// once shutdown starts. Connections still open after it are closed.
+	ShutdownTimeout time.Duration
+	// ShutdownDelay keeps accepting requests for a while after shutdown
+	// starts, with OnShutdown already called, so load balancers can observe
+	// the failing readiness probe and stop routing here first.
+	ShutdownDelay time.Duration
+	OnShutdown    func()
+}
+
+func OptionsFromConfig(cfg config.HTTPConfig) Options {
+	return Options{
+		Addr:              cfg.Addr,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ShutdownTimeout:   cfg.ShutdownTimeout,
+		ShutdownDelay:     cfg.ShutdownDelay,
+	}
+}
+
+// Server is an http.Server that stops when the context passed to Serve or
+// ListenAndServe is done.
+type Server struct {
+	http            *http.Server
+	shutdownTimeout time.Duration
+	shutdownDelay   time.Duration
+	onShutdown      func()
+}
+
+func New(handler http.Handler, opts Options) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:              opts.Addr,
+			Handler:           handler,
+			ReadTimeout:       opts.ReadTimeout,
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+			MaxHeaderBytes:    opts.MaxHeaderBytes,
+		},
+		shutdownTimeout: opts.ShutdownTimeout,
+		shutdownDelay:   opts.ShutdownDelay,
+		onShutdown:      opts.OnShutdown,
+	}
+}
+
+// ListenAndServe listens on the configured address and serves until ctx is
+// done, then shuts down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.http.Addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done. It then stops accepting
+// new connections and waits up to the shutdown timeout for in-flight requests
+// to finish. It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.http.Serve(ln)
+	}()
+	log.Printf("Server running on %s", ln.Addr())
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	if s.onShutdown != nil {
+		s.onShutdown()
+	}
+	if s.shutdownDelay > 0 {
+		log.Printf("Shutting down server in %s", s.shutdownDelay)
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(s.shutdownDelay):
+		}
+	}
+
+	log.Println("Shutting down server, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		s.http.Close()
+		return fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("Server stopped")
+	return nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- New(handler, Options{ShutdownTimeout: 5 * time.Second}).Serve(ctx, ln)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned before the in-flight request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("new connections are still accepted during shutdown")
+	}
+
+	close(release)
+	if got := <-responses; got.err != nil || got.body != "done" {
+		t.Errorf("in-flight response = %q, %v; want %q", got.body, got.err, "done")
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve = %v, want nil", err)
+	}
+}
+
+func TestServeShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- New(handler, Options{ShutdownTimeout: 50 * time.Millisecond}).Serve(ctx, ln)
+	}()
+	go http.Get("http://" + ln.Addr().String())
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("Serve = nil, want an error for the request that outlived the deadline")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not give up after the shutdown timeout")
+	}
+}
+
+func TestListenAndServeInvalidAddress(t *testing.T) {
+	err := New(http.NotFoundHandler(), Options{Addr: "256.0.0.1:http"}).ListenAndServe(context.Background())
+	if err == nil {
+		t.Fatal("ListenAndServe = nil, want a listen error")
+	}
+}
+
+func TestServeShutdownDelay(t *testing.T) {
+	var shuttingDown atomic.Bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- New(handler, Options{
+			ShutdownTimeout: time.Second,
+			ShutdownDelay:   200 * time.Millisecond,
+			OnShutdown:      func() { shuttingDown.Store(true) },
+		}).Serve(ctx, ln)
+	}()
+
+	cancel()
+	for !shuttingDown.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	resp, err := http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("request during the shutdown delay failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status during the shutdown delay = %d, want 503", resp.StatusCode)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve = %v, want nil", err)
+	}
+}
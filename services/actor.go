@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+
+	"promo-api/requestctx"
+)
+
+const (
+	// ActorSystem identifies changes made by background workers.
+	ActorSystem = "system"
+	// ActorAdmin identifies changes made with the admin API key.
+	ActorAdmin = "admin"
+)
+
+// actorFromContext identifies who is performing the current operation.
+func actorFromContext(ctx context.Context) string {
+	if company, ok := requestctx.Company(ctx); ok {
+		return "company:" + company.ID.String()
+	}
+	if requestctx.IsAdmin(ctx) {
+		return ActorAdmin
+	}
+	return ActorSystem
+}
+
+// companyLabel identifies who is performing the current operation in metric
+// labels: the company ID, admin or system.
+func companyLabel(ctx context.Context) string {
+	if company, ok := requestctx.Company(ctx); ok {
+		return company.ID.String()
+	}
+	if requestctx.IsAdmin(ctx) {
+		return ActorAdmin
+	}
+	return ActorSystem
+}
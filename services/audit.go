@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/requestctx"
+	"promo-api/tracing"
+)
+
+// redactedAuditFields never have their values written to the audit log.
+var redactedAuditFields = map[string]bool{
+	"api_key": true,
+}
+
+const redacted = "[redacted]"
+
+type AuditServiceInterface interface {
+	GetAuditEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEvent, error)
+}
+
+type AuditService struct {
+	Repo repositories.AuditRepositoryInterface
+}
+
+var _ AuditServiceInterface = &AuditService{}
+
+func (s *AuditService) GetAuditEvents(ctx context.Context, filter models.AuditFilter, limit, offset int) (_ []models.AuditEvent, err error) {
+	ctx, end := tracing.Start(ctx, "AuditService.GetAuditEvents")
+	defer end(&err)
+
+	events, err := s.Repo.FindEvents(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+	return events, nil
+}
+
+// recordAudit appends an audit event describing a mutation. Call it with the
+// transaction context of the change so both commit together.
+func recordAudit(ctx context.Context, repo repositories.AuditRepositoryInterface, action, resourceType string, resourceID uuid.UUID, before, after any, at time.Time) error {
+	if repo == nil {
+		return nil
+	}
+
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		return fmt.Errorf("failed to diff %s %s: %w", resourceType, resourceID, err)
+	}
+
+	event := &models.AuditEvent{
+		ID:           uuid.New(),
+		Actor:        actorFromContext(ctx),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Changes:      changes,
+		CreatedAt:    at,
+	}
+	if hint, ok := requestctx.APIKeyHint(ctx); ok {
+		event.APIKeyHint = &hint
+	}
+	if info, ok := requestctx.Request(ctx); ok {
+		if info.ID != "" {
+			event.RequestID = &info.ID
+		}
+		if info.IP != "" {
+			event.IP = &info.IP
+		}
+	}
+
+	if err := repo.CreateEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to audit %s of %s %s: %w", action, resourceType, resourceID, err)
+	}
+	return nil
+}
+
+// auditChanges diffs the JSON representations of before and after, either of
+// which may be nil, into a map of field to [before, after].
+func auditChanges(before, after any) (json.RawMessage, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string][2]any{}
+	for field, value := range afterFields {
+		if !reflect.DeepEqual(beforeFields[field], value) {
+			changes[field] = [2]any{beforeFields[field], value}
+		}
+	}
+	for field, value := range beforeFields {
+		if _, ok := afterFields[field]; !ok {
+			changes[field] = [2]any{value, nil}
+		}
+	}
+
+	for field, change := range changes {
+		if redactedAuditFields[field] {
+			for i := range change {
+				if change[i] != nil {
+					change[i] = redacted
+				}
+			}
+			changes[field] = change
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+func jsonFields(value any) (map[string]any, error) {
+	fields := map[string]any{}
+	if value == nil {
+		return fields, nil
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
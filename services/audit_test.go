@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/repositories/memory"
+	"promo-api/requestctx"
+)
+
+// failingOutbox rejects every event, failing the transaction that enqueues
+// it.
+type failingOutbox struct {
+	repositories.OutboxRepositoryInterface
+}
+
+func (failingOutbox) Enqueue(ctx context.Context, event models.Event) error {
+	return errors.New("outbox unavailable")
+}
+
+// auditEvents returns the audit events of resource, newest first.
+func (f *promotionFixture) auditEvents(t *testing.T, resource uuid.UUID) []models.AuditEvent {
+	t.Helper()
+	events, err := (&AuditService{Repo: f.service.Audit}).GetAuditEvents(f.ctx, models.AuditFilter{ResourceID: &resource}, 10, 0)
+	if err != nil {
+		t.Fatalf("GetAuditEvents: %v", err)
+	}
+	return events
+}
+
+func TestPromotionChangesAreAudited(t *testing.T) {
+	f := newPromotionFixture(t)
+	company := &models.Company{ID: uuid.New(), Cnpj: "12345678000190"}
+	if err := (&memory.CompanyRepository{Store: f.store}).CreateCompany(f.ctx, company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	f.ctx = requestctx.WithCompany(f.ctx, company)
+	f.ctx = requestctx.WithAPIKeyHint(f.ctx, "...cafe")
+	f.ctx = requestctx.WithRequestInfo(f.ctx, requestctx.RequestInfo{ID: "req-1", IP: "203.0.113.7"})
+
+	promotion := f.create(t, nil)
+	if _, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"title":"Summer sale"}`), 0); err != nil {
+		t.Fatalf("PatchPromotion: %v", err)
+	}
+	if err := f.service.DeletePromotion(f.ctx, promotion.ID); err != nil {
+		t.Fatalf("DeletePromotion: %v", err)
+	}
+
+	events := f.auditEvents(t, promotion.ID)
+	actions := map[string]models.AuditEvent{}
+	for _, event := range events {
+		actions[event.Action] = event
+		if event.Actor != "company:"+company.ID.String() || event.ResourceType != models.ResourcePromotion {
+			t.Errorf("%s event by %s on %s, want the company on a promotion", event.Action, event.Actor, event.ResourceType)
+		}
+		if event.APIKeyHint == nil || *event.APIKeyHint != "...cafe" ||
+			event.RequestID == nil || *event.RequestID != "req-1" || event.IP == nil || *event.IP != "203.0.113.7" {
+			t.Errorf("%s event is missing the request details: %+v", event.Action, event)
+		}
+		if !event.CreatedAt.Equal(f.clock.now) {
+			t.Errorf("%s event at %v, want %v", event.Action, event.CreatedAt, f.clock.now)
+		}
+	}
+	if len(events) != 3 || len(actions) != 3 {
+		t.Fatalf("got %d audit events, want create, update and delete", len(events))
+	}
+
+	changes := func(action string) map[string][2]any {
+		t.Helper()
+		var changes map[string][2]any
+		if err := json.Unmarshal(actions[action].Changes, &changes); err != nil {
+			t.Fatalf("%s changes: %v", action, err)
+		}
+		return changes
+	}
+	if title := changes(models.AuditActionCreate)["title"]; title[0] != nil || title[1] != "Spring sale" {
+		t.Errorf("create changed the title %v, want from nothing to Spring sale", title)
+	}
+	update := changes(models.AuditActionUpdate)
+	if title := update["title"]; title[0] != "Spring sale" || title[1] != "Summer sale" {
+		t.Errorf("update changed the title %v, want from Spring sale to Summer sale", title)
+	}
+	if _, ok := update["discount_value"]; ok {
+		t.Error("update recorded the unchanged discount_value")
+	}
+	if deleted := changes(models.AuditActionDelete)["deleted_at"]; deleted[0] != nil || deleted[1] == nil {
+		t.Errorf("delete changed deleted_at %v, want it set", deleted)
+	}
+}
+
+func TestAuditRollsBackWithTheChange(t *testing.T) {
+	f := newPromotionFixture(t)
+	promotion := f.create(t, nil)
+	f.service.Outbox = failingOutbox{}
+
+	if _, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"title":"Summer sale"}`), 0); err == nil {
+		t.Fatal("PatchPromotion succeeded with the outbox down")
+	}
+	if err := f.service.DeletePromotion(f.ctx, promotion.ID); err == nil {
+		t.Fatal("DeletePromotion succeeded with the outbox down")
+	}
+
+	events := f.auditEvents(t, promotion.ID)
+	if len(events) != 1 || events[0].Action != models.AuditActionCreate || events[0].Actor != ActorSystem {
+		t.Errorf("got %d audit events, want only the system's create", len(events))
+	}
+	stored, err := f.service.GetPromotion(f.ctx, promotion.ID, false)
+	if err != nil {
+		t.Fatalf("GetPromotion: %v", err)
+	}
+	if stored.Title != "Spring sale" {
+		t.Errorf("title = %q, want the failed update rolled back", stored.Title)
+	}
+}
@@ -2,14 +2,19 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
 	"promo-api/models"
 	"promo-api/repositories"
+	"promo-api/requestctx"
+	"promo-api/tracing"
+	"promo-api/utils"
 )
 
 type CompanyServiceInterface interface {
@@ -19,100 +24,335 @@ type CompanyServiceInterface interface {
 	GetCompanyByCnpj(ctx context.Context, cnpj string) (*models.Company, error)
 	GetAllCompanies(ctx context.Context, limit, offset int) ([]models.Company, error)
 	UpdateCompany(ctx context.Context, company *models.Company) error
+	PatchCompany(ctx context.Context, id uuid.UUID, patch []byte, version int) (*models.Company, error)
 	DeactivateCompany(ctx context.Context, id uuid.UUID) error
 	RotateAPIKey(ctx context.Context, id uuid.UUID) (string, error)
 }
 
 type CompanyService struct {
-	Repo repositories.CompanyRepositoryInterface
+	Repo   repositories.CompanyRepositoryInterface
+	Audit  repositories.AuditRepositoryInterface
+	Tx     repositories.Transactor
+	Clock  utils.Clock
+	Outbox repositories.OutboxRepositoryInterface
 }
 
 var _ CompanyServiceInterface = &CompanyService{}
 
-func (s *CompanyService) CreateCompany(ctx context.Context, company *models.Company) error {
+func (s *CompanyService) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// publish enqueues a company event in the outbox. Call it inside the
+// transaction that makes the change. API keys never appear in events.
+func (s *CompanyService) publish(ctx context.Context, eventType string, company *models.Company) error {
+	data, err := jsonFields(company)
+	if err != nil {
+		return fmt.Errorf("failed to build %s event: %w", eventType, err)
+	}
+	delete(data, "api_key")
+	return enqueueEvent(ctx, s.Outbox, eventType, models.AggregateCompany, company.ID, &company.ID, s.now(), data)
+}
+
+// normalizeTimezone defaults the company time zone to UTC and rejects names
+// that are not in the IANA database.
+func normalizeTimezone(company *models.Company) error {
+	if company.Timezone == "" {
+		company.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(company.Timezone); err != nil {
+		return fmt.Errorf("invalid company timezone %q", company.Timezone)
+	}
+	return nil
+}
+
+// checkRateLimits only lets the administrator choose a company's rate
+// limits; for anyone else they keep the stored values, nil for a new
+// company. Chosen limits must be positive.
+func checkRateLimits(ctx context.Context, existing, company *models.Company) error {
+	if !requestctx.IsAdmin(ctx) {
+		company.RateLimit, company.RateLimitBurst = nil, nil
+		if existing != nil {
+			company.RateLimit, company.RateLimitBurst = existing.RateLimit, existing.RateLimitBurst
+		}
+		return nil
+	}
+	if company.RateLimit != nil && *company.RateLimit <= 0 {
+		return errors.New("company rate_limit must be positive")
+	}
+	if company.RateLimitBurst != nil && *company.RateLimitBurst <= 0 {
+		return errors.New("company rate_limit_burst must be positive")
+	}
+	return nil
+}
+
+// CreateCompany onboards a company in one transaction: it issues the API key,
+// rejects a CNPJ already held by a live company with ErrDuplicate and
+// inserts the company. On success company.APIKey holds the key; it is the
+// only time the key is returned.
+func (s *CompanyService) CreateCompany(ctx context.Context, company *models.Company) (err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.CreateCompany")
+	defer end(&err)
+
+	company.Cnpj = strings.TrimSpace(company.Cnpj)
 	if company.Name == "" {
 		return errors.New("company name is required")
 	}
 	if company.Cnpj == "" {
 		return errors.New("company CNPJ is required")
 	}
+	if err := normalizeTimezone(company); err != nil {
+		return err
+	}
+	if err := checkRateLimits(ctx, nil, company); err != nil {
+		return err
+	}
+
+	apiKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate API key: %w", err)
+	}
 
 	company.ID = uuid.New()
+	company.APIKey = apiKey
+	company.Version = 1
 	company.IsActive = true
-	now := time.Now()
+	now := s.now()
 	company.CreatedAt = now
 	company.UpdatedAt = now
 
-	newKey, err := s.Repo.RotateAPIKey(ctx, company.ID)
-	if err != nil {
-		return fmt.Errorf("failed to generate initial API key: %w", err)
-	}
-	company.APIKey = newKey
+	return withTx(ctx, s.Tx, func(ctx context.Context) error {
+		// The unique index on live CNPJs settles races between this check
+		// and the insert; the repository reports them as ErrDuplicate too.
+		_, err := s.Repo.FindByCnpj(ctx, company.Cnpj)
+		if err == nil {
+			return fmt.Errorf("company with CNPJ %s: %w", company.Cnpj, ErrDuplicate)
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to create company: %w", err)
+		}
 
-	if err := s.Repo.CreateCompany(ctx, company); err != nil {
-		return fmt.Errorf("failed to create company: %w", err)
-	}
-	return nil
+		if err := s.Repo.CreateCompany(ctx, company); err != nil {
+			return fmt.Errorf("failed to create company: %w", err)
+		}
+		if err := recordAudit(ctx, s.Audit, models.AuditActionCreate, models.ResourceCompany, company.ID, nil, company, now); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventCompanyCreated, company)
+	})
 }
 
-func (s *CompanyService) GetCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+func (s *CompanyService) GetCompany(ctx context.Context, id uuid.UUID) (_ *models.Company, err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.GetCompany")
+	defer end(&err)
+
 	company, err := s.Repo.FindByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get company: %w", err)
 	}
+	company.APIKey = ""
 	return company, nil
 }
 
-func (s *CompanyService) GetCompanyByAPIKey(ctx context.Context, apiKey string) (*models.Company, error) {
+func (s *CompanyService) GetCompanyByAPIKey(ctx context.Context, apiKey string) (_ *models.Company, err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.GetCompanyByAPIKey")
+	defer end(&err)
+
 	company, err := s.Repo.FindByAPIKey(ctx, apiKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get company by API key: %w", err)
 	}
+	company.APIKey = ""
 	return company, nil
 }
 
-func (s *CompanyService) GetCompanyByCnpj(ctx context.Context, cnpj string) (*models.Company, error) {
+func (s *CompanyService) GetCompanyByCnpj(ctx context.Context, cnpj string) (_ *models.Company, err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.GetCompanyByCnpj")
+	defer end(&err)
+
 	company, err := s.Repo.FindByCnpj(ctx, cnpj)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get company by CNPJ: %w", err)
 	}
+	company.APIKey = ""
 	return company, nil
 }
 
-func (s *CompanyService) GetAllCompanies(ctx context.Context, limit, offset int) ([]models.Company, error) {
+func (s *CompanyService) GetAllCompanies(ctx context.Context, limit, offset int) (_ []models.Company, err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.GetAllCompanies")
+	defer end(&err)
+
 	companies, err := s.Repo.FindAll(ctx, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get companies: %w", err)
 	}
+	for i := range companies {
+		companies[i].APIKey = ""
+	}
 	return companies, nil
 }
 
-func (s *CompanyService) UpdateCompany(ctx context.Context, company *models.Company) error {
+// UpdateCompany replaces the client-managed fields of a company. A non-zero
+// company.Version must match the stored version.
+// Server-managed fields, including the API key, keep their stored values.
+func (s *CompanyService) UpdateCompany(ctx context.Context, company *models.Company) (err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.UpdateCompany")
+	defer end(&err)
+
+	existing, err := s.Repo.FindByID(ctx, company.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update company: %w", err)
+	}
+	return s.update(ctx, existing, company)
+}
+
+// PatchCompany applies an RFC 7396 merge patch to a company. A non-zero
+// version must match the stored one.
+func (s *CompanyService) PatchCompany(ctx context.Context, id uuid.UUID, patch []byte, version int) (_ *models.Company, err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.PatchCompany")
+	defer end(&err)
+
+	readOnly := companyReadOnlyFields
+	if !requestctx.IsAdmin(ctx) {
+		readOnly = append(readOnly[:len(readOnly):len(readOnly)], companyAdminFields...)
+	}
+	if err := checkMergePatch(patch, readOnly); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch company: %w", err)
+	}
+
+	document, err := json.Marshal(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch company: %w", err)
+	}
+	merged, err := utils.MergePatch(document, patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var company models.Company
+	if err := json.Unmarshal(merged, &company); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	company.Version = version
+	if err := s.update(ctx, existing, &company); err != nil {
+		return nil, err
+	}
+	return &company, nil
+}
+
+func (s *CompanyService) update(ctx context.Context, existing, company *models.Company) error {
+	company.Cnpj = strings.TrimSpace(company.Cnpj)
 	if company.Name == "" {
 		return errors.New("company name is required")
 	}
 	if company.Cnpj == "" {
 		return errors.New("company CNPJ is required")
 	}
-	company.UpdatedAt = time.Now()
+	if err := normalizeTimezone(company); err != nil {
+		return err
+	}
+	if err := checkRateLimits(ctx, existing, company); err != nil {
+		return err
+	}
 
-	if err := s.Repo.UpdateCompany(ctx, company); err != nil {
-		return fmt.Errorf("failed to update company: %w", err)
+	if company.Version != 0 && company.Version != existing.Version {
+		return fmt.Errorf("company %s is at version %d: %w", existing.ID, existing.Version, ErrVersionConflict)
 	}
-	return nil
-}
+	// The update is conditional on the version the client sent, so the
+	// database rejects it if existing was already out of date; "*" takes
+	// the version just read.
+	if company.Version == 0 {
+		company.Version = existing.Version
+	}
+
+	company.ID = existing.ID
+	company.APIKey = existing.APIKey
+	company.CreatedAt = existing.CreatedAt
+	company.DeletedAt = existing.DeletedAt
+	now := s.now()
+	company.UpdatedAt = now
 
-func (s *CompanyService) DeactivateCompany(ctx context.Context, id uuid.UUID) error {
-	if err := s.Repo.DeactivateCompany(ctx, id); err != nil {
-		return fmt.Errorf("failed to deactivate company: %w", err)
+	err := withTx(ctx, s.Tx, func(ctx context.Context) error {
+		if err := s.Repo.UpdateCompany(ctx, company); err != nil {
+			return fmt.Errorf("failed to update company: %w", err)
+		}
+		if err := recordAudit(ctx, s.Audit, models.AuditActionUpdate, models.ResourceCompany, company.ID, existing, company, now); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventCompanyUpdated, company)
+	})
+	if err != nil {
+		return err
 	}
+	company.APIKey = ""
 	return nil
 }
 
-func (s *CompanyService) RotateAPIKey(ctx context.Context, id uuid.UUID) (string, error) {
-	newKey, err := s.Repo.RotateAPIKey(ctx, id)
+func (s *CompanyService) DeactivateCompany(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.DeactivateCompany")
+	defer end(&err)
+
+	return withTx(ctx, s.Tx, func(ctx context.Context) error {
+		before, err := s.Repo.FindByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to deactivate company: %w", err)
+		}
+		if err := s.Repo.DeactivateCompany(ctx, id); err != nil {
+			return fmt.Errorf("failed to deactivate company: %w", err)
+		}
+
+		now := s.now()
+		after := map[string]any{"is_active": false}
+		err = recordAudit(ctx, s.Audit, models.AuditActionDelete, models.ResourceCompany, id,
+			map[string]any{"is_active": before.IsActive}, after, now)
+		if err != nil {
+			return err
+		}
+
+		deactivated := *before
+		deactivated.IsActive = false
+		deactivated.DeletedAt = &now
+		deactivated.UpdatedAt = now
+		return s.publish(ctx, models.EventCompanyDeactivated, &deactivated)
+	})
+}
+
+// RotateAPIKey issues a new API key for the company and returns it. A company
+// may only rotate its own key; the administrator may rotate any.
+func (s *CompanyService) RotateAPIKey(ctx context.Context, id uuid.UUID) (_ string, err error) {
+	ctx, end := tracing.Start(ctx, "CompanyService.RotateAPIKey")
+	defer end(&err)
+
+	if company, ok := requestctx.Company(ctx); !requestctx.IsAdmin(ctx) && (!ok || company.ID != id) {
+		return "", fmt.Errorf("%w: a company may only rotate its own API key", ErrForbidden)
+	}
+
+	var newKey string
+	err = withTx(ctx, s.Tx, func(ctx context.Context) error {
+		var err error
+		newKey, err = s.Repo.RotateAPIKey(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to rotate API key: %w", err)
+		}
+
+		now := s.now()
+		before := map[string]string{"api_key": "previous"}
+		after := map[string]string{"api_key": "current"}
+		if err := recordAudit(ctx, s.Audit, models.AuditActionRotateKey, models.ResourceCompany, id, before, after, now); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, s.Outbox, models.EventCompanyAPIKeyRotated, models.AggregateCompany, id, &id, now, map[string]string{"id": id.String()})
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to rotate API key: %w", err)
+		return "", err
 	}
 	return newKey, nil
 }
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories/memory"
+)
+
+// companyRepo is an in-memory company repository that counts key rotations.
+type companyRepo struct {
+	*memory.CompanyRepository
+	rotations atomic.Int32
+}
+
+func newCompanyRepo() *companyRepo {
+	return &companyRepo{CompanyRepository: &memory.CompanyRepository{Store: memory.NewStore()}}
+}
+
+func (r *companyRepo) RotateAPIKey(ctx context.Context, id uuid.UUID) (string, error) {
+	r.rotations.Add(1)
+	return r.CompanyRepository.RotateAPIKey(ctx, id)
+}
+
+// count returns the number of live companies.
+func (r *companyRepo) count(t *testing.T) int {
+	t.Helper()
+	companies, err := r.FindAll(context.Background(), 100, 0)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	return len(companies)
+}
+
+// countingTransactor runs fn directly and counts the transactions opened.
+type countingTransactor struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (t *countingTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.mu.Lock()
+	t.n++
+	t.mu.Unlock()
+	return fn(ctx)
+}
+
+func TestCreateCompanyIssuesKeyInOneTransaction(t *testing.T) {
+	repo := newCompanyRepo()
+	tx := &countingTransactor{}
+	service := &CompanyService{Repo: repo, Tx: tx}
+
+	company := &models.Company{Name: "Acme", Cnpj: " 12345678000190 "}
+	if err := service.CreateCompany(context.Background(), company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+
+	if len(company.APIKey) != 64 {
+		t.Errorf("API key = %q, want 64 hex characters", company.APIKey)
+	}
+	if company.Cnpj != "12345678000190" {
+		t.Errorf("CNPJ = %q, want it trimmed", company.Cnpj)
+	}
+	if repo.rotations.Load() != 0 {
+		t.Errorf("RotateAPIKey called %d times during creation", repo.rotations.Load())
+	}
+	if tx.n != 1 {
+		t.Errorf("opened %d transactions, want 1", tx.n)
+	}
+
+	stored, err := repo.FindByAPIKey(context.Background(), company.APIKey)
+	if err != nil || stored.ID != company.ID {
+		t.Fatalf("the issued key does not authenticate the company: %v", err)
+	}
+
+	// The key is a one-time credential: reads never return it.
+	got, err := service.GetCompany(context.Background(), company.ID)
+	if err != nil {
+		t.Fatalf("GetCompany: %v", err)
+	}
+	if got.APIKey != "" {
+		t.Error("GetCompany returned the API key")
+	}
+}
+
+func TestCreateCompanyRejectsDuplicateCnpj(t *testing.T) {
+	repo := newCompanyRepo()
+	service := &CompanyService{Repo: repo}
+
+	first := &models.Company{Name: "Acme", Cnpj: "12345678000190"}
+	if err := service.CreateCompany(context.Background(), first); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	err := service.CreateCompany(context.Background(), &models.Company{Name: "Acme 2", Cnpj: "12345678000190"})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second CreateCompany: err = %v, want ErrDuplicate", err)
+	}
+	if n := repo.count(t); n != 1 {
+		t.Errorf("stored %d companies, want 1", n)
+	}
+
+	// A deactivated company releases its CNPJ.
+	if err := service.DeactivateCompany(context.Background(), first.ID); err != nil {
+		t.Fatalf("DeactivateCompany: %v", err)
+	}
+	if err := service.CreateCompany(context.Background(), &models.Company{Name: "Acme 3", Cnpj: "12345678000190"}); err != nil {
+		t.Errorf("CreateCompany after deactivation: %v", err)
+	}
+}
+
+func TestCreateCompanyConcurrentDuplicates(t *testing.T) {
+	repo := newCompanyRepo()
+	service := &CompanyService{Repo: repo, Tx: &countingTransactor{}}
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs <- service.CreateCompany(context.Background(), &models.Company{
+				Name: fmt.Sprintf("Acme %d", i),
+				Cnpj: "12345678000190",
+			})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrDuplicate):
+			t.Errorf("CreateCompany: err = %v, want nil or ErrDuplicate", err)
+		}
+	}
+	if n := repo.count(t); created != 1 || n != 1 {
+		t.Errorf("created %d companies (%d stored), want exactly 1", created, n)
+	}
+}
+
+func TestPatchCompanyKeepsTheAPIKey(t *testing.T) {
+	repo := newCompanyRepo()
+	service := &CompanyService{Repo: repo}
+	ctx := context.Background()
+
+	company := &models.Company{Name: "Acme", Cnpj: "12345678000190", Timezone: "America/Sao_Paulo"}
+	if err := service.CreateCompany(ctx, company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	apiKey := company.APIKey
+
+	if _, err := service.PatchCompany(ctx, company.ID, []byte(`{"api_key":"stolen"}`), 0); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("PatchCompany of api_key = %v, want ErrInvalidPatch", err)
+	}
+	if _, err := service.PatchCompany(ctx, company.ID, []byte(`{"rate_limit":1000}`), 0); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("PatchCompany of rate_limit by a company = %v, want ErrInvalidPatch", err)
+	}
+
+	patched, err := service.PatchCompany(ctx, company.ID, []byte(`{"name":"Acme Ltda"}`), 0)
+	if err != nil {
+		t.Fatalf("PatchCompany: %v", err)
+	}
+	if patched.Name != "Acme Ltda" || patched.Cnpj != company.Cnpj || patched.Timezone != company.Timezone {
+		t.Errorf("patched company = %+v, want only the name changed", patched)
+	}
+	if patched.APIKey != "" {
+		t.Error("PatchCompany returned the API key")
+	}
+	stored, err := repo.FindByAPIKey(ctx, apiKey)
+	if err != nil || stored.ID != company.ID || stored.Name != "Acme Ltda" {
+		t.Errorf("the issued key no longer authenticates the patched company: %v", err)
+	}
+}
+
+func TestUpdateCompanyChecksTheClientVersion(t *testing.T) {
+	repo := newCompanyRepo()
+	service := &CompanyService{Repo: repo}
+	ctx := context.Background()
+
+	company := &models.Company{Name: "Acme", Cnpj: "12345678000190"}
+	if err := service.CreateCompany(ctx, company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	read := company.Version
+
+	update := *company
+	update.Name = "Acme Ltda"
+	if err := service.UpdateCompany(ctx, &update); err != nil {
+		t.Fatalf("UpdateCompany at the current version: %v", err)
+	}
+
+	stale := *company
+	stale.Name = "Lost update"
+	if err := service.UpdateCompany(ctx, &stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("UpdateCompany at a stale version = %v, want ErrVersionConflict", err)
+	}
+	if _, err := service.PatchCompany(ctx, company.ID, []byte(`{"name":"Lost patch"}`), read); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("PatchCompany at a stale version = %v, want ErrVersionConflict", err)
+	}
+
+	stale.Version = 0
+	stale.Name = "Acme SA"
+	if err := service.UpdateCompany(ctx, &stale); err != nil {
+		t.Fatalf("UpdateCompany without a version: %v", err)
+	}
+	patched, err := service.PatchCompany(ctx, company.ID, []byte(`{"name":"Acme Group"}`), 0)
+	if err != nil {
+		t.Fatalf("PatchCompany without a version: %v", err)
+	}
+	if patched.Name != "Acme Group" || patched.Version != read+3 {
+		t.Errorf("patched company = %q at version %d, want Acme Group at %d", patched.Name, patched.Version, read+3)
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+
+	"promo-api/repositories"
+)
+
+var (
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = repositories.ErrNotFound
+
+	// ErrVersionConflict is returned when a write expected a version of the
+	// resource that is no longer current.
+	ErrVersionConflict = repositories.ErrVersionConflict
+
+	// ErrDuplicate is returned when a resource conflicts with an existing
+	// one, such as a second company with the same CNPJ.
+	ErrDuplicate = repositories.ErrDuplicate
+
+	// ErrInvalidStatus is returned when a promotion status filter or value is
+	// not a known lifecycle status.
+	ErrInvalidStatus = errors.New("invalid status")
+
+	// ErrInvalidTransition is returned when a lifecycle transition is not
+	// allowed from the promotion's current status.
+	ErrInvalidTransition = errors.New("invalid transition")
+
+	// ErrInvalidPatch is returned when a merge patch is malformed or changes
+	// a read-only field.
+	ErrInvalidPatch = errors.New("invalid patch")
+
+	// ErrPromotionNotActive is returned when a promotion is redeemed outside
+	// its lifecycle or its recurring schedule.
+	ErrPromotionNotActive = errors.New("promotion is not active")
+
+	// ErrPromotionExhausted is returned when a promotion is redeemed after
+	// reaching its max_usage.
+	ErrPromotionExhausted = errors.New("promotion usage limit reached")
+
+	// ErrMinimumPurchase is returned when a purchase is below the minimum
+	// amount of the promotion it redeems.
+	ErrMinimumPurchase = errors.New("purchase below the promotion minimum")
+
+	// ErrForbidden is returned when the caller may not act on a resource it
+	// can otherwise see, such as another company.
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrCompanyRequired is returned when an operation on company-owned
+	// resources is called without a company API key.
+	ErrCompanyRequired = errors.New("company API key required")
+)
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/events"
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/tracing"
+	"promo-api/utils"
+)
+
+const outboxBatchSize = 100
+
+type OutboxServiceInterface interface {
+	Relay(ctx context.Context) (int, error)
+}
+
+// OutboxService relays events committed to the outbox to Sink. Delivery is
+// at least once: a message is marked published only after Sink accepts it,
+// so sinks must tolerate duplicates, e.g. by deduplicating on event ID.
+type OutboxService struct {
+	Repo  repositories.OutboxRepositoryInterface
+	Sink  events.Publisher
+	Clock utils.Clock
+
+	// Retention is how long published messages are kept. Zero keeps them.
+	Retention time.Duration
+	// MaxAttempts is how many times a message is tried before it is
+	// dead-lettered. Zero retries it forever.
+	MaxAttempts int
+}
+
+var _ OutboxServiceInterface = &OutboxService{}
+
+func (s *OutboxService) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// enqueueEvent stores an event of the aggregate owned by owner, if any, in
+// the outbox. Call it inside the transaction that makes the change so the
+// event is only relayed if the change commits.
+func enqueueEvent(ctx context.Context, outbox repositories.OutboxRepositoryInterface, eventType, aggregateType string, aggregateID uuid.UUID, owner *uuid.UUID, at time.Time, data any) error {
+	if outbox == nil {
+		return nil
+	}
+	event, err := models.NewEvent(eventType, aggregateType, aggregateID, at, data)
+	if err != nil {
+		return fmt.Errorf("failed to build %s event: %w", eventType, err)
+	}
+	event.CompanyID = owner
+	if err := outbox.Enqueue(ctx, event); err != nil {
+		return fmt.Errorf("failed to enqueue %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// Relay publishes pending messages by position and returns how many were
+// published. Positions follow the order messages were enqueued; the writes
+// that enqueue an aggregate's events lock its row first, so each aggregate's
+// events are enqueued, and relayed, in the order they committed.
+//
+// When publishing fails, later messages of the same aggregate wait for the
+// next run so each aggregate's events stay in order; other aggregates are
+// not held up, however many failing messages come first. A message that
+// fails MaxAttempts times is dead-lettered and releases its aggregate. Only
+// one instance relays at a time.
+func (s *OutboxService) Relay(ctx context.Context) (_ int, err error) {
+	ctx, end := tracing.Start(ctx, "OutboxService.Relay")
+	defer end(&err)
+
+	unlock, ok, err := s.Repo.Lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, nil
+	}
+	defer unlock()
+
+	published := 0
+	blocked := map[uuid.UUID]bool{}
+	// Each run pages through every pending message once. It starts from the
+	// beginning again next time, which also picks up messages whose
+	// transactions committed after later positions were read.
+	var after int64
+	for {
+		messages, err := s.Repo.FindPending(ctx, after, outboxBatchSize)
+		if err != nil {
+			return published, err
+		}
+
+		for i := range messages {
+			message := &messages[i]
+			after = message.Position
+			if blocked[message.AggregateID] {
+				continue
+			}
+
+			if err := s.Sink.Publish(ctx, message.Event()); err != nil {
+				dead, err := s.fail(ctx, message, err)
+				if err != nil {
+					return published, err
+				}
+				blocked[message.AggregateID] = !dead
+				continue
+			}
+			if err := s.Repo.MarkPublished(ctx, message.Position, s.now()); err != nil {
+				return published, err
+			}
+			published++
+		}
+
+		if len(messages) < outboxBatchSize {
+			break
+		}
+	}
+
+	if s.Retention > 0 {
+		if _, err := s.Repo.PurgePublished(ctx, s.now().Add(-s.Retention)); err != nil {
+			return published, err
+		}
+	}
+	return published, nil
+}
+
+// fail records a failed attempt to publish message, dead-lettering it once it
+// has been tried MaxAttempts times. It reports whether it did.
+func (s *OutboxService) fail(ctx context.Context, message *models.OutboxMessage, cause error) (bool, error) {
+	attrs := []any{"event_id", message.EventID, "event_type", message.Type, "aggregate_id", message.AggregateID,
+		"attempts", message.Attempts + 1, "error", cause}
+	if s.MaxAttempts > 0 && message.Attempts+1 >= s.MaxAttempts {
+		slog.ErrorContext(ctx, "Failed to publish outbox event, dead-lettering it", attrs...)
+		return true, s.Repo.MarkDead(ctx, message.Position, cause.Error(), s.now())
+	}
+	slog.WarnContext(ctx, "Failed to publish outbox event, holding back its aggregate", attrs...)
+	return false, s.Repo.MarkFailed(ctx, message.Position, cause.Error())
+}
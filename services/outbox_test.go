@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories/memory"
+)
+
+// sink records the events it publishes and fails those of the aggregates in
+// failing.
+type sink struct {
+	failing   map[uuid.UUID]bool
+	published []models.Event
+}
+
+func (s *sink) Publish(ctx context.Context, event models.Event) error {
+	if s.failing[event.AggregateID] {
+		return errors.New("broker down")
+	}
+	s.published = append(s.published, event)
+	return nil
+}
+
+type outboxFixture struct {
+	service *OutboxService
+	repo    *memory.OutboxRepository
+	sink    *sink
+	clock   *fakeClock
+}
+
+func newOutboxFixture() *outboxFixture {
+	f := &outboxFixture{
+		repo:  &memory.OutboxRepository{Store: memory.NewStore()},
+		sink:  &sink{failing: map[uuid.UUID]bool{}},
+		clock: &fakeClock{now: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)},
+	}
+	f.service = &OutboxService{Repo: f.repo, Sink: f.sink, Clock: f.clock}
+	return f
+}
+
+// enqueue adds an event of aggregate to the outbox, numbered by n.
+func (f *outboxFixture) enqueue(t *testing.T, aggregate uuid.UUID, n int) models.Event {
+	t.Helper()
+	event, err := models.NewEvent(models.EventPromotionUpdated, models.AggregatePromotion, aggregate, f.clock.now, map[string]int{"n": n})
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	if err := f.repo.Enqueue(context.Background(), event); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return event
+}
+
+func (f *outboxFixture) relay(t *testing.T, want int) {
+	t.Helper()
+	if n, err := f.service.Relay(context.Background()); err != nil || n != want {
+		t.Fatalf("Relay = %d, %v; want %d", n, err, want)
+	}
+}
+
+func (f *outboxFixture) pending(t *testing.T) []models.OutboxMessage {
+	t.Helper()
+	messages, err := f.repo.FindPending(context.Background(), 0, 1000)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	return messages
+}
+
+func eventIDs(events []models.Event) []uuid.UUID {
+	ids := make([]uuid.UUID, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	f := newOutboxFixture()
+	f.service.Retention = time.Hour
+	a, b := uuid.New(), uuid.New()
+	want := []models.Event{f.enqueue(t, a, 0), f.enqueue(t, b, 0), f.enqueue(t, a, 1)}
+
+	f.relay(t, 3)
+	if !slices.Equal(eventIDs(f.sink.published), eventIDs(want)) {
+		t.Errorf("published %v, want %v", eventIDs(f.sink.published), eventIDs(want))
+	}
+	if pending := f.pending(t); len(pending) != 0 {
+		t.Errorf("%d messages still pending", len(pending))
+	}
+	f.relay(t, 0)
+
+	f.clock.now = f.clock.now.Add(2 * time.Hour)
+	f.relay(t, 0)
+	if n, _ := f.repo.PurgePublished(context.Background(), f.clock.now); n != 0 {
+		t.Errorf("Relay kept %d messages published before the retention", n)
+	}
+}
+
+// TestRelayHoldsBackFailingAggregates checks that a failing message holds
+// back the later messages of its aggregate only, even when more failing
+// messages than a batch come first.
+func TestRelayHoldsBackFailingAggregates(t *testing.T) {
+	f := newOutboxFixture()
+	failing := uuid.New()
+	f.sink.failing[failing] = true
+	var held []models.Event
+	for n := range outboxBatchSize + 50 {
+		held = append(held, f.enqueue(t, failing, n))
+	}
+	for range outboxBatchSize + 50 {
+		other := uuid.New()
+		f.sink.failing[other] = true
+		f.enqueue(t, other, 0)
+	}
+	healthy := f.enqueue(t, uuid.New(), 0)
+
+	f.relay(t, 1)
+	if len(f.sink.published) != 1 || f.sink.published[0].ID != healthy.ID {
+		t.Fatalf("published %v, want only the healthy aggregate's event", eventIDs(f.sink.published))
+	}
+	for _, message := range f.pending(t) {
+		want := 1
+		if message.AggregateID == failing && message.EventID != held[0].ID {
+			want = 0
+		}
+		if message.Attempts != want {
+			t.Fatalf("message %d was tried %d times, want %d", message.Position, message.Attempts, want)
+		}
+	}
+
+	delete(f.sink.failing, failing)
+	f.sink.published = nil
+	f.relay(t, len(held))
+	if !slices.Equal(eventIDs(f.sink.published), eventIDs(held)) {
+		t.Error("the held back aggregate's events were not published in order")
+	}
+}
+
+func TestRelayDeadLettersAfterMaxAttempts(t *testing.T) {
+	f := newOutboxFixture()
+	f.service.MaxAttempts = 3
+	aggregate := uuid.New()
+	f.sink.failing[aggregate] = true
+	poison := f.enqueue(t, aggregate, 0)
+	next := f.enqueue(t, aggregate, 1)
+
+	f.relay(t, 0)
+	f.relay(t, 0)
+	if pending := f.pending(t); len(pending) != 2 || pending[0].Attempts != 2 {
+		t.Fatalf("pending after two runs = %+v, want both messages, the first tried twice", pending)
+	}
+
+	// The third failure dead-letters the first message and releases the
+	// aggregate, whose next message fails for the first time.
+	f.relay(t, 0)
+	pending := f.pending(t)
+	if len(pending) != 1 || pending[0].EventID != next.ID || pending[0].Attempts != 1 {
+		t.Fatalf("pending after the third run = %+v, want the second message tried once", pending)
+	}
+
+	delete(f.sink.failing, aggregate)
+	f.relay(t, 1)
+	if len(f.sink.published) != 1 || f.sink.published[0].ID != next.ID {
+		t.Errorf("published %v, want only the message after the dead-lettered one", eventIDs(f.sink.published))
+	}
+	for _, event := range f.sink.published {
+		if event.ID == poison.ID {
+			t.Error("a dead-lettered message was published")
+		}
+	}
+}
+
+func TestRelaySkipsWhileAnotherRelayRuns(t *testing.T) {
+	f := newOutboxFixture()
+	f.enqueue(t, uuid.New(), 0)
+
+	unlock, ok, err := f.repo.Lock(context.Background())
+	if err != nil || !ok {
+		t.Fatalf("Lock = %v, %v", ok, err)
+	}
+	f.relay(t, 0)
+	unlock()
+	f.relay(t, 1)
+}
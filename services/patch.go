@@ -0,0 +1,39 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	// promotionReadOnlyFields are managed by the server and rejected in
+	// merge patches. The status changes through transitions only.
+	promotionReadOnlyFields = []string{
+		"id", "company_id", "current_usage", "status", "is_active", "created_at", "updated_at", "deleted_at",
+	}
+
+	// companyReadOnlyFields are managed by the server and rejected in merge
+	// patches. The API key changes through rotation only.
+	companyReadOnlyFields = []string{
+		"id", "api_key", "created_at", "updated_at", "deleted_at",
+	}
+
+	// companyAdminFields are read-only in merge patches except for the
+	// administrator.
+	companyAdminFields = []string{"rate_limit", "rate_limit_burst"}
+)
+
+// checkMergePatch ensures a merge patch is a JSON object that does not touch
+// any of the read-only fields.
+func checkMergePatch(patch []byte, readOnly []string) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil {
+		return fmt.Errorf("%w: patch must be a JSON object", ErrInvalidPatch)
+	}
+	for _, field := range readOnly {
+		if _, ok := members[field]; ok {
+			return fmt.Errorf("%w: %s is read-only", ErrInvalidPatch, field)
+		}
+	}
+	return nil
+}
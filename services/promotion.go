@@ -2,93 +2,799 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 
 	"promo-api/models"
 	"promo-api/repositories"
+	"promo-api/requestctx"
+	"promo-api/tracing"
+	"promo-api/utils"
 )
 
+// activeAtBatchSize bounds each repository fetch while filtering promotions
+// by schedule, which cannot be expressed in SQL.
+const activeAtBatchSize = 100
+
+// statusSyncBatchSize bounds how many promotions SyncStatuses transitions per
+// repository fetch.
+const statusSyncBatchSize = 100
+
 type PromotionServiceInterface interface {
 	CreatePromotion(ctx context.Context, promotion *models.Promotion) error
-	GetPromotion(ctx context.Context, id uuid.UUID) (*models.Promotion, error)
-	GetAllPromotions(ctx context.Context, limit, offset int) ([]models.Promotion, error)
+	GetPromotion(ctx context.Context, id uuid.UUID, includeDeleted bool) (*models.Promotion, error)
+	GetAllPromotions(ctx context.Context, includeDeleted bool, limit, offset int) ([]models.Promotion, error)
+	GetPromotionsActiveAt(ctx context.Context, at time.Time, limit, offset int) ([]models.Promotion, error)
+	GetPromotionsByStatus(ctx context.Context, status string, limit, offset int) ([]models.Promotion, error)
+	IsActiveAt(promotion *models.Promotion, at time.Time) (bool, error)
+	RedeemPromotion(ctx context.Context, id uuid.UUID, purchaseAmount float64) (*models.Redemption, error)
 	GetPromotionsByCoupon(ctx context.Context, coupon string) ([]models.Promotion, error)
 	UpdatePromotion(ctx context.Context, promotion *models.Promotion) error
+	PatchPromotion(ctx context.Context, id uuid.UUID, patch []byte, version int) (*models.Promotion, error)
 	DeletePromotion(ctx context.Context, id uuid.UUID) error
+	RestorePromotion(ctx context.Context, id uuid.UUID) (*models.Promotion, error)
+	PurgeDeletedPromotions(ctx context.Context, retention time.Duration) (int64, error)
+	PausePromotion(ctx context.Context, id uuid.UUID) (*models.Promotion, error)
+	ResumePromotion(ctx context.Context, id uuid.UUID) (*models.Promotion, error)
+	ArchivePromotion(ctx context.Context, id uuid.UUID) (*models.Promotion, error)
+	GetPromotionTransitions(ctx context.Context, id uuid.UUID) ([]models.PromotionTransition, error)
+	GetPromotionAsOf(ctx context.Context, id uuid.UUID, at time.Time) (*models.Promotion, error)
+	GetPromotionRevisions(ctx context.Context, id uuid.UUID) ([]models.PromotionRevision, error)
+	RollbackPromotion(ctx context.Context, id uuid.UUID, revision, version int) (*models.Promotion, error)
+	SyncStatuses(ctx context.Context) (int, error)
 }
 
 type PromotionService struct {
-	Repo repositories.PromotionRepositoryInterface
+	Repo   repositories.PromotionRepositoryInterface
+	Audit  repositories.AuditRepositoryInterface
+	Tx     repositories.Transactor
+	Clock  utils.Clock
+	Outbox repositories.OutboxRepositoryInterface
+	// Metrics, when set, counts business events once they commit.
+	Metrics BusinessMetrics
+}
+
+// BusinessMetrics counts business events, labelled by company.
+type BusinessMetrics interface {
+	PromotionCreated(company string)
+	PromotionRedeemed(company string, discount float64)
+	RedemptionRejected(company, reason string)
+}
+
+// redemptionRejections are the reasons a redemption is refused, counted in
+// metrics, and the errors that report them.
+var redemptionRejections = []struct {
+	err    error
+	reason string
+}{
+	{ErrNotFound, "not_found"},
+	{ErrPromotionNotActive, "not_active"},
+	{ErrPromotionExhausted, "exhausted"},
+	{ErrMinimumPurchase, "minimum_purchase"},
 }
 
 var _ PromotionServiceInterface = &PromotionService{}
 
-func (s *PromotionService) CreatePromotion(ctx context.Context, promotion *models.Promotion) error {
+func (s *PromotionService) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// publish enqueues a promotion event in the outbox. Call it inside the
+// transaction that makes the change.
+func (s *PromotionService) publish(ctx context.Context, eventType string, promotion *models.Promotion) error {
+	return enqueueEvent(ctx, s.Outbox, eventType, models.AggregatePromotion, promotion.ID, promotion.CompanyID, s.now(), promotion)
+}
+
+// prepareStatus resolves the initial stored status of a new promotion.
+// Clients may only create drafts; the other statuses follow from the dates
+// and usage. Legacy clients that send is_active=false without a status also
+// get a draft. Later changes go through the transition endpoints.
+func prepareStatus(promotion *models.Promotion, now time.Time) error {
+	switch promotion.Status {
+	case "":
+		if !promotion.IsActive {
+			promotion.Status = models.PromotionStatusDraft
+		}
+	case models.PromotionStatusDraft:
+	default:
+		if !promotionStatuses[promotion.Status] {
+			return fmt.Errorf("%w %q", ErrInvalidStatus, promotion.Status)
+		}
+		promotion.Status = ""
+	}
+
+	applyStatus(promotion, now)
+	return nil
+}
+
+// prepareSchedule defaults the schedule time zone to the authenticated
+// company's and validates the recurrence definition.
+func (s *PromotionService) prepareSchedule(ctx context.Context, promotion *models.Promotion) error {
+	if promotion.Schedule == nil {
+		return nil
+	}
+	if promotion.Schedule.Timezone == "" {
+		promotion.Schedule.Timezone = "UTC"
+		if company, ok := requestctx.Company(ctx); ok && company.Timezone != "" {
+			promotion.Schedule.Timezone = company.Timezone
+		}
+	}
+	return validateSchedule(promotion.Schedule)
+}
+
+func (s *PromotionService) CreatePromotion(ctx context.Context, promotion *models.Promotion) (err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.CreatePromotion")
+	defer end(&err)
+
 	if promotion.StartDate.After(promotion.EndDate) {
 		return errors.New("start_date cannot be after end_date")
 	}
 	if promotion.DiscountValue <= 0 {
 		return errors.New("discount_value must be greater than zero")
 	}
+	if err := s.prepareSchedule(ctx, promotion); err != nil {
+		return err
+	}
+
+	now := s.now()
+	if err := prepareStatus(promotion, now); err != nil {
+		return err
+	}
 
+	// The calling company owns the promotion; the admin key creates
+	// promotions without an owner.
+	promotion.CompanyID = nil
+	if company, ok := requestctx.Company(ctx); ok {
+		promotion.CompanyID = &company.ID
+	}
 	promotion.ID = uuid.New()
-	now := time.Now()
+	promotion.Version = 1
 	promotion.CreatedAt = now
 	promotion.UpdatedAt = now
 
-	if err := s.Repo.CreatePromotion(ctx, promotion); err != nil {
-		return fmt.Errorf("failed to create promotion: %w", err)
+	err = withTx(ctx, s.Tx, func(ctx context.Context) error {
+		if err := s.Repo.CreatePromotion(ctx, promotion); err != nil {
+			return fmt.Errorf("failed to create promotion: %w", err)
+		}
+		if err := s.recordRevision(ctx, promotion, now); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.Audit, models.AuditActionCreate, models.ResourcePromotion, promotion.ID, nil, promotion, now); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventPromotionCreated, promotion)
+	})
+	if err == nil && s.Metrics != nil {
+		s.Metrics.PromotionCreated(companyLabel(ctx))
 	}
-	return nil
+	return err
 }
 
-func (s *PromotionService) GetAllPromotions(ctx context.Context, limit, offset int) ([]models.Promotion, error) {
-	promotions, err := s.Repo.FindAll(ctx, limit, offset)
+func (s *PromotionService) GetAllPromotions(ctx context.Context, includeDeleted bool, limit, offset int) (_ []models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetAllPromotions")
+	defer end(&err)
+
+	promotions, err := s.Repo.FindAll(ctx, includeDeleted, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get promotions: %w", err)
 	}
+	applyStatuses(promotions, s.now())
 	return promotions, nil
 }
 
-func (s *PromotionService) GetPromotion(ctx context.Context, id uuid.UUID) (*models.Promotion, error) {
-	promotion, err := s.Repo.FindByID(ctx, id)
+// GetPromotionsByStatus lists promotions by their lifecycle status now.
+func (s *PromotionService) GetPromotionsByStatus(ctx context.Context, status string, limit, offset int) (_ []models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetPromotionsByStatus")
+	defer end(&err)
+
+	if !promotionStatuses[status] {
+		return nil, fmt.Errorf("%w %q", ErrInvalidStatus, status)
+	}
+
+	now := s.now()
+	promotions, err := s.Repo.FindByStatus(ctx, status, now, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotions by status: %w", err)
+	}
+	applyStatuses(promotions, now)
+	return promotions, nil
+}
+
+// GetPromotionsActiveAt lists promotions that apply at the given instant,
+// including their recurring schedule. A zero at means now.
+func (s *PromotionService) GetPromotionsActiveAt(ctx context.Context, at time.Time, limit, offset int) (_ []models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetPromotionsActiveAt")
+	defer end(&err)
+
+	now := s.now()
+	if at.IsZero() {
+		at = now
+	}
+
+	promotions := []models.Promotion{}
+	skipped := 0
+	for page := 0; ; page++ {
+		batch, err := s.Repo.FindActiveAt(ctx, at, activeAtBatchSize, page*activeAtBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get active promotions: %w", err)
+		}
+
+		for i := range batch {
+			active, err := scheduleActiveAt(&batch[i], at)
+			if err != nil {
+				// One promotion with a schedule that no longer evaluates,
+				// such as a time zone dropped from the database, must not
+				// fail the whole listing.
+				slog.WarnContext(ctx, "Failed to evaluate promotion schedule, leaving it out", "promotion_id", batch[i].ID, "error", err)
+				continue
+			}
+			if !active {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			applyStatus(&batch[i], now)
+			promotions = append(promotions, batch[i])
+			if len(promotions) == limit {
+				return promotions, nil
+			}
+		}
+
+		if len(batch) < activeAtBatchSize {
+			return promotions, nil
+		}
+	}
+}
+
+// IsActiveAt reports whether a promotion can be applied at the given instant.
+// Every redemption path must check it so recurring schedules are honored.
+func (s *PromotionService) IsActiveAt(promotion *models.Promotion, at time.Time) (bool, error) {
+	if PromotionStatus(promotion, at) != models.PromotionStatusActive {
+		return false, nil
+	}
+	return scheduleActiveAt(promotion, at)
+}
+
+// RedeemPromotion applies the promotion to a purchase of the given amount,
+// counts the use and emits promotion.redeemed. The promotion must be active now, within its recurring
+// schedule, and the purchase must reach its minimum amount. max_usage holds
+// under concurrent redemptions; the one that reaches it leaves the promotion
+// exhausted, which SyncStatuses records.
+func (s *PromotionService) RedeemPromotion(ctx context.Context, id uuid.UUID, purchaseAmount float64) (_ *models.Redemption, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.RedeemPromotion")
+	defer end(&err)
+
+	if purchaseAmount <= 0 {
+		return nil, errors.New("purchase_amount must be greater than zero")
+	}
+
+	now := s.now()
+	var redemption *models.Redemption
+	err = withTx(ctx, s.Tx, func(ctx context.Context) error {
+		promotion, err := s.Repo.FindByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to load promotion %s: %w", id, err)
+		}
+		if PromotionStatus(promotion, now) == models.PromotionStatusExhausted {
+			return fmt.Errorf("%w: promotion %s", ErrPromotionExhausted, id)
+		}
+		active, err := s.IsActiveAt(promotion, now)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate schedule of promotion %s: %w", id, err)
+		}
+		if !active {
+			return fmt.Errorf("%w: promotion %s does not apply at %s", ErrPromotionNotActive, id, now.Format(time.RFC3339))
+		}
+		if minimum := promotion.MinimumPurchaseAmount; minimum != nil && purchaseAmount < *minimum {
+			return fmt.Errorf("%w: purchase_amount must be at least %.2f", ErrMinimumPurchase, *minimum)
+		}
+
+		counted, err := s.Repo.IncrementUsage(ctx, id, now)
+		if err != nil {
+			return err
+		}
+		if !counted {
+			return fmt.Errorf("%w: promotion %s", ErrPromotionExhausted, id)
+		}
+
+		discount := discountAmount(promotion, purchaseAmount)
+		redemption = &models.Redemption{
+			PromotionID:    id,
+			PurchaseAmount: purchaseAmount,
+			DiscountAmount: discount,
+			FinalAmount:    math.Round((purchaseAmount-discount)*100) / 100,
+			RedeemedAt:     now,
+		}
+		return enqueueEvent(ctx, s.Outbox, models.EventPromotionRedeemed, models.AggregatePromotion, id, promotion.CompanyID, now, redemption)
+	})
+	if err != nil {
+		s.countRejection(ctx, err)
+		return nil, err
+	}
+	if s.Metrics != nil {
+		s.Metrics.PromotionRedeemed(companyLabel(ctx), redemption.DiscountAmount)
+	}
+	return redemption, nil
+}
+
+// countRejection counts a redemption refused by err, unless err is a failure
+// rather than a refusal.
+func (s *PromotionService) countRejection(ctx context.Context, err error) {
+	if s.Metrics == nil {
+		return
+	}
+	for _, rejection := range redemptionRejections {
+		if errors.Is(err, rejection.err) {
+			s.Metrics.RedemptionRejected(companyLabel(ctx), rejection.reason)
+			return
+		}
+	}
+}
+
+// discountAmount is the discount the promotion grants on a purchase, rounded
+// to cents and never more than the purchase itself.
+func discountAmount(promotion *models.Promotion, purchaseAmount float64) float64 {
+	discount := promotion.DiscountValue
+	if promotion.DiscountType == models.DiscountTypePercentage {
+		discount = math.Round(purchaseAmount*promotion.DiscountValue) / 100
+	}
+	return min(discount, purchaseAmount)
+}
+
+func (s *PromotionService) GetPromotion(ctx context.Context, id uuid.UUID, includeDeleted bool) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetPromotion")
+	defer end(&err)
+
+	find := s.Repo.FindByID
+	if includeDeleted {
+		find = s.Repo.FindByIDIncludingDeleted
+	}
+	promotion, err := find(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get promotion: %w", err)
 	}
+	applyStatus(promotion, s.now())
 	return promotion, nil
 }
 
-func (s *PromotionService) GetPromotionsByCoupon(ctx context.Context, coupon string) ([]models.Promotion, error) {
+func (s *PromotionService) GetPromotionsByCoupon(ctx context.Context, coupon string) (_ []models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetPromotionsByCoupon")
+	defer end(&err)
+
 	promotions, err := s.Repo.FindByCoupon(ctx, coupon)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get promotion by coupon: %w", err)
 	}
+	applyStatuses(promotions, s.now())
 	return promotions, nil
 }
 
-func (s *PromotionService) UpdatePromotion(ctx context.Context, promotion *models.Promotion) error {
-	if promotion.StartDate.After(promotion.EndDate) {
-		return errors.New("start_date cannot be after end_date")
-	}
-	if promotion.DiscountValue <= 0 {
-		return errors.New("discount_value must be greater than zero")
+// UpdatePromotion replaces the client-managed fields of a promotion. A non-zero
+// promotion.Version must match the stored version.
+// Server-managed fields keep their stored values.
+func (s *PromotionService) UpdatePromotion(ctx context.Context, promotion *models.Promotion) (err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.UpdatePromotion")
+	defer end(&err)
+
+	_, err = s.update(ctx, promotion.ID, models.AuditActionUpdate, func(*models.Promotion) (*models.Promotion, error) {
+		return promotion, nil
+	})
+	return err
+}
+
+// PatchPromotion applies an RFC 7396 merge patch to a promotion. A non-zero
+// version must match the stored one.
+func (s *PromotionService) PatchPromotion(ctx context.Context, id uuid.UUID, patch []byte, version int) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.PatchPromotion")
+	defer end(&err)
+
+	if err := checkMergePatch(patch, promotionReadOnlyFields); err != nil {
+		return nil, err
 	}
 
-	promotion.UpdatedAt = time.Now()
+	return s.update(ctx, id, models.AuditActionUpdate, func(existing *models.Promotion) (*models.Promotion, error) {
+		document, err := json.Marshal(existing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to patch promotion: %w", err)
+		}
+		merged, err := utils.MergePatch(document, patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		var promotion models.Promotion
+		if err := json.Unmarshal(merged, &promotion); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		promotion.Version = version
+		return &promotion, nil
+	})
+}
+
+// update stores the promotion that build derives from the stored promotion
+// id. The stored promotion is read inside the write transaction, so the
+// version check and the server-managed fields kept from it are those of the
+// row being replaced, never a cached or replicated copy.
+func (s *PromotionService) update(ctx context.Context, id uuid.UUID, action string, build func(existing *models.Promotion) (*models.Promotion, error)) (*models.Promotion, error) {
+	var promotion *models.Promotion
+	err := withTx(ctx, s.Tx, func(ctx context.Context) error {
+		existing, err := s.Repo.FindByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to load promotion %s: %w", id, err)
+		}
+		if existing.Status == models.PromotionStatusArchived {
+			return fmt.Errorf("%w: cannot update an archived promotion", ErrInvalidTransition)
+		}
+
+		promotion, err = build(existing)
+		if err != nil {
+			return err
+		}
+		if promotion.StartDate.After(promotion.EndDate) {
+			return errors.New("start_date cannot be after end_date")
+		}
+		if promotion.DiscountValue <= 0 {
+			return errors.New("discount_value must be greater than zero")
+		}
+		if err := s.prepareSchedule(ctx, promotion); err != nil {
+			return err
+		}
+
+		if promotion.Version != 0 && promotion.Version != existing.Version {
+			return fmt.Errorf("promotion %s is at version %d: %w", existing.ID, existing.Version, ErrVersionConflict)
+		}
+		// The update is conditional on the version the client sent, so a
+		// write committed since existing was read still conflicts; "*"
+		// takes the version just read.
+		if promotion.Version == 0 {
+			promotion.Version = existing.Version
+		}
 
-	if err := s.Repo.UpdatePromotion(ctx, promotion); err != nil {
-		return fmt.Errorf("failed to update promotion: %w", err)
+		promotion.ID = existing.ID
+		promotion.CompanyID = existing.CompanyID
+		promotion.CurrentUsage = existing.CurrentUsage
+		promotion.CreatedAt = existing.CreatedAt
+		promotion.DeletedAt = existing.DeletedAt
+
+		// The status only changes through transitions, never through updates.
+		now := s.now()
+		promotion.Status = existing.Status
+		applyStatus(promotion, now)
+		promotion.UpdatedAt = now
+
+		before := *existing
+		applyStatus(&before, now)
+
+		if err := s.Repo.UpdatePromotion(ctx, promotion); err != nil {
+			return fmt.Errorf("failed to update promotion: %w", err)
+		}
+		if err := s.recordRevision(ctx, promotion, now); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.Audit, action, models.ResourcePromotion, promotion.ID, &before, promotion, now); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventPromotionUpdated, promotion)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return promotion, nil
 }
 
-func (s *PromotionService) DeletePromotion(ctx context.Context, id uuid.UUID) error {
-	if err := s.Repo.DeletePromotion(ctx, id); err != nil {
+// DeletePromotion soft-deletes a promotion. It is hidden from default
+// queries until restored or purged by the retention job.
+func (s *PromotionService) DeletePromotion(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.DeletePromotion")
+	defer end(&err)
+
+	promotion, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
 		return fmt.Errorf("failed to delete promotion: %w", err)
 	}
+
+	now := s.now()
+	applyStatus(promotion, now)
+	before := *promotion
+	promotion.DeletedAt = &now
+	promotion.Version++
+	promotion.UpdatedAt = now
+
+	return withTx(ctx, s.Tx, func(ctx context.Context) error {
+		if err := s.Repo.DeletePromotion(ctx, id, now); err != nil {
+			return fmt.Errorf("failed to delete promotion: %w", err)
+		}
+		if err := recordAudit(ctx, s.Audit, models.AuditActionDelete, models.ResourcePromotion, id, &before, promotion, now); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventPromotionDeleted, promotion)
+	})
+}
+
+func (s *PromotionService) RestorePromotion(ctx context.Context, id uuid.UUID) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.RestorePromotion")
+	defer end(&err)
+
+	now := s.now()
+
+	var promotion *models.Promotion
+	err = withTx(ctx, s.Tx, func(ctx context.Context) error {
+		before, err := s.Repo.FindByIDIncludingDeleted(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to restore promotion: %w", err)
+		}
+		if err := s.Repo.RestorePromotion(ctx, id, now); err != nil {
+			return fmt.Errorf("failed to restore promotion: %w", err)
+		}
+		promotion, err = s.Repo.FindByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to restore promotion: %w", err)
+		}
+
+		applyStatus(before, now)
+		applyStatus(promotion, now)
+		if err := recordAudit(ctx, s.Audit, models.AuditActionRestore, models.ResourcePromotion, id, before, promotion, now); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventPromotionRestored, promotion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+// PurgeDeletedPromotions permanently removes promotions soft-deleted more
+// than retention ago.
+func (s *PromotionService) PurgeDeletedPromotions(ctx context.Context, retention time.Duration) (_ int64, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.PurgeDeletedPromotions")
+	defer end(&err)
+
+	purged, err := s.Repo.PurgeDeleted(ctx, s.now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted promotions: %w", err)
+	}
+	return purged, nil
+}
+
+// SyncStatuses persists lifecycle transitions that happened with the passage
+// of time or usage, emitting an event for each promotion that changed. It
+// returns the number of promotions transitioned.
+func (s *PromotionService) SyncStatuses(ctx context.Context) (_ int, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.SyncStatuses")
+	defer end(&err)
+
+	now := s.now()
+	transitioned := 0
+
+	for {
+		promotions, err := s.Repo.FindStatusChanges(ctx, now, statusSyncBatchSize)
+		if err != nil {
+			return transitioned, fmt.Errorf("failed to sync promotion statuses: %w", err)
+		}
+
+		changed := 0
+		for i := range promotions {
+			promotion := &promotions[i]
+			to := PromotionStatus(promotion, now)
+
+			ok, err := s.changeStatus(ctx, promotion, models.TransitionSync, to, ActorSystem, statusEvents[to], now)
+			if err != nil {
+				return transitioned, fmt.Errorf("failed to sync promotion statuses: %w", err)
+			}
+			if ok {
+				changed++
+			}
+		}
+		transitioned += changed
+
+		if len(promotions) < statusSyncBatchSize || changed == 0 {
+			return transitioned, nil
+		}
+	}
+}
+
+func (s *PromotionService) PausePromotion(ctx context.Context, id uuid.UUID) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.PausePromotion")
+	defer end(&err)
+
+	return s.transition(ctx, id, models.TransitionPause)
+}
+
+func (s *PromotionService) ResumePromotion(ctx context.Context, id uuid.UUID) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.ResumePromotion")
+	defer end(&err)
+
+	return s.transition(ctx, id, models.TransitionResume)
+}
+
+func (s *PromotionService) ArchivePromotion(ctx context.Context, id uuid.UUID) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.ArchivePromotion")
+	defer end(&err)
+
+	return s.transition(ctx, id, models.TransitionArchive)
+}
+
+func (s *PromotionService) GetPromotionTransitions(ctx context.Context, id uuid.UUID) (_ []models.PromotionTransition, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetPromotionTransitions")
+	defer end(&err)
+
+	if _, err := s.Repo.FindByID(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to get promotion transitions: %w", err)
+	}
+	transitions, err := s.Repo.FindTransitions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion transitions: %w", err)
+	}
+	return transitions, nil
+}
+
+// transition applies an explicit lifecycle action through the state machine
+// and records who performed it.
+func (s *PromotionService) transition(ctx context.Context, id uuid.UUID, action string) (*models.Promotion, error) {
+	promotion, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s promotion: %w", action, err)
+	}
+
+	now := s.now()
+	to, err := nextStatus(promotion, action, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.changeStatus(ctx, promotion, action, to, actorFromContext(ctx), transitionEvents[action], now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s promotion: %w", action, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: promotion status changed concurrently", ErrInvalidTransition)
+	}
+	return promotion, nil
+}
+
+// changeStatus moves the stored status of promotion to the given value,
+// recording the transition, its audit event and eventType in the same
+// transaction. On success promotion reflects the new state. It reports false
+// when the stored status changed concurrently.
+func (s *PromotionService) changeStatus(ctx context.Context, promotion *models.Promotion, action, to, actor, eventType string, at time.Time) (bool, error) {
+	from := promotion.Status
+	next := *promotion
+	next.Status = to
+	next.Version++
+	next.UpdatedAt = at
+	applyStatus(&next, at)
+
+	changed := false
+	err := withTx(ctx, s.Tx, func(ctx context.Context) error {
+		ok, err := s.Repo.UpdateStatus(ctx, promotion.ID, from, to, at)
+		if err != nil || !ok {
+			return err
+		}
+
+		err = s.Repo.RecordTransition(ctx, &models.PromotionTransition{
+			ID:          uuid.New(),
+			PromotionID: promotion.ID,
+			Action:      action,
+			FromStatus:  from,
+			ToStatus:    to,
+			Actor:       actor,
+			CreatedAt:   at,
+		})
+		if err != nil {
+			return err
+		}
+
+		before := map[string]string{"status": from}
+		after := map[string]string{"status": to}
+		if err := recordAudit(ctx, s.Audit, models.AuditActionTransition, models.ResourcePromotion, promotion.ID, before, after, at); err != nil {
+			return err
+		}
+		if err := s.publish(ctx, eventType, &next); err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		*promotion = next
+	}
+	return changed, nil
+}
+
+// recordRevision snapshots the promotion as its next immutable revision.
+func (s *PromotionService) recordRevision(ctx context.Context, promotion *models.Promotion, at time.Time) error {
+	snapshot, err := json.Marshal(promotion)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot promotion %s: %w", promotion.ID, err)
+	}
+
+	err = s.Repo.CreateRevision(ctx, &models.PromotionRevision{
+		ID:          uuid.New(),
+		PromotionID: promotion.ID,
+		Snapshot:    snapshot,
+		Actor:       actorFromContext(ctx),
+		CreatedAt:   at,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record promotion revision: %w", err)
+	}
 	return nil
 }
+
+func (s *PromotionService) GetPromotionRevisions(ctx context.Context, id uuid.UUID) (_ []models.PromotionRevision, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetPromotionRevisions")
+	defer end(&err)
+
+	if _, err := s.Repo.FindByIDIncludingDeleted(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to get promotion revisions: %w", err)
+	}
+	revisions, err := s.Repo.FindRevisions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetPromotionAsOf reconstructs a promotion from the revision that was
+// current at the given instant, with its status computed at that instant.
+func (s *PromotionService) GetPromotionAsOf(ctx context.Context, id uuid.UUID, at time.Time) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.GetPromotionAsOf")
+	defer end(&err)
+
+	revision, err := s.Repo.FindRevisionAsOf(ctx, id, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion as of %s: %w", at.Format(time.RFC3339), err)
+	}
+
+	promotion, err := revisionPromotion(revision)
+	if err != nil {
+		return nil, err
+	}
+	applyStatus(promotion, at)
+	return promotion, nil
+}
+
+// RollbackPromotion restores the content of an earlier revision as a new
+// revision. Server-managed fields such as current_usage and the status keep
+// their current values. A non-zero version must match the stored one.
+func (s *PromotionService) RollbackPromotion(ctx context.Context, id uuid.UUID, revision, version int) (_ *models.Promotion, err error) {
+	ctx, end := tracing.Start(ctx, "PromotionService.RollbackPromotion")
+	defer end(&err)
+
+	target, err := s.Repo.FindRevision(ctx, id, revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back promotion: %w", err)
+	}
+	promotion, err := revisionPromotion(target)
+	if err != nil {
+		return nil, err
+	}
+
+	promotion.Version = version
+	return s.update(ctx, id, models.AuditActionRollback, func(*models.Promotion) (*models.Promotion, error) {
+		return promotion, nil
+	})
+}
+
+func revisionPromotion(revision *models.PromotionRevision) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := json.Unmarshal(revision.Snapshot, &promotion); err != nil {
+		return nil, fmt.Errorf("failed to decode revision %d of promotion %s: %w", revision.Revision, revision.PromotionID, err)
+	}
+	return &promotion, nil
+}
@@ -0,0 +1,627 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/repositories/memory"
+	"promo-api/requestctx"
+)
+
+// promotionFixture is a promotion service on the memory repositories, with
+// transactions tracked as in the application and a clock the test moves.
+type promotionFixture struct {
+	service *PromotionService
+	store   *memory.Store
+	repo    *memory.PromotionRepository
+	clock   *fakeClock
+	ctx     context.Context
+}
+
+func newPromotionFixture(t *testing.T) *promotionFixture {
+	t.Helper()
+	store := memory.NewStore()
+	f := &promotionFixture{
+		store: store,
+		repo:  &memory.PromotionRepository{Store: store},
+		clock: &fakeClock{now: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)},
+		ctx:   context.Background(),
+	}
+	f.service = &PromotionService{
+		Repo:   f.repo,
+		Audit:  &memory.AuditRepository{Store: store},
+		Tx:     repositories.TrackTx(store),
+		Clock:  f.clock,
+		Outbox: &memory.OutboxRepository{Store: store},
+	}
+	return f
+}
+
+// create stores a promotion running from a day before the fixture clock to
+// a month after it.
+func (f *promotionFixture) create(t *testing.T, change func(*models.Promotion)) *models.Promotion {
+	t.Helper()
+	promotion := &models.Promotion{
+		Title:         "Spring sale",
+		DiscountType:  "percentage",
+		DiscountValue: 10,
+		StartDate:     f.clock.now.Add(-24 * time.Hour),
+		EndDate:       f.clock.now.AddDate(0, 1, 0),
+		IsActive:      true,
+	}
+	if change != nil {
+		change(promotion)
+	}
+	if err := f.service.CreatePromotion(f.ctx, promotion); err != nil {
+		t.Fatalf("CreatePromotion: %v", err)
+	}
+	return promotion
+}
+
+// TestUpdatePromotionReadsInsideTheTransaction checks that updates compare
+// versions with, and keep the usage of, the stored row rather than a stale
+// cached copy.
+func TestUpdatePromotionReadsInsideTheTransaction(t *testing.T) {
+	f := newPromotionFixture(t)
+	cached := repositories.NewCachedPromotionRepository(f.repo, repositories.PromotionCacheOptions{TTL: time.Hour, Size: 10})
+	f.service.Repo = cached
+	promotion := f.create(t, nil)
+
+	if _, err := f.service.GetPromotion(f.ctx, promotion.ID, false); err != nil {
+		t.Fatalf("GetPromotion: %v", err)
+	}
+	// Another instance redeems the promotion; its invalidation has not
+	// arrived yet, so the cache still holds version 1.
+	stored, _ := f.repo.FindByID(f.ctx, promotion.ID)
+	stored.CurrentUsage = 5
+	if err := f.repo.UpdatePromotion(f.ctx, stored); err != nil {
+		t.Fatalf("UpdatePromotion: %v", err)
+	}
+
+	update := *promotion
+	update.Title = "Summer sale"
+	update.Version = stored.Version
+	if err := f.service.UpdatePromotion(f.ctx, &update); err != nil {
+		t.Fatalf("UpdatePromotion at the stored version: %v", err)
+	}
+	if update.CurrentUsage != 5 {
+		t.Errorf("current_usage = %d after the update, want the stored 5", update.CurrentUsage)
+	}
+
+	patched, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"title":"Autumn sale"}`), update.Version)
+	if err != nil {
+		t.Fatalf("PatchPromotion at the stored version: %v", err)
+	}
+	if patched.Title != "Autumn sale" || patched.CurrentUsage != 5 {
+		t.Errorf("patched promotion = %q with usage %d, want Autumn sale with 5", patched.Title, patched.CurrentUsage)
+	}
+
+	update.Title = "Winter sale"
+	update.Version = stored.Version
+	if err := f.service.UpdatePromotion(f.ctx, &update); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("UpdatePromotion at an old version = %v, want ErrVersionConflict", err)
+	}
+}
+
+func TestCreatePromotionRecordsTheOwningCompany(t *testing.T) {
+	f := newPromotionFixture(t)
+	company := &models.Company{ID: uuid.New(), Cnpj: "12345678000190"}
+	if err := (&memory.CompanyRepository{Store: f.store}).CreateCompany(f.ctx, company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	f.ctx = requestctx.WithCompany(f.ctx, company)
+	promotion := f.create(t, nil)
+	if promotion.CompanyID == nil || *promotion.CompanyID != company.ID {
+		t.Fatalf("company_id = %v, want the creating company %s", promotion.CompanyID, company.ID)
+	}
+
+	if _, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"company_id":null}`), 0); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("PatchPromotion of company_id = %v, want ErrInvalidPatch", err)
+	}
+	messages, err := f.service.Outbox.(*memory.OutboxRepository).FindPending(f.ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	for _, message := range messages {
+		if message.CompanyID == nil || *message.CompanyID != company.ID {
+			t.Errorf("%s event owned by %v, want %s", message.Type, message.CompanyID, company.ID)
+		}
+	}
+}
+
+func TestGetPromotionsActiveAtSkipsSchedulesThatFail(t *testing.T) {
+	f := newPromotionFixture(t)
+	broken := f.create(t, nil)
+	healthy := f.create(t, nil)
+
+	// Written behind the service's back, as a time zone later dropped from
+	// the database would leave it.
+	stored, _ := f.repo.FindByID(f.ctx, broken.ID)
+	stored.Schedule = &models.PromotionSchedule{Timezone: "Mars/Olympus_Mons"}
+	if err := f.repo.UpdatePromotion(f.ctx, stored); err != nil {
+		t.Fatalf("UpdatePromotion: %v", err)
+	}
+
+	promotions, err := f.service.GetPromotionsActiveAt(f.ctx, time.Time{}, 10, 0)
+	if err != nil {
+		t.Fatalf("GetPromotionsActiveAt: %v", err)
+	}
+	if len(promotions) != 1 || promotions[0].ID != healthy.ID {
+		t.Errorf("GetPromotionsActiveAt returned %d promotions, want only the healthy one", len(promotions))
+	}
+}
+
+func TestRedeemPromotion(t *testing.T) {
+	f := newPromotionFixture(t)
+	minimum := 50.0
+	percentage := f.create(t, func(p *models.Promotion) { p.MinimumPurchaseAmount = &minimum })
+	fixed := f.create(t, func(p *models.Promotion) {
+		p.DiscountType = models.DiscountTypeFixed
+		p.DiscountValue = 30
+	})
+	// The fixture clock is on a Monday.
+	weekend := f.create(t, func(p *models.Promotion) {
+		p.Schedule = &models.PromotionSchedule{DaysOfWeek: []string{"saturday", "sunday"}}
+	})
+	paused := f.create(t, nil)
+	if _, err := f.service.PausePromotion(f.ctx, paused.ID); err != nil {
+		t.Fatalf("PausePromotion: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		id           uuid.UUID
+		amount       float64
+		wantDiscount float64
+		wantErr      error
+		wantAnyErr   bool
+	}{
+		{name: "percentage", id: percentage.ID, amount: 123.45, wantDiscount: 12.35},
+		{name: "below the minimum", id: percentage.ID, amount: 49.99, wantErr: ErrMinimumPurchase},
+		{name: "fixed", id: fixed.ID, amount: 100, wantDiscount: 30},
+		{name: "fixed above the purchase", id: fixed.ID, amount: 20, wantDiscount: 20},
+		{name: "outside the schedule", id: weekend.ID, amount: 100, wantErr: ErrPromotionNotActive},
+		{name: "paused", id: paused.ID, amount: 100, wantErr: ErrPromotionNotActive},
+		{name: "unknown", id: uuid.New(), amount: 100, wantErr: ErrNotFound},
+		{name: "no purchase", id: fixed.ID, amount: 0, wantAnyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redemption, err := f.service.RedeemPromotion(f.ctx, tt.id, tt.amount)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RedeemPromotion = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("RedeemPromotion succeeded, want an error")
+				}
+			case err != nil:
+				t.Fatalf("RedeemPromotion: %v", err)
+			case redemption.DiscountAmount != tt.wantDiscount || redemption.FinalAmount != math.Round((tt.amount-tt.wantDiscount)*100)/100:
+				t.Errorf("redemption = %+v, want a discount of %.2f", redemption, tt.wantDiscount)
+			}
+		})
+	}
+
+	for id, want := range map[uuid.UUID]int{percentage.ID: 1, fixed.ID: 2, weekend.ID: 0, paused.ID: 0} {
+		stored, err := f.repo.FindByID(f.ctx, id)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		if stored.CurrentUsage != want {
+			t.Errorf("%s was used %d times, want %d", stored.Title, stored.CurrentUsage, want)
+		}
+	}
+}
+
+// TestRedeemPromotionHoldsMaxUsage checks that concurrent redemptions never
+// use a promotion more than its max_usage allows.
+func TestRedeemPromotionHoldsMaxUsage(t *testing.T) {
+	f := newPromotionFixture(t)
+	limit := 5
+	promotion := f.create(t, func(p *models.Promotion) { p.MaxUsage = &limit })
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed, exhausted := 0, 0
+	for range 4 * limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.service.RedeemPromotion(f.ctx, promotion.ID, 100)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				redeemed++
+			case errors.Is(err, ErrPromotionExhausted):
+				exhausted++
+			default:
+				t.Errorf("RedeemPromotion: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if redeemed != limit || exhausted != 3*limit {
+		t.Errorf("%d redemptions succeeded and %d were exhausted, want %d and %d", redeemed, exhausted, limit, 3*limit)
+	}
+	if _, err := f.service.RedeemPromotion(f.ctx, promotion.ID, 100); !errors.Is(err, ErrPromotionExhausted) {
+		t.Errorf("RedeemPromotion after the limit = %v, want ErrPromotionExhausted", err)
+	}
+}
+
+func TestRedeemPromotionPublishesTheRedemption(t *testing.T) {
+	f := newPromotionFixture(t)
+	company := &models.Company{ID: uuid.New(), Cnpj: "12345678000190"}
+	if err := (&memory.CompanyRepository{Store: f.store}).CreateCompany(f.ctx, company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	f.ctx = requestctx.WithCompany(f.ctx, company)
+	minimum := 50.0
+	promotion := f.create(t, func(p *models.Promotion) { p.MinimumPurchaseAmount = &minimum })
+
+	if _, err := f.service.RedeemPromotion(f.ctx, promotion.ID, 10); !errors.Is(err, ErrMinimumPurchase) {
+		t.Fatalf("RedeemPromotion below the minimum = %v, want ErrMinimumPurchase", err)
+	}
+	redemption, err := f.service.RedeemPromotion(f.ctx, promotion.ID, 80)
+	if err != nil {
+		t.Fatalf("RedeemPromotion: %v", err)
+	}
+
+	messages, err := f.service.Outbox.(*memory.OutboxRepository).FindPending(f.ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	var redeemed []models.OutboxMessage
+	for _, message := range messages {
+		if message.Type == models.EventPromotionRedeemed {
+			redeemed = append(redeemed, message)
+		}
+	}
+	if len(redeemed) != 1 {
+		t.Fatalf("got %d %s events, want one for the successful redemption", len(redeemed), models.EventPromotionRedeemed)
+	}
+	event := redeemed[0]
+	if event.AggregateID != promotion.ID || event.CompanyID == nil || *event.CompanyID != company.ID {
+		t.Errorf("event of %s owned by %v, want promotion %s of company %s", event.AggregateID, event.CompanyID, promotion.ID, company.ID)
+	}
+	var data models.Redemption
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		t.Fatalf("failed to decode the event data: %v", err)
+	}
+	if data != *redemption {
+		t.Errorf("event data = %+v, want the redemption %+v", data, *redemption)
+	}
+}
+
+// TestGetPromotionsActiveAtHonorsSchedules checks that the listing filters by
+// recurring schedule, evaluated in each schedule's time zone, and pages over
+// the filtered promotions. The fixture clock is on a Monday at 12:00 UTC.
+func TestGetPromotionsActiveAtHonorsSchedules(t *testing.T) {
+	f := newPromotionFixture(t)
+	company := &models.Company{ID: uuid.New(), Cnpj: "12345678000190", Timezone: "America/Sao_Paulo"}
+	if err := (&memory.CompanyRepository{Store: f.store}).CreateCompany(f.ctx, company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+	schedule := func(schedule models.PromotionSchedule) func(*models.Promotion) {
+		return func(p *models.Promotion) { p.Schedule = &schedule }
+	}
+	always := f.create(t, nil)
+	mondays := f.create(t, schedule(models.PromotionSchedule{DaysOfWeek: []string{"monday"}}))
+	f.create(t, schedule(models.PromotionSchedule{DaysOfWeek: []string{"saturday", "sunday"}}))
+	lunch := f.create(t, schedule(models.PromotionSchedule{TimeWindows: []models.TimeWindow{{Start: "11:30", End: "13:00"}}}))
+	f.create(t, schedule(models.PromotionSchedule{TimeWindows: []models.TimeWindow{{Start: "18:00", End: "20:00"}}}))
+
+	// 12:00 UTC is 09:00 in São Paulo, the company's time zone, which the
+	// schedule takes when it names none.
+	f.ctx = requestctx.WithCompany(f.ctx, company)
+	morning := f.create(t, schedule(models.PromotionSchedule{TimeWindows: []models.TimeWindow{{Start: "08:00", End: "10:00"}}}))
+	if morning.Schedule.Timezone != company.Timezone {
+		t.Fatalf("schedule time zone = %q, want the company's %q", morning.Schedule.Timezone, company.Timezone)
+	}
+
+	want := map[uuid.UUID]bool{always.ID: true, mondays.ID: true, lunch.ID: true, morning.ID: true}
+	seen := map[uuid.UUID]bool{}
+	for offset := 0; ; offset += 3 {
+		page, err := f.service.GetPromotionsActiveAt(f.ctx, time.Time{}, 3, offset)
+		if err != nil {
+			t.Fatalf("GetPromotionsActiveAt: %v", err)
+		}
+		for _, promotion := range page {
+			if !want[promotion.ID] || seen[promotion.ID] {
+				t.Errorf("GetPromotionsActiveAt returned %s, which is not active now or was already listed", promotion.ID)
+			}
+			seen[promotion.ID] = true
+		}
+		if len(page) < 3 {
+			break
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("GetPromotionsActiveAt listed %d promotions, want %d", len(seen), len(want))
+	}
+
+	saturdayEvening := time.Date(2026, 3, 7, 19, 0, 0, 0, time.UTC)
+	promotions, err := f.service.GetPromotionsActiveAt(f.ctx, saturdayEvening, 10, 0)
+	if err != nil {
+		t.Fatalf("GetPromotionsActiveAt: %v", err)
+	}
+	if len(promotions) != 3 {
+		t.Errorf("GetPromotionsActiveAt on Saturday evening listed %d promotions, want always, weekend and evening", len(promotions))
+	}
+}
+
+func TestDeletePromotionKeepsItUntilPurged(t *testing.T) {
+	f := newPromotionFixture(t)
+	deleted := f.create(t, nil)
+	kept := f.create(t, nil)
+
+	if err := f.service.DeletePromotion(f.ctx, deleted.ID); err != nil {
+		t.Fatalf("DeletePromotion: %v", err)
+	}
+	if err := f.service.DeletePromotion(f.ctx, deleted.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeletePromotion twice = %v, want ErrNotFound", err)
+	}
+	if _, err := f.service.GetPromotion(f.ctx, deleted.ID, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPromotion of a deleted promotion = %v, want ErrNotFound", err)
+	}
+	stored, err := f.service.GetPromotion(f.ctx, deleted.ID, true)
+	if err != nil {
+		t.Fatalf("GetPromotion including deleted: %v", err)
+	}
+	if stored.DeletedAt == nil || !stored.DeletedAt.Equal(f.clock.now) {
+		t.Errorf("deleted_at = %v, want %v", stored.DeletedAt, f.clock.now)
+	}
+	live, err := f.service.GetAllPromotions(f.ctx, false, 10, 0)
+	if err != nil {
+		t.Fatalf("GetAllPromotions: %v", err)
+	}
+	if len(live) != 1 || live[0].ID != kept.ID {
+		t.Errorf("GetAllPromotions listed %d promotions, want only the live one", len(live))
+	}
+
+	restored, err := f.service.RestorePromotion(f.ctx, deleted.ID)
+	if err != nil {
+		t.Fatalf("RestorePromotion: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Status != models.PromotionStatusActive {
+		t.Errorf("restored promotion is %s with deleted_at %v", restored.Status, restored.DeletedAt)
+	}
+	if _, err := f.service.RestorePromotion(f.ctx, deleted.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RestorePromotion of a live promotion = %v, want ErrNotFound", err)
+	}
+	messages, err := f.service.Outbox.(*memory.OutboxRepository).FindPending(f.ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	var lifecycle []string
+	for _, message := range messages {
+		if message.Type == models.EventPromotionDeleted || message.Type == models.EventPromotionRestored {
+			lifecycle = append(lifecycle, message.Type)
+		}
+	}
+	if want := []string{models.EventPromotionDeleted, models.EventPromotionRestored}; !slices.Equal(lifecycle, want) {
+		t.Errorf("lifecycle events = %v, want %v", lifecycle, want)
+	}
+
+	if err := f.service.DeletePromotion(f.ctx, deleted.ID); err != nil {
+		t.Fatalf("DeletePromotion: %v", err)
+	}
+	f.clock.now = f.clock.now.Add(24 * time.Hour)
+	if n, err := f.service.PurgeDeletedPromotions(f.ctx, 48*time.Hour); err != nil || n != 0 {
+		t.Fatalf("PurgeDeletedPromotions within the retention = %d, %v; want 0", n, err)
+	}
+	if n, err := f.service.PurgeDeletedPromotions(f.ctx, time.Hour); err != nil || n != 1 {
+		t.Fatalf("PurgeDeletedPromotions past the retention = %d, %v; want 1", n, err)
+	}
+	if _, err := f.service.GetPromotion(f.ctx, deleted.ID, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPromotion of a purged promotion = %v, want ErrNotFound", err)
+	}
+	if _, err := f.service.GetPromotion(f.ctx, kept.ID, false); err != nil {
+		t.Errorf("PurgeDeletedPromotions removed a live promotion: %v", err)
+	}
+}
+
+func TestPatchPromotion(t *testing.T) {
+	f := newPromotionFixture(t)
+	minimum, maxUsage, coupon := 50.0, 10, "SPRING"
+	promotion := f.create(t, func(p *models.Promotion) {
+		p.Description = "Ten percent off"
+		p.MinimumPurchaseAmount = &minimum
+		p.MaxUsage = &maxUsage
+		p.CouponCode = &coupon
+	})
+
+	patched, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"title":"Summer sale","discount_value":15}`), 0)
+	if err != nil {
+		t.Fatalf("PatchPromotion: %v", err)
+	}
+	if patched.Title != "Summer sale" || patched.DiscountValue != 15 {
+		t.Errorf("patched promotion = %q at %v, want Summer sale at 15", patched.Title, patched.DiscountValue)
+	}
+	if patched.Description != promotion.Description || patched.MinimumPurchaseAmount == nil || *patched.MinimumPurchaseAmount != minimum ||
+		patched.MaxUsage == nil || *patched.MaxUsage != maxUsage || patched.CouponCode == nil || *patched.CouponCode != coupon ||
+		!patched.StartDate.Equal(promotion.StartDate) || !patched.EndDate.Equal(promotion.EndDate) {
+		t.Errorf("PatchPromotion changed omitted fields: %+v", patched)
+	}
+
+	cleared, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"minimum_purchase_amount":null,"max_usage":null,"coupon_code":null}`), 0)
+	if err != nil {
+		t.Fatalf("PatchPromotion with nulls: %v", err)
+	}
+	if cleared.MinimumPurchaseAmount != nil || cleared.MaxUsage != nil || cleared.CouponCode != nil {
+		t.Errorf("null left minimum %v, max usage %v and coupon %v set", cleared.MinimumPurchaseAmount, cleared.MaxUsage, cleared.CouponCode)
+	}
+	if cleared.Title != "Summer sale" {
+		t.Errorf("title = %q after clearing other fields, want Summer sale", cleared.Title)
+	}
+
+	for _, patch := range []string{
+		`{"id":"` + uuid.NewString() + `"}`,
+		`{"current_usage":0}`,
+		`{"status":"archived"}`,
+		`{"is_active":false}`,
+		`{"created_at":"2026-01-01T00:00:00Z"}`,
+		`{"deleted_at":null}`,
+		`["title"]`,
+	} {
+		if _, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(patch), 0); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("PatchPromotion(%s) = %v, want ErrInvalidPatch", patch, err)
+		}
+	}
+	stored, err := f.service.GetPromotion(f.ctx, promotion.ID, false)
+	if err != nil {
+		t.Fatalf("GetPromotion: %v", err)
+	}
+	if stored.Status != models.PromotionStatusActive || stored.Version != cleared.Version {
+		t.Errorf("a rejected patch changed the promotion to %s at version %d", stored.Status, stored.Version)
+	}
+	if _, err := f.service.PatchPromotion(f.ctx, uuid.New(), []byte(`{"title":"Lost"}`), 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PatchPromotion of an unknown promotion = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdatePromotionChecksTheClientVersion(t *testing.T) {
+	f := newPromotionFixture(t)
+	promotion := f.create(t, nil)
+	read := promotion.Version
+
+	update := *promotion
+	update.Title = "Summer sale"
+	if err := f.service.UpdatePromotion(f.ctx, &update); err != nil {
+		t.Fatalf("UpdatePromotion at the current version: %v", err)
+	}
+	if update.Version != read+1 {
+		t.Errorf("version = %d after the update, want %d", update.Version, read+1)
+	}
+
+	stale := *promotion
+	stale.Title = "Lost update"
+	stale.Version = read
+	if err := f.service.UpdatePromotion(f.ctx, &stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("UpdatePromotion at a stale version = %v, want ErrVersionConflict", err)
+	}
+	if _, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"title":"Lost patch"}`), read); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("PatchPromotion at a stale version = %v, want ErrVersionConflict", err)
+	}
+
+	// Version 0 is If-Match: * and applies to whatever is stored.
+	unconditional := *promotion
+	unconditional.Title = "Autumn sale"
+	unconditional.Version = 0
+	if err := f.service.UpdatePromotion(f.ctx, &unconditional); err != nil {
+		t.Fatalf("UpdatePromotion without a version: %v", err)
+	}
+	patched, err := f.service.PatchPromotion(f.ctx, promotion.ID, []byte(`{"title":"Winter sale"}`), 0)
+	if err != nil {
+		t.Fatalf("PatchPromotion without a version: %v", err)
+	}
+	if patched.Title != "Winter sale" || patched.Version != read+3 {
+		t.Errorf("patched promotion = %q at version %d, want Winter sale at %d", patched.Title, patched.Version, read+3)
+	}
+}
+
+func TestPromotionRevisions(t *testing.T) {
+	f := newPromotionFixture(t)
+	created := f.clock.now
+	promotion := f.create(t, nil)
+
+	f.clock.now = created.Add(time.Hour)
+	asAdmin := requestctx.WithAdmin(f.ctx)
+	if _, err := f.service.PatchPromotion(asAdmin, promotion.ID, []byte(`{"title":"Summer sale"}`), 0); err != nil {
+		t.Fatalf("PatchPromotion: %v", err)
+	}
+	f.clock.now = created.Add(2 * time.Hour)
+	if _, err := f.service.PatchPromotion(asAdmin, promotion.ID, []byte(`{"discount_value":25}`), 0); err != nil {
+		t.Fatalf("PatchPromotion: %v", err)
+	}
+	if _, err := f.service.RedeemPromotion(f.ctx, promotion.ID, 100); err != nil {
+		t.Fatalf("RedeemPromotion: %v", err)
+	}
+
+	revisions, err := f.service.GetPromotionRevisions(f.ctx, promotion.ID)
+	if err != nil {
+		t.Fatalf("GetPromotionRevisions: %v", err)
+	}
+	wantActors := []string{ActorSystem, ActorAdmin, ActorAdmin}
+	if len(revisions) != len(wantActors) {
+		t.Fatalf("got %d revisions, want one per change", len(revisions))
+	}
+	for i, revision := range revisions {
+		if revision.Revision != i+1 || revision.Actor != wantActors[i] || !revision.CreatedAt.Equal(created.Add(time.Duration(i)*time.Hour)) {
+			t.Errorf("revision %d = %d by %s at %v", i, revision.Revision, revision.Actor, revision.CreatedAt)
+		}
+	}
+
+	tests := []struct {
+		at        time.Time
+		wantTitle string
+		wantValue float64
+	}{
+		{at: created, wantTitle: "Spring sale", wantValue: 10},
+		{at: created.Add(90 * time.Minute), wantTitle: "Summer sale", wantValue: 10},
+		{at: f.clock.now, wantTitle: "Summer sale", wantValue: 25},
+	}
+	for _, tt := range tests {
+		asOf, err := f.service.GetPromotionAsOf(f.ctx, promotion.ID, tt.at)
+		if err != nil {
+			t.Fatalf("GetPromotionAsOf(%v): %v", tt.at, err)
+		}
+		if asOf.Title != tt.wantTitle || asOf.DiscountValue != tt.wantValue {
+			t.Errorf("GetPromotionAsOf(%v) = %q at %v, want %q at %v", tt.at, asOf.Title, asOf.DiscountValue, tt.wantTitle, tt.wantValue)
+		}
+	}
+	if _, err := f.service.GetPromotionAsOf(f.ctx, promotion.ID, created.Add(-time.Second)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPromotionAsOf before the creation = %v, want ErrNotFound", err)
+	}
+
+	current, err := f.service.GetPromotion(f.ctx, promotion.ID, false)
+	if err != nil {
+		t.Fatalf("GetPromotion: %v", err)
+	}
+	if _, err := f.service.RollbackPromotion(asAdmin, promotion.ID, 1, current.Version-1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("RollbackPromotion at a stale version = %v, want ErrVersionConflict", err)
+	}
+	if _, err := f.service.RollbackPromotion(asAdmin, promotion.ID, 9, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RollbackPromotion to an unknown revision = %v, want ErrNotFound", err)
+	}
+	f.clock.now = created.Add(3 * time.Hour)
+	rolledBack, err := f.service.RollbackPromotion(asAdmin, promotion.ID, 1, current.Version)
+	if err != nil {
+		t.Fatalf("RollbackPromotion: %v", err)
+	}
+	if rolledBack.Title != "Spring sale" || rolledBack.DiscountValue != 10 {
+		t.Errorf("rolled back promotion = %q at %v, want Spring sale at 10", rolledBack.Title, rolledBack.DiscountValue)
+	}
+	if rolledBack.CurrentUsage != 1 {
+		t.Errorf("current_usage = %d after the rollback, want the stored 1", rolledBack.CurrentUsage)
+	}
+
+	revisions, err = f.service.GetPromotionRevisions(f.ctx, promotion.ID)
+	if err != nil {
+		t.Fatalf("GetPromotionRevisions: %v", err)
+	}
+	if len(revisions) != 4 || revisions[3].Revision != 4 || revisions[3].Actor != ActorAdmin {
+		t.Fatalf("got %d revisions after the rollback, want a fourth by the admin", len(revisions))
+	}
+	var snapshot models.Promotion
+	if err := json.Unmarshal(revisions[3].Snapshot, &snapshot); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snapshot.Title != "Spring sale" || snapshot.DiscountValue != 10 {
+		t.Errorf("rollback revision holds %q at %v, want Spring sale at 10", snapshot.Title, snapshot.DiscountValue)
+	}
+}
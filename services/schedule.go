@@ -0,0 +1,293 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"promo-api/models"
+)
+
+const (
+	rruleDaily   = "DAILY"
+	rruleWeekly  = "WEEKLY"
+	rruleMonthly = "MONTHLY"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// recurrence is the supported RRULE subset: FREQ (DAILY, WEEKLY, MONTHLY),
+// INTERVAL, BYDAY (without ordinals), BYMONTHDAY, BYMONTH and BYHOUR. The
+// rule selects days and hours; the promotion StartDate is its DTSTART.
+type recurrence struct {
+	freq       string
+	interval   int
+	byDay      map[time.Weekday]bool
+	byMonthDay map[int]bool
+	byMonth    map[time.Month]bool
+	byHour     map[int]bool
+}
+
+// compiledSchedule is a PromotionSchedule parsed and ready for evaluation.
+type compiledSchedule struct {
+	location *time.Location
+	days     map[time.Weekday]bool
+	windows  [][2]int
+	rule     *recurrence
+}
+
+func validateSchedule(schedule *models.PromotionSchedule) error {
+	if schedule == nil {
+		return nil
+	}
+	_, err := compileSchedule(schedule)
+	return err
+}
+
+func compileSchedule(schedule *models.PromotionSchedule) (*compiledSchedule, error) {
+	compiled := &compiledSchedule{location: time.UTC}
+
+	if schedule.Timezone != "" {
+		loc, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone %q", schedule.Timezone)
+		}
+		compiled.location = loc
+	}
+
+	if len(schedule.DaysOfWeek) > 0 {
+		compiled.days = make(map[time.Weekday]bool, len(schedule.DaysOfWeek))
+		for _, name := range schedule.DaysOfWeek {
+			day, ok := weekdayNames[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("invalid schedule day of week %q", name)
+			}
+			compiled.days[day] = true
+		}
+	}
+
+	for _, window := range schedule.TimeWindows {
+		start, err := parseClock(window.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(window.End)
+		if err != nil {
+			return nil, err
+		}
+		if start == end {
+			return nil, fmt.Errorf("schedule time window %s-%s is empty", window.Start, window.End)
+		}
+		compiled.windows = append(compiled.windows, [2]int{start, end})
+	}
+
+	if schedule.RRule != "" {
+		rule, err := parseRRule(schedule.RRule)
+		if err != nil {
+			return nil, err
+		}
+		compiled.rule = rule
+	}
+
+	return compiled, nil
+}
+
+// parseClock converts "HH:MM" into minutes since midnight.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseRRule(value string) (*recurrence, error) {
+	rule := &recurrence{interval: 1}
+
+	for _, part := range strings.Split(strings.TrimPrefix(strings.ToUpper(value), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rrule part %q", part)
+		}
+		list := strings.Split(val, ",")
+
+		switch key {
+		case "FREQ":
+			if val != rruleDaily && val != rruleWeekly && val != rruleMonthly {
+				return nil, fmt.Errorf("unsupported rrule FREQ %q", val)
+			}
+			rule.freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid rrule INTERVAL %q", val)
+			}
+			rule.interval = n
+		case "BYDAY":
+			rule.byDay = make(map[time.Weekday]bool, len(list))
+			for _, d := range list {
+				day, ok := rruleWeekdays[d]
+				if !ok {
+					return nil, fmt.Errorf("unsupported rrule BYDAY %q", d)
+				}
+				rule.byDay[day] = true
+			}
+		case "BYMONTHDAY":
+			rule.byMonthDay = make(map[int]bool, len(list))
+			for _, d := range list {
+				n, err := strconv.Atoi(d)
+				if err != nil || n < 1 || n > 31 {
+					return nil, fmt.Errorf("unsupported rrule BYMONTHDAY %q", d)
+				}
+				rule.byMonthDay[n] = true
+			}
+		case "BYMONTH":
+			rule.byMonth = make(map[time.Month]bool, len(list))
+			for _, m := range list {
+				n, err := strconv.Atoi(m)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("invalid rrule BYMONTH %q", m)
+				}
+				rule.byMonth[time.Month(n)] = true
+			}
+		case "BYHOUR":
+			rule.byHour = make(map[int]bool, len(list))
+			for _, h := range list {
+				n, err := strconv.Atoi(h)
+				if err != nil || n < 0 || n > 23 {
+					return nil, fmt.Errorf("invalid rrule BYHOUR %q", h)
+				}
+				rule.byHour[n] = true
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %q", key)
+		}
+	}
+
+	if rule.freq == "" {
+		return nil, errors.New("rrule FREQ is required")
+	}
+	return rule, nil
+}
+
+// scheduleActiveAt reports whether the promotion applies at the given instant,
+// honoring both its date interval and its recurring schedule.
+func scheduleActiveAt(promotion *models.Promotion, at time.Time) (bool, error) {
+	if at.Before(promotion.StartDate) || at.After(promotion.EndDate) {
+		return false, nil
+	}
+	if promotion.Schedule == nil {
+		return true, nil
+	}
+
+	compiled, err := compileSchedule(promotion.Schedule)
+	if err != nil {
+		return false, err
+	}
+	return compiled.matches(promotion.StartDate, at), nil
+}
+
+func (c *compiledSchedule) matches(start, at time.Time) bool {
+	local := at.In(c.location)
+	anchor := start.In(c.location)
+
+	if len(c.windows) == 0 {
+		return c.matchesDay(anchor, local)
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	for _, w := range c.windows {
+		switch {
+		case w[0] < w[1]:
+			if minute >= w[0] && minute < w[1] && c.matchesDay(anchor, local) {
+				return true
+			}
+		case minute >= w[0]:
+			if c.matchesDay(anchor, local) {
+				return true
+			}
+		case minute < w[1]:
+			// The tail of a window that started on the previous day belongs
+			// to that day's occurrence.
+			if c.matchesDay(anchor, local.AddDate(0, 0, -1)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *compiledSchedule) matchesDay(anchor, local time.Time) bool {
+	if c.days != nil && !c.days[local.Weekday()] {
+		return false
+	}
+	if c.rule != nil && !c.rule.matches(anchor, local) {
+		return false
+	}
+	return true
+}
+
+func (r *recurrence) matches(anchor, local time.Time) bool {
+	if r.byMonth != nil && !r.byMonth[local.Month()] {
+		return false
+	}
+	if r.byMonthDay != nil && !r.byMonthDay[local.Day()] {
+		return false
+	}
+	if r.byHour != nil && !r.byHour[local.Hour()] {
+		return false
+	}
+
+	switch r.freq {
+	case rruleDaily:
+		if r.byDay != nil && !r.byDay[local.Weekday()] {
+			return false
+		}
+		return daysBetween(anchor, local)%r.interval == 0
+	case rruleWeekly:
+		day := r.byDay
+		if day == nil {
+			day = map[time.Weekday]bool{anchor.Weekday(): true}
+		}
+		if !day[local.Weekday()] {
+			return false
+		}
+		return daysBetween(startOfWeek(anchor), startOfWeek(local))/7%r.interval == 0
+	case rruleMonthly:
+		if r.byDay != nil && !r.byDay[local.Weekday()] {
+			return false
+		}
+		if r.byDay == nil && r.byMonthDay == nil && local.Day() != anchor.Day() {
+			return false
+		}
+		months := (local.Year()-anchor.Year())*12 + int(local.Month()-anchor.Month())
+		return months%r.interval == 0
+	}
+	return false
+}
+
+// daysBetween counts calendar days from a to b, ignoring the time of day.
+func daysBetween(a, b time.Time) int {
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
+
+// startOfWeek returns the Monday of t's week, matching the RRULE default WKST.
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"promo-api/models"
+)
+
+func date(t *testing.T, loc, value string) time.Time {
+	t.Helper()
+	location, err := time.LoadLocation(loc)
+	if err != nil {
+		t.Fatalf("load %s: %v", loc, err)
+	}
+	at, err := time.ParseInLocation("2006-01-02 15:04", value, location)
+	if err != nil {
+		t.Fatalf("parse %q: %v", value, err)
+	}
+	return at
+}
+
+func TestScheduleActiveAt(t *testing.T) {
+	type check struct {
+		at     string
+		active bool
+	}
+	tests := []struct {
+		name     string
+		start    string
+		schedule models.PromotionSchedule
+		checks   []check
+	}{
+		{
+			name:     "daily every other day counts from the start date",
+			start:    "2026-03-02 00:00",
+			schedule: models.PromotionSchedule{RRule: "FREQ=DAILY;INTERVAL=2"},
+			checks: []check{
+				{"2026-03-02 10:00", true},
+				{"2026-03-03 10:00", false},
+				{"2026-03-04 23:59", true},
+				{"2026-03-31 10:00", false},
+				{"2026-04-01 10:00", true},
+			},
+		},
+		{
+			name:     "weekly without BYDAY repeats the start weekday",
+			start:    "2026-03-04 00:00",
+			schedule: models.PromotionSchedule{RRule: "FREQ=WEEKLY"},
+			checks: []check{
+				{"2026-03-11 10:00", true},
+				{"2026-03-12 10:00", false},
+			},
+		},
+		{
+			name:     "weekly every other week counts weeks from the start week",
+			start:    "2026-03-04 00:00",
+			schedule: models.PromotionSchedule{RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+			checks: []check{
+				{"2026-03-06 10:00", true},
+				{"2026-03-09 10:00", false},
+				{"2026-03-13 10:00", false},
+				{"2026-03-16 10:00", true},
+				{"2026-03-17 10:00", false},
+				{"2026-03-20 10:00", true},
+			},
+		},
+		{
+			name:     "weeks start on monday",
+			start:    "2026-03-04 00:00",
+			schedule: models.PromotionSchedule{RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=SU"},
+			checks: []check{
+				// The first sunday closes the start week.
+				{"2026-03-08 10:00", true},
+				{"2026-03-15 10:00", false},
+				{"2026-03-22 10:00", true},
+			},
+		},
+		{
+			name:     "monthly every third month keeps the start day",
+			start:    "2026-01-15 00:00",
+			schedule: models.PromotionSchedule{RRule: "FREQ=MONTHLY;INTERVAL=3"},
+			checks: []check{
+				{"2026-01-15 10:00", true},
+				{"2026-02-15 10:00", false},
+				{"2026-04-15 10:00", true},
+				{"2026-04-16 10:00", false},
+				{"2027-01-15 10:00", true},
+			},
+		},
+		{
+			name:     "monthly BYMONTHDAY=31 skips shorter months",
+			start:    "2026-01-01 00:00",
+			schedule: models.PromotionSchedule{RRule: "FREQ=MONTHLY;BYMONTHDAY=31"},
+			checks: []check{
+				{"2026-01-31 10:00", true},
+				{"2026-02-28 10:00", false},
+				{"2026-03-01 10:00", false},
+				{"2026-04-30 10:00", false},
+				{"2026-05-31 10:00", true},
+			},
+		},
+		{
+			name:  "window past midnight belongs to the day it starts",
+			start: "2026-03-01 00:00",
+			schedule: models.PromotionSchedule{
+				DaysOfWeek:  []string{"friday"},
+				TimeWindows: []models.TimeWindow{{Start: "22:00", End: "02:00"}},
+			},
+			checks: []check{
+				{"2026-03-06 21:59", false},
+				{"2026-03-06 22:00", true},
+				{"2026-03-06 23:30", true},
+				{"2026-03-07 01:59", true},
+				{"2026-03-07 02:00", false},
+				{"2026-03-07 23:00", false},
+				{"2026-03-06 01:00", false},
+			},
+		},
+		{
+			name:  "window past midnight follows the rule interval",
+			start: "2026-03-02 00:00",
+			schedule: models.PromotionSchedule{
+				TimeWindows: []models.TimeWindow{{Start: "22:00", End: "02:00"}},
+				RRule:       "FREQ=DAILY;INTERVAL=2",
+			},
+			checks: []check{
+				{"2026-03-02 23:00", true},
+				{"2026-03-03 01:00", true},
+				{"2026-03-03 23:00", false},
+				{"2026-03-04 01:00", false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promotion := &models.Promotion{
+				StartDate: date(t, "UTC", tt.start),
+				EndDate:   date(t, "UTC", "2030-01-01 00:00"),
+				Schedule:  &tt.schedule,
+			}
+			for _, c := range tt.checks {
+				clock := &fakeClock{now: date(t, "UTC", c.at)}
+				service := &PromotionService{Clock: clock}
+				active, err := service.IsActiveAt(promotion, service.now())
+				if err != nil {
+					t.Fatalf("%s: %v", c.at, err)
+				}
+				if active != c.active {
+					t.Errorf("%s: active = %v, want %v", c.at, active, c.active)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleActiveAtAcrossDST(t *testing.T) {
+	const zone = "America/New_York"
+	promotion := &models.Promotion{
+		StartDate: date(t, zone, "2026-03-06 00:30"),
+		EndDate:   date(t, zone, "2026-12-31 00:00"),
+		Schedule: &models.PromotionSchedule{
+			Timezone:    zone,
+			TimeWindows: []models.TimeWindow{{Start: "09:00", End: "17:00"}},
+			RRule:       "FREQ=DAILY;INTERVAL=2",
+		},
+	}
+	tests := []struct {
+		at     time.Time
+		active bool
+	}{
+		// Clocks go forward on 2026-03-08; the window stays on local time.
+		{time.Date(2026, 3, 6, 14, 30, 0, 0, time.UTC), true},  // 09:30 EST
+		{time.Date(2026, 3, 6, 21, 30, 0, 0, time.UTC), true},  // 16:30 EST
+		{time.Date(2026, 3, 8, 13, 30, 0, 0, time.UTC), true},  // 09:30 EDT
+		{time.Date(2026, 3, 8, 12, 30, 0, 0, time.UTC), false}, // 08:30 EDT
+		{time.Date(2026, 3, 8, 20, 30, 0, 0, time.UTC), true},  // 16:30 EDT
+		{time.Date(2026, 3, 8, 21, 30, 0, 0, time.UTC), false}, // 17:30 EDT
+		{time.Date(2026, 3, 9, 13, 30, 0, 0, time.UTC), false}, // off day
+		{time.Date(2026, 3, 10, 4, 30, 0, 0, time.UTC), false}, // 00:30 EDT
+		{time.Date(2026, 3, 10, 13, 30, 0, 0, time.UTC), true}, // 09:30 EDT
+		// Clocks go back on 2026-11-01, an on day.
+		{time.Date(2026, 11, 1, 13, 30, 0, 0, time.UTC), false}, // 08:30 EST
+		{time.Date(2026, 11, 1, 14, 30, 0, 0, time.UTC), true},  // 09:30 EST
+		{time.Date(2026, 11, 1, 22, 30, 0, 0, time.UTC), false}, // 17:30 EST
+	}
+	for _, tt := range tests {
+		clock := &fakeClock{now: tt.at}
+		service := &PromotionService{Clock: clock}
+		active, err := service.IsActiveAt(promotion, service.now())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.at, err)
+		}
+		if active != tt.active {
+			t.Errorf("%s (%s): active = %v, want %v", tt.at, tt.at.In(promotion.StartDate.Location()), active, tt.active)
+		}
+	}
+}
+
+func TestValidateScheduleRejectsUnsupportedRRules(t *testing.T) {
+	tests := []struct {
+		rrule string
+		err   string
+	}{
+		{"FREQ=YEARLY", "unsupported rrule FREQ"},
+		{"FREQ=HOURLY", "unsupported rrule FREQ"},
+		{"INTERVAL=2", "rrule FREQ is required"},
+		{"FREQ=DAILY;INTERVAL=0", "invalid rrule INTERVAL"},
+		{"FREQ=DAILY;COUNT=3", "unsupported rrule part"},
+		{"FREQ=DAILY;UNTIL=20261231T000000Z", "unsupported rrule part"},
+		{"FREQ=MONTHLY;BYSETPOS=-1", "unsupported rrule part"},
+		{"FREQ=WEEKLY;WKST=SU", "unsupported rrule part"},
+		{"FREQ=MONTHLY;BYDAY=1MO", "unsupported rrule BYDAY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "unsupported rrule BYMONTHDAY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", "unsupported rrule BYMONTHDAY"},
+		{"FREQ=DAILY;BYHOUR=24", "invalid rrule BYHOUR"},
+		{"FREQ=DAILY;BYMONTH=13", "invalid rrule BYMONTH"},
+		{"FREQ", "invalid rrule part"},
+	}
+	for _, tt := range tests {
+		err := validateSchedule(&models.PromotionSchedule{RRule: tt.rrule})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.rrule, err, tt.err)
+		}
+	}
+
+	for _, rrule := range []string{
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE",
+		"freq=monthly;interval=2;bymonthday=1,15",
+		"FREQ=DAILY;BYMONTH=12;BYHOUR=18,19",
+	} {
+		if err := validateSchedule(&models.PromotionSchedule{RRule: rrule}); err != nil {
+			t.Errorf("%s: %v", rrule, err)
+		}
+	}
+}
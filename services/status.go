@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"promo-api/models"
+)
+
+var promotionStatuses = map[string]bool{
+	models.PromotionStatusDraft:     true,
+	models.PromotionStatusScheduled: true,
+	models.PromotionStatusActive:    true,
+	models.PromotionStatusPaused:    true,
+	models.PromotionStatusExpired:   true,
+	models.PromotionStatusExhausted: true,
+	models.PromotionStatusArchived:  true,
+}
+
+// manualStatuses are only entered and left through explicit transitions.
+var manualStatuses = map[string]bool{
+	models.PromotionStatusDraft:    true,
+	models.PromotionStatusPaused:   true,
+	models.PromotionStatusArchived: true,
+}
+
+// transitionEvents maps explicit transitions to the event they emit.
+var transitionEvents = map[string]string{
+	models.TransitionPause:   models.EventPromotionPaused,
+	models.TransitionResume:  models.EventPromotionResumed,
+	models.TransitionArchive: models.EventPromotionArchived,
+}
+
+// statusEvents maps the lifecycle statuses reached automatically to the event
+// emitted when a promotion enters them.
+var statusEvents = map[string]string{
+	models.PromotionStatusScheduled: models.EventPromotionScheduled,
+	models.PromotionStatusActive:    models.EventPromotionActivated,
+	models.PromotionStatusExpired:   models.EventPromotionExpired,
+	models.PromotionStatusExhausted: models.EventPromotionExhausted,
+}
+
+// PromotionStatus computes the lifecycle status of a promotion at the given
+// instant. Keep in sync with repositories.computedStatus; the repository
+// contract checks that both agree.
+func PromotionStatus(promotion *models.Promotion, at time.Time) string {
+	switch {
+	case manualStatuses[promotion.Status]:
+		return promotion.Status
+	case at.After(promotion.EndDate):
+		return models.PromotionStatusExpired
+	case promotion.MaxUsage != nil && promotion.CurrentUsage >= *promotion.MaxUsage:
+		return models.PromotionStatusExhausted
+	case at.Before(promotion.StartDate):
+		return models.PromotionStatusScheduled
+	default:
+		return models.PromotionStatusActive
+	}
+}
+
+// applyStatus refreshes the computed status of a promotion before it is
+// returned, so IsActive never reports an expired or exhausted promotion.
+func applyStatus(promotion *models.Promotion, at time.Time) {
+	promotion.Status = PromotionStatus(promotion, at)
+	promotion.IsActive = promotion.Status == models.PromotionStatusActive
+}
+
+func applyStatuses(promotions []models.Promotion, at time.Time) {
+	for i := range promotions {
+		applyStatus(&promotions[i], at)
+	}
+}
+
+// nextStatus is the promotion state machine. It returns the status to store
+// when action is applied at the given instant:
+//
+//	pause:   scheduled, active, exhausted -> paused
+//	resume:  draft, paused -> scheduled, active or exhausted (never expired)
+//	archive: any status but archived -> archived
+func nextStatus(promotion *models.Promotion, action string, at time.Time) (string, error) {
+	current := PromotionStatus(promotion, at)
+
+	switch action {
+	case models.TransitionPause:
+		switch current {
+		case models.PromotionStatusScheduled, models.PromotionStatusActive, models.PromotionStatusExhausted:
+			return models.PromotionStatusPaused, nil
+		}
+	case models.TransitionResume:
+		if current == models.PromotionStatusDraft || current == models.PromotionStatusPaused {
+			resumed := *promotion
+			resumed.Status = ""
+			next := PromotionStatus(&resumed, at)
+			if next == models.PromotionStatusExpired {
+				return "", fmt.Errorf("%w: cannot resume an expired promotion", ErrInvalidTransition)
+			}
+			return next, nil
+		}
+	case models.TransitionArchive:
+		if current != models.PromotionStatusArchived {
+			return models.PromotionStatusArchived, nil
+		}
+	default:
+		return "", fmt.Errorf("%w: unknown action %q", ErrInvalidTransition, action)
+	}
+
+	return "", fmt.Errorf("%w: cannot %s a promotion that is %s", ErrInvalidTransition, action, current)
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories/memory"
+	"promo-api/requestctx"
+)
+
+func TestPromotionStatus(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	limit := 3
+	tests := []struct {
+		name       string
+		status     string
+		start, end time.Time
+		usage      int
+		want       string
+	}{
+		{"before the start", "", now.Add(time.Hour), now.Add(2 * time.Hour), 0, models.PromotionStatusScheduled},
+		{"at the start", "", now, now.Add(time.Hour), 0, models.PromotionStatusActive},
+		{"at the end", "", now.Add(-time.Hour), now, 0, models.PromotionStatusActive},
+		{"after the end", "", now.Add(-2 * time.Hour), now.Add(-time.Hour), 0, models.PromotionStatusExpired},
+		{"at max usage", "", now.Add(-time.Hour), now.Add(time.Hour), limit, models.PromotionStatusExhausted},
+		{"exhausted and ended", "", now.Add(-2 * time.Hour), now.Add(-time.Hour), limit, models.PromotionStatusExpired},
+		{"stale stored status", models.PromotionStatusScheduled, now.Add(-time.Hour), now.Add(time.Hour), 0, models.PromotionStatusActive},
+		{"draft", models.PromotionStatusDraft, now.Add(-time.Hour), now.Add(time.Hour), 0, models.PromotionStatusDraft},
+		{"paused after the end", models.PromotionStatusPaused, now.Add(-2 * time.Hour), now.Add(-time.Hour), 0, models.PromotionStatusPaused},
+		{"archived", models.PromotionStatusArchived, now.Add(-time.Hour), now.Add(time.Hour), 0, models.PromotionStatusArchived},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promotion := &models.Promotion{Status: tt.status, StartDate: tt.start, EndDate: tt.end, MaxUsage: &limit, CurrentUsage: tt.usage}
+			applyStatus(promotion, now)
+			if promotion.Status != tt.want || promotion.IsActive != (tt.want == models.PromotionStatusActive) {
+				t.Errorf("status = %s, is_active = %v; want %s", promotion.Status, promotion.IsActive, tt.want)
+			}
+		})
+	}
+}
+
+// TestSyncStatuses checks that the scheduler stores the status promotions
+// reach at their boundaries, once, recording each transition and event.
+func TestSyncStatuses(t *testing.T) {
+	f := newPromotionFixture(t)
+	starting := f.create(t, func(p *models.Promotion) {
+		p.StartDate = f.clock.now.Add(time.Hour)
+		p.EndDate = f.clock.now.Add(3 * time.Hour)
+	})
+	ending := f.create(t, func(p *models.Promotion) { p.EndDate = f.clock.now.Add(time.Hour) })
+	draft := f.create(t, func(p *models.Promotion) { p.IsActive = false })
+
+	if n, err := f.service.SyncStatuses(f.ctx); err != nil || n != 0 {
+		t.Fatalf("SyncStatuses before any boundary = %d, %v; want 0", n, err)
+	}
+
+	f.clock.now = f.clock.now.Add(2 * time.Hour)
+	if n, err := f.service.SyncStatuses(f.ctx); err != nil || n != 2 {
+		t.Fatalf("SyncStatuses = %d, %v; want 2", n, err)
+	}
+	if n, err := f.service.SyncStatuses(f.ctx); err != nil || n != 0 {
+		t.Fatalf("SyncStatuses again = %d, %v; want 0", n, err)
+	}
+
+	for _, tt := range []struct {
+		promotion *models.Promotion
+		want      string
+	}{
+		{starting, models.PromotionStatusActive},
+		{ending, models.PromotionStatusExpired},
+		{draft, models.PromotionStatusDraft},
+	} {
+		stored, err := f.repo.FindByID(f.ctx, tt.promotion.ID)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		if stored.Status != tt.want {
+			t.Errorf("stored status = %s, want %s", stored.Status, tt.want)
+		}
+	}
+
+	transitions, err := f.service.GetPromotionTransitions(f.ctx, ending.ID)
+	if err != nil {
+		t.Fatalf("GetPromotionTransitions: %v", err)
+	}
+	if len(transitions) != 1 || transitions[0].Action != models.TransitionSync || transitions[0].Actor != ActorSystem ||
+		transitions[0].FromStatus != models.PromotionStatusActive || transitions[0].ToStatus != models.PromotionStatusExpired {
+		t.Errorf("transitions = %+v, want one sync by the system from active to expired", transitions)
+	}
+
+	messages, err := f.service.Outbox.(*memory.OutboxRepository).FindPending(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatalf("FindPending: %v", err)
+	}
+	events := map[string]int{}
+	for _, message := range messages {
+		events[message.Type]++
+	}
+	if events[models.EventPromotionActivated] != 1 || events[models.EventPromotionExpired] != 1 {
+		t.Errorf("events = %v, want one %s and one %s", events, models.EventPromotionActivated, models.EventPromotionExpired)
+	}
+
+	limit := 1
+	limited := f.create(t, func(p *models.Promotion) { p.MaxUsage = &limit })
+	if _, err := f.service.RedeemPromotion(f.ctx, limited.ID, 100); err != nil {
+		t.Fatalf("RedeemPromotion: %v", err)
+	}
+	if n, err := f.service.SyncStatuses(f.ctx); err != nil || n != 1 {
+		t.Fatalf("SyncStatuses after the last redemption = %d, %v; want 1", n, err)
+	}
+	stored, err := f.repo.FindByID(f.ctx, limited.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if stored.Status != models.PromotionStatusExhausted {
+		t.Errorf("stored status after the last redemption = %s, want exhausted", stored.Status)
+	}
+}
+
+func TestNextStatus(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	limit := 1
+	promotion := func(status string, start, end time.Time, usage int) *models.Promotion {
+		return &models.Promotion{Status: status, StartDate: start, EndDate: end, MaxUsage: &limit, CurrentUsage: usage}
+	}
+	running := func(status string) *models.Promotion {
+		return promotion(status, now.Add(-time.Hour), now.Add(time.Hour), 0)
+	}
+	upcoming := promotion(models.PromotionStatusPaused, now.Add(time.Hour), now.Add(2*time.Hour), 0)
+	ended := promotion(models.PromotionStatusPaused, now.Add(-2*time.Hour), now.Add(-time.Hour), 0)
+	used := promotion("", now.Add(-time.Hour), now.Add(time.Hour), limit)
+
+	tests := []struct {
+		name      string
+		promotion *models.Promotion
+		action    string
+		want      string
+	}{
+		{"pause scheduled", promotion("", now.Add(time.Hour), now.Add(2*time.Hour), 0), models.TransitionPause, models.PromotionStatusPaused},
+		{"pause active", running(""), models.TransitionPause, models.PromotionStatusPaused},
+		{"pause exhausted", used, models.TransitionPause, models.PromotionStatusPaused},
+		{"pause draft", running(models.PromotionStatusDraft), models.TransitionPause, ""},
+		{"pause paused", running(models.PromotionStatusPaused), models.TransitionPause, ""},
+		{"pause expired", promotion("", now.Add(-2*time.Hour), now.Add(-time.Hour), 0), models.TransitionPause, ""},
+		{"resume draft", running(models.PromotionStatusDraft), models.TransitionResume, models.PromotionStatusActive},
+		{"resume before the start", upcoming, models.TransitionResume, models.PromotionStatusScheduled},
+		{"resume after the end", ended, models.TransitionResume, ""},
+		{"resume active", running(""), models.TransitionResume, ""},
+		{"resume archived", running(models.PromotionStatusArchived), models.TransitionResume, ""},
+		{"archive paused", running(models.PromotionStatusPaused), models.TransitionArchive, models.PromotionStatusArchived},
+		{"archive expired", ended, models.TransitionArchive, models.PromotionStatusArchived},
+		{"archive archived", running(models.PromotionStatusArchived), models.TransitionArchive, ""},
+		{"unknown action", running(""), "delete", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextStatus(tt.promotion, tt.action, now)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Errorf("nextStatus = %q, %v; want ErrInvalidTransition", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("nextStatus = %q, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestTransitionsRecordTheActor checks that each transition is recorded with
+// who performed it, and that archived promotions can no longer change.
+func TestTransitionsRecordTheActor(t *testing.T) {
+	f := newPromotionFixture(t)
+	company := &models.Company{ID: uuid.New(), Cnpj: "12345678000190"}
+	promotion := f.create(t, nil)
+	asCompany := requestctx.WithCompany(f.ctx, company)
+	asAdmin := requestctx.WithAdmin(f.ctx)
+
+	paused, err := f.service.PausePromotion(asCompany, promotion.ID)
+	if err != nil {
+		t.Fatalf("PausePromotion: %v", err)
+	}
+	if paused.Status != models.PromotionStatusPaused || paused.IsActive || paused.Version != promotion.Version+1 {
+		t.Errorf("paused promotion is %s, is_active %v at version %d", paused.Status, paused.IsActive, paused.Version)
+	}
+	if _, err := f.service.PausePromotion(asCompany, promotion.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("PausePromotion twice = %v, want ErrInvalidTransition", err)
+	}
+	pausedAt := f.clock.now
+	f.clock.now = f.clock.now.Add(time.Minute)
+	if _, err := f.service.ResumePromotion(asAdmin, promotion.ID); err != nil {
+		t.Fatalf("ResumePromotion: %v", err)
+	}
+	resumedAt := f.clock.now
+	f.clock.now = f.clock.now.Add(time.Minute)
+	if _, err := f.service.ArchivePromotion(asAdmin, promotion.ID); err != nil {
+		t.Fatalf("ArchivePromotion: %v", err)
+	}
+	if _, err := f.service.ResumePromotion(asAdmin, promotion.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("ResumePromotion of an archived promotion = %v, want ErrInvalidTransition", err)
+	}
+	update := *promotion
+	update.Title = "Archived sale"
+	if err := f.service.UpdatePromotion(asAdmin, &update); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("UpdatePromotion of an archived promotion = %v, want ErrInvalidTransition", err)
+	}
+
+	transitions, err := f.service.GetPromotionTransitions(f.ctx, promotion.ID)
+	if err != nil {
+		t.Fatalf("GetPromotionTransitions: %v", err)
+	}
+	want := []struct {
+		action, from, to, actor string
+		at                      time.Time
+	}{
+		{models.TransitionPause, models.PromotionStatusActive, models.PromotionStatusPaused, "company:" + company.ID.String(), pausedAt},
+		{models.TransitionResume, models.PromotionStatusPaused, models.PromotionStatusActive, ActorAdmin, resumedAt},
+		{models.TransitionArchive, models.PromotionStatusActive, models.PromotionStatusArchived, ActorAdmin, f.clock.now},
+	}
+	if len(transitions) != len(want) {
+		t.Fatalf("got %d transitions, want %d", len(transitions), len(want))
+	}
+	for i, w := range want {
+		got := transitions[i]
+		if got.Action != w.action || got.FromStatus != w.from || got.ToStatus != w.to || got.Actor != w.actor || !got.CreatedAt.Equal(w.at) {
+			t.Errorf("transition %d = %+v, want %s from %s to %s by %s at %s", i, got, w.action, w.from, w.to, w.actor, w.at)
+		}
+	}
+}
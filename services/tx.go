@@ -0,0 +1,18 @@
+package services
+
+import (
+	"context"
+
+	"promo-api/repositories"
+)
+
+// withTx runs fn inside a transaction when the service has a Transactor, so
+// a change, its audit record and its events commit or roll back together.
+// Inside another withTx call fn runs in a savepoint, so one service method
+// can call another and handle its failure without losing its own writes.
+func withTx(ctx context.Context, tx repositories.Transactor, fn func(ctx context.Context) error) error {
+	if tx == nil {
+		return fn(ctx)
+	}
+	return tx.WithTx(ctx, fn)
+}
@@ -0,0 +1,488 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/events"
+	"promo-api/models"
+	"promo-api/repositories"
+	"promo-api/requestctx"
+	"promo-api/tracing"
+	"promo-api/utils"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of a webhook payload.
+	SignatureHeader = "X-Webhook-Signature"
+
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookBaseBackoff = 30 * time.Second
+	defaultWebhookMaxBackoff  = time.Hour
+	defaultWebhookTimeout     = 10 * time.Second
+
+	// maxWebhookDrain bounds how much of a response is read, so the
+	// connection can be reused. Responses are never stored: they could
+	// reveal what an internal target returned.
+	maxWebhookDrain = 4096
+)
+
+// webhookEventTypes are the event types a webhook may subscribe to.
+var webhookEventTypes = map[string]bool{
+	models.EventPromotionCreated:   true,
+	models.EventPromotionUpdated:   true,
+	models.EventPromotionActivated: true,
+	models.EventPromotionScheduled: true,
+	models.EventPromotionExpired:   true,
+	models.EventPromotionExhausted: true,
+	models.EventPromotionPaused:    true,
+	models.EventPromotionResumed:   true,
+	models.EventPromotionArchived:  true,
+	models.EventPromotionDeleted:   true,
+	models.EventPromotionRestored:  true,
+	models.EventPromotionRedeemed:  true,
+}
+
+type WebhookServiceInterface interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	GetDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]models.WebhookDelivery, error)
+	GetDeadDeliveries(ctx context.Context, limit, offset int) ([]models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+// WebhookService manages company webhooks and delivers events to them. As an
+// events.Publisher it enqueues one delivery per subscribed webhook; DeliverDue
+// sends them, retrying failures with exponential backoff until MaxAttempts,
+// after which the delivery is dead-lettered.
+type WebhookService struct {
+	Repo   repositories.WebhookRepositoryInterface
+	Clock  utils.Clock
+	Client *http.Client
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// AllowPrivateTargets lets webhooks target loopback and private
+	// addresses, for local development. Never set it in production.
+	AllowPrivateTargets bool
+
+	clientOnce    sync.Once
+	defaultClient *http.Client
+}
+
+var (
+	_ WebhookServiceInterface = &WebhookService{}
+	_ events.Publisher        = &WebhookService{}
+)
+
+func (s *WebhookService) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+func (s *WebhookService) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	s.clientOnce.Do(func() {
+		s.defaultClient = newWebhookClient(defaultWebhookTimeout, s.AllowPrivateTargets)
+	})
+	return s.defaultClient
+}
+
+func (s *WebhookService) maxAttempts() int {
+	if s.MaxAttempts <= 0 {
+		return defaultWebhookMaxAttempts
+	}
+	return s.MaxAttempts
+}
+
+// backoff returns the delay before the attempt following the given number
+// of failed attempts: BaseBackoff doubled per failure, capped at MaxBackoff.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	base, limit := s.BaseBackoff, s.MaxBackoff
+	if base <= 0 {
+		base = defaultWebhookBaseBackoff
+	}
+	if limit <= 0 {
+		limit = defaultWebhookMaxBackoff
+	}
+
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= limit {
+			return limit
+		}
+	}
+	return min(delay, limit)
+}
+
+// companyID returns the company whose webhooks the caller manages.
+func companyID(ctx context.Context) (uuid.UUID, error) {
+	company, ok := requestctx.Company(ctx)
+	if !ok {
+		return uuid.Nil, ErrCompanyRequired
+	}
+	return company.ID, nil
+}
+
+func (s *WebhookService) validateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return fmt.Errorf("invalid webhook url %q, expected an absolute http or https URL", webhook.URL)
+	}
+	if !s.AllowPrivateTargets {
+		if err := checkWebhookHost(ctx, target.Hostname()); err != nil {
+			return err
+		}
+	}
+	for _, eventType := range webhook.EventTypes {
+		if !webhookEventTypes[eventType] {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+// CreateWebhook registers a webhook for the calling company and generates its
+// signing secret. The secret is only returned here.
+func (s *WebhookService) CreateWebhook(ctx context.Context, webhook *models.Webhook) (err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.CreateWebhook")
+	defer end(&err)
+
+	owner, err := companyID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.validateWebhook(ctx, webhook); err != nil {
+		return err
+	}
+
+	secret, err := utils.GenerateAPIKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	webhook.ID = uuid.New()
+	webhook.CompanyID = owner
+	webhook.Secret = secret
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+	webhook.IsActive = true
+	now := s.now()
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+
+	if err := s.Repo.CreateWebhook(ctx, webhook); err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+func (s *WebhookService) GetWebhooks(ctx context.Context) (_ []models.Webhook, err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.GetWebhooks")
+	defer end(&err)
+
+	owner, err := companyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := s.Repo.FindByCompany(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uuid.UUID) (_ *models.Webhook, err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.GetWebhook")
+	defer end(&err)
+
+	webhook, err := s.ownedWebhook(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.DeleteWebhook")
+	defer end(&err)
+
+	if _, err := s.ownedWebhook(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if err := s.Repo.DeleteWebhook(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ownedWebhook loads a webhook of the calling company. Webhooks of other
+// companies are reported as not found.
+func (s *WebhookService) ownedWebhook(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	owner, err := companyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.CompanyID != owner {
+		return nil, fmt.Errorf("webhook not found with ID %s: %w", id, ErrNotFound)
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) GetDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) (_ []models.WebhookDelivery, err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.GetDeliveries")
+	defer end(&err)
+
+	if _, err := s.ownedWebhook(ctx, webhookID); err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	deliveries, err := s.Repo.FindDeliveries(ctx, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// GetDeadDeliveries lists the dead-lettered deliveries of the calling
+// company's webhooks.
+func (s *WebhookService) GetDeadDeliveries(ctx context.Context, limit, offset int) (_ []models.WebhookDelivery, err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.GetDeadDeliveries")
+	defer end(&err)
+
+	owner, err := companyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.Repo.FindDeadDeliveries(ctx, owner, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery for immediate delivery with a fresh retry
+// budget, whatever its current status.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID uuid.UUID) (_ *models.WebhookDelivery, err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.Redeliver")
+	defer end(&err)
+
+	if _, err := s.ownedWebhook(ctx, webhookID); err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	delivery, err := s.Repo.FindDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	if delivery.WebhookID != webhookID {
+		return nil, fmt.Errorf("webhook delivery not found with ID %s: %w", deliveryID, ErrNotFound)
+	}
+
+	now := s.now()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	delivery.UpdatedAt = now
+	if err := s.Repo.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// Publish enqueues the event for every active webhook of the company that
+// owns its aggregate subscribed to its type. Events without an owner, and
+// those webhooks cannot subscribe to, such as company events, are ignored.
+func (s *WebhookService) Publish(ctx context.Context, event models.Event) (err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.Publish")
+	defer end(&err)
+
+	if !webhookEventTypes[event.Type] || event.CompanyID == nil {
+		return nil
+	}
+
+	webhooks, err := s.Repo.FindSubscribed(ctx, *event.CompanyID, event.Type)
+	if err != nil {
+		return fmt.Errorf("failed to find webhooks for %s: %w", event.Type, err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+
+	now := s.now()
+	for _, webhook := range webhooks {
+		delivery := &models.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := s.Repo.CreateDelivery(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to enqueue %s for webhook %s: %w", event.Type, webhook.ID, err)
+		}
+	}
+	return nil
+}
+
+// DeliverDue attempts every delivery that is due and returns how many were
+// delivered successfully. Deliveries are claimed in small batches, each for
+// long enough to send the whole batch, so concurrent dispatchers on other
+// instances send each delivery once.
+func (s *WebhookService) DeliverDue(ctx context.Context) (_ int, err error) {
+	ctx, end := tracing.Start(ctx, "WebhookService.DeliverDue")
+	defer end(&err)
+
+	const batchSize = 10
+
+	timeout := s.client().Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	claim := time.Duration(batchSize+1) * timeout
+
+	delivered := 0
+	webhooks := map[uuid.UUID]*models.Webhook{}
+	for {
+		now := s.now()
+		deliveries, err := s.Repo.ClaimDueDeliveries(ctx, now, now.Add(claim), batchSize)
+		if err != nil {
+			return delivered, fmt.Errorf("failed to claim due webhook deliveries: %w", err)
+		}
+
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			webhook, ok := webhooks[delivery.WebhookID]
+			if !ok {
+				webhook, err = s.Repo.FindByID(ctx, delivery.WebhookID)
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					return delivered, fmt.Errorf("failed to get webhook %s: %w", delivery.WebhookID, err)
+				}
+				webhooks[delivery.WebhookID] = webhook
+			}
+
+			if err := s.attempt(ctx, webhook, delivery); err != nil {
+				return delivered, err
+			}
+			if delivery.Status == models.WebhookDeliverySucceeded {
+				delivered++
+			}
+		}
+
+		if len(deliveries) < batchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// attempt sends one delivery and records the outcome. webhook is nil when it
+// has been deleted.
+func (s *WebhookService) attempt(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) error {
+	var sendErr error
+	var statusCode *int
+	retry := true
+	if webhook == nil || !webhook.IsActive {
+		sendErr = errors.New("webhook is no longer active")
+		retry = false
+	} else {
+		var code int
+		code, sendErr = s.send(ctx, webhook, delivery)
+		if code != 0 {
+			statusCode = &code
+		}
+	}
+
+	if sendErr != nil {
+		slog.WarnContext(ctx, "Webhook delivery failed",
+			"delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "attempt", delivery.Attempts+1, "error", sendErr)
+	}
+
+	now := s.now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.UpdatedAt = now
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.LastError = nil
+		delivery.DeliveredAt = &now
+	case !retry || delivery.Attempts >= s.maxAttempts():
+		message := sendErr.Error()
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.NextAttemptAt = nil
+		delivery.LastError = &message
+	default:
+		message := sendErr.Error()
+		next := now.Add(s.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = &message
+	}
+
+	if err := s.Repo.UpdateDelivery(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to record webhook delivery attempt: %w", err)
+	}
+	return nil
+}
+
+// send posts the signed payload and returns the response status code, or 0
+// when no response was received. Any non-2xx response is an error.
+func (s *WebhookService) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", delivery.ID.String())
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set(SignatureHeader, utils.SignPayload(webhook.Secret, s.now(), delivery.Payload))
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookDrain))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lookupWebhookHost resolves webhook hosts at registration. Tests replace it.
+var lookupWebhookHost = net.DefaultResolver.LookupNetIP
+
+// publicAddr reports whether ip may be the target of a webhook. Webhook URLs
+// come from companies, so without this a company could make the server post
+// to the cloud metadata service, the database or anything else on its
+// internal network.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// checkWebhookHost rejects a webhook host that is, or resolves to, an
+// address publicAddr refuses. The dialer checks again when delivering, as
+// the name may resolve differently by then.
+func checkWebhookHost(ctx context.Context, host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(ip) {
+			return fmt.Errorf("webhook url must not target the non-public address %s", ip)
+		}
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("webhook url must not target %s", host)
+	}
+
+	ips, err := lookupWebhookHost(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("webhook url host %s cannot be resolved", host)
+	}
+	for _, ip := range ips {
+		if !publicAddr(ip) {
+			return fmt.Errorf("webhook url host %s resolves to the non-public address %s", host, ip.Unmap())
+		}
+	}
+	return nil
+}
+
+// dialPublicOnly is a net.Dialer Control function refusing connections to
+// non-public addresses. It runs after name resolution, so a name rebound to
+// an internal address after registration is refused too.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ip) {
+		return fmt.Errorf("webhook target %s is not a public address", ip.Unmap())
+	}
+	return nil
+}
+
+// newWebhookClient returns the client webhooks are delivered with. It does
+// not follow redirects, which could lead anywhere, and unless allowPrivate
+// it only connects to public addresses. Proxies are not used, as the check
+// would apply to the proxy instead of the target.
+func newWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = dialPublicOnly
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"promo-api/models"
+	"promo-api/repositories/memory"
+	"promo-api/requestctx"
+	"promo-api/utils"
+)
+
+// fakeClock is a utils.Clock the test moves by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// receiver is a local webhook endpoint that records what it receives and
+// answers with a configurable status code.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	w.WriteHeader(rc.status)
+}
+
+func (rc *receiver) setStatus(status int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.status = status
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+type webhookFixture struct {
+	service  *WebhookService
+	repo     *memory.WebhookRepository
+	clock    *fakeClock
+	receiver *receiver
+	ctx      context.Context
+	company  *models.Company
+	webhook  *models.Webhook
+}
+
+func newWebhookFixture(t *testing.T, status int) *webhookFixture {
+	t.Helper()
+
+	rc := &receiver{status: status}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	store := memory.NewStore()
+	company := &models.Company{ID: uuid.New(), Cnpj: "12345678000190"}
+	if err := (&memory.CompanyRepository{Store: store}).CreateCompany(context.Background(), company); err != nil {
+		t.Fatalf("CreateCompany: %v", err)
+	}
+
+	f := &webhookFixture{
+		repo:     &memory.WebhookRepository{Store: store},
+		clock:    &fakeClock{now: time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)},
+		receiver: rc,
+		ctx:      requestctx.WithCompany(context.Background(), company),
+		company:  company,
+	}
+	f.service = &WebhookService{
+		Repo:        f.repo,
+		Clock:       f.clock,
+		Client:      server.Client(),
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  time.Hour,
+
+		AllowPrivateTargets: true,
+	}
+
+	f.webhook = &models.Webhook{URL: server.URL, EventTypes: []string{models.EventPromotionCreated}}
+	if err := f.service.CreateWebhook(f.ctx, f.webhook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	return f
+}
+
+// publish publishes an event of a promotion of the fixture's company.
+func (f *webhookFixture) publish(t *testing.T, eventType string) models.Event {
+	t.Helper()
+	return f.publishFor(t, &f.company.ID, eventType)
+}
+
+func (f *webhookFixture) publishFor(t *testing.T, owner *uuid.UUID, eventType string) models.Event {
+	t.Helper()
+	event, err := models.NewEvent(eventType, models.AggregatePromotion, uuid.New(), f.clock.now, map[string]string{"title": "Spring sale"})
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	event.CompanyID = owner
+	if err := f.service.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	return event
+}
+
+func (f *webhookFixture) onlyDelivery(t *testing.T) models.WebhookDelivery {
+	t.Helper()
+	deliveries, err := f.repo.FindDeliveries(f.ctx, f.webhook.ID, "", 10, 0)
+	if err != nil {
+		t.Fatalf("FindDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+func TestWebhookDeliversSignedPayload(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusNoContent)
+	event := f.publish(t, models.EventPromotionCreated)
+	f.publish(t, models.EventPromotionDeleted) // not subscribed
+
+	n, err := f.service.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if n != 1 || f.receiver.count() != 1 {
+		t.Fatalf("delivered %d, received %d, want 1 and 1", n, f.receiver.count())
+	}
+
+	req, body := f.receiver.requests[0], f.receiver.bodies[0]
+	if !utils.VerifyPayload(f.webhook.Secret, req.Header.Get(SignatureHeader), body) {
+		t.Errorf("signature %q does not verify", req.Header.Get(SignatureHeader))
+	}
+	if utils.VerifyPayload("wrong secret", req.Header.Get(SignatureHeader), body) {
+		t.Error("signature verifies with the wrong secret")
+	}
+	if got := req.Header.Get("X-Webhook-Event"); got != models.EventPromotionCreated {
+		t.Errorf("X-Webhook-Event = %q, want %q", got, models.EventPromotionCreated)
+	}
+
+	var received models.Event
+	if err := json.Unmarshal(body, &received); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	if received.ID != event.ID {
+		t.Errorf("received event %s, want %s", received.ID, event.ID)
+	}
+
+	delivery := f.onlyDelivery(t)
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.DeliveredAt == nil {
+		t.Errorf("delivery status = %s, delivered_at = %v", delivery.Status, delivery.DeliveredAt)
+	}
+}
+
+func TestWebhookRetriesWithExponentialBackoff(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusInternalServerError)
+	f.service.MaxAttempts = 5
+	f.publish(t, models.EventPromotionCreated)
+
+	for attempt, wantDelay := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if _, err := f.service.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+		delivery := f.onlyDelivery(t)
+		if delivery.Attempts != attempt+1 {
+			t.Fatalf("attempts = %d, want %d", delivery.Attempts, attempt+1)
+		}
+		if delivery.LastStatusCode == nil || *delivery.LastStatusCode != http.StatusInternalServerError {
+			t.Errorf("last status code = %v, want 500", delivery.LastStatusCode)
+		}
+		if got := delivery.NextAttemptAt.Sub(f.clock.now); got != wantDelay {
+			t.Fatalf("attempt %d: next attempt in %s, want %s", attempt+1, got, wantDelay)
+		}
+
+		// Nothing is sent before the backoff elapses.
+		f.clock.now = f.clock.now.Add(wantDelay - time.Second)
+		if _, err := f.service.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+		if f.receiver.count() != attempt+1 {
+			t.Fatalf("received %d requests before backoff elapsed, want %d", f.receiver.count(), attempt+1)
+		}
+		f.clock.now = f.clock.now.Add(time.Second)
+	}
+}
+
+func TestWebhookDeadLetterAndRedeliver(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusBadGateway)
+	f.publish(t, models.EventPromotionCreated)
+
+	for i := 0; i < f.service.MaxAttempts; i++ {
+		if _, err := f.service.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+		f.clock.now = f.clock.now.Add(time.Hour)
+	}
+
+	dead, err := f.service.GetDeadDeliveries(f.ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("GetDeadDeliveries: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != f.service.MaxAttempts || dead[0].NextAttemptAt != nil {
+		t.Fatalf("dead deliveries = %+v, want one after %d attempts", dead, f.service.MaxAttempts)
+	}
+
+	// Dead deliveries are not retried on their own.
+	if _, err := f.service.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if f.receiver.count() != f.service.MaxAttempts {
+		t.Fatalf("received %d requests, want %d", f.receiver.count(), f.service.MaxAttempts)
+	}
+
+	f.receiver.setStatus(http.StatusOK)
+	if _, err := f.service.Redeliver(f.ctx, f.webhook.ID, dead[0].ID); err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if n, err := f.service.DeliverDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("DeliverDue = %d, %v, want 1, nil", n, err)
+	}
+	if delivery := f.onlyDelivery(t); delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 1 {
+		t.Errorf("redelivered status = %s after %d attempts", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestWebhooksAreScopedToTheirCompany(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusOK)
+	other := requestctx.WithCompany(context.Background(), &models.Company{ID: uuid.New()})
+
+	if _, err := f.service.GetWebhook(other, f.webhook.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetWebhook from another company: err = %v, want ErrNotFound", err)
+	}
+	if err := f.service.DeleteWebhook(other, f.webhook.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteWebhook from another company: err = %v, want ErrNotFound", err)
+	}
+	if _, err := f.service.GetWebhooks(context.Background()); !errors.Is(err, ErrCompanyRequired) {
+		t.Errorf("GetWebhooks without a company: err = %v, want ErrCompanyRequired", err)
+	}
+
+	webhook, err := f.service.GetWebhook(f.ctx, f.webhook.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook: %v", err)
+	}
+	if webhook.Secret != "" {
+		t.Error("GetWebhook exposes the signing secret")
+	}
+}
+
+func TestWebhooksReceiveOnlyTheirCompanysEvents(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusOK)
+	other := uuid.New()
+	f.publishFor(t, &other, models.EventPromotionCreated)
+	f.publishFor(t, nil, models.EventPromotionCreated)
+
+	deliveries, err := f.repo.FindDeliveries(f.ctx, f.webhook.ID, "", 10, 0)
+	if err != nil {
+		t.Fatalf("FindDeliveries: %v", err)
+	}
+	if len(deliveries) != 0 {
+		t.Fatalf("got %d deliveries of other companies' and unowned events, want none", len(deliveries))
+	}
+
+	f.publish(t, models.EventPromotionCreated)
+	f.onlyDelivery(t)
+}
+
+func TestCreateWebhookValidates(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusOK)
+
+	for _, webhook := range []models.Webhook{
+		{URL: "ftp://example.com/hook"},
+		{URL: "/relative"},
+		{URL: "https://example.com/hook", EventTypes: []string{"promotion.unknown"}},
+	} {
+		if err := f.service.CreateWebhook(f.ctx, &webhook); err == nil {
+			t.Errorf("CreateWebhook(%s, %v) succeeded, want error", webhook.URL, webhook.EventTypes)
+		}
+	}
+}
+
+func TestCreateWebhookRejectsNonPublicTargets(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusOK)
+	f.service.AllowPrivateTargets = false
+
+	resolved := map[string][]netip.Addr{
+		"hooks.example.com":    {netip.MustParseAddr("93.184.216.34")},
+		"internal.example.com": {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.1.2.3")},
+	}
+	lookup := lookupWebhookHost
+	lookupWebhookHost = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		if ips, ok := resolved[host]; ok {
+			return ips, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	t.Cleanup(func() { lookupWebhookHost = lookup })
+
+	for _, url := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"http://localhost:5432",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://10.0.0.7/hook",
+		"http://172.16.4.1/hook",
+		"http://192.168.1.10/hook",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+		"http://224.0.0.1/hook",
+		"https://internal.example.com/hook",
+		"https://unknown.example.com/hook",
+	} {
+		if err := f.service.CreateWebhook(f.ctx, &models.Webhook{URL: url}); err == nil {
+			t.Errorf("CreateWebhook(%s) succeeded, want error", url)
+		}
+	}
+
+	for _, url := range []string{"https://hooks.example.com/hook", "https://93.184.216.34:8443/hook"} {
+		if err := f.service.CreateWebhook(f.ctx, &models.Webhook{URL: url}); err != nil {
+			t.Errorf("CreateWebhook(%s): %v", url, err)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(&receiver{status: http.StatusOK})
+	t.Cleanup(server.Close)
+
+	_, err := newWebhookClient(time.Second, false).Post(server.URL, "application/json", nil)
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("posting to %s: err = %v, want the address refused", server.URL, err)
+	}
+}
+
+func TestWebhookDoesNotFollowRedirectsOrKeepResponses(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusOK)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the redirect was followed")
+	}))
+	t.Cleanup(target.Close)
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", target.URL)
+		w.WriteHeader(http.StatusFound)
+		io.WriteString(w, "secret internal response")
+	}))
+	t.Cleanup(redirect.Close)
+	f.service.Client = newWebhookClient(time.Second, true)
+	if err := f.service.DeleteWebhook(f.ctx, f.webhook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	f.webhook = &models.Webhook{URL: redirect.URL, EventTypes: []string{models.EventPromotionCreated}}
+	if err := f.service.CreateWebhook(f.ctx, f.webhook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	f.publish(t, models.EventPromotionCreated)
+	if _, err := f.service.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	delivery := f.onlyDelivery(t)
+	if delivery.LastStatusCode == nil || *delivery.LastStatusCode != http.StatusFound {
+		t.Errorf("last status code = %v, want 302", delivery.LastStatusCode)
+	}
+	if delivery.LastError == nil || strings.Contains(*delivery.LastError, "secret") {
+		t.Errorf("last error = %v, want the status without the response body", delivery.LastError)
+	}
+}
+
+func TestConcurrentDispatchersSendEachDeliveryOnce(t *testing.T) {
+	f := newWebhookFixture(t, http.StatusOK)
+	const events = 25
+	for range events {
+		f.publish(t, models.EventPromotionCreated)
+	}
+
+	other := &WebhookService{Repo: f.repo, Clock: f.clock, Client: f.service.Client, AllowPrivateTargets: true}
+	var wg sync.WaitGroup
+	for _, service := range []*WebhookService{f.service, other} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.DeliverDue(context.Background()); err != nil {
+				t.Errorf("DeliverDue: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := f.receiver.count(); got != events {
+		t.Errorf("received %d requests for %d deliveries, want each sent once", got, events)
+	}
+}
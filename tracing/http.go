@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"promo-api/utils"
+)
+
+// Middleware is a mux middleware that serves each request in a server span
+// named after its method and route template, such as
+// "GET /promotions/{id}". A traceparent header from the caller makes the
+// span part of the caller's trace.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		serve(route, next, w, r)
+	})
+}
+
+// Unmatched wraps the handler for requests that matched no route. Their
+// spans are named after the method alone.
+func Unmatched(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve("", next, w, r)
+	})
+}
+
+func serve(route string, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	name := r.Method
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)),
+	}
+	if route != "" {
+		name += " " + route
+		opts = append(opts, trace.WithAttributes(semconv.HTTPRoute(route)))
+	}
+	ctx, span := tracer().Start(ctx, name, opts...)
+	defer span.End()
+
+	recorder := utils.NewResponseRecorder(w)
+	next.ServeHTTP(recorder, r.WithContext(ctx))
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.Status))
+	if recorder.Status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(recorder.Status))
+	}
+}
+
+type parentSpanKey struct{}
+
+// WrapMiddleware records the work of middleware mw in a span named
+// "middleware <name>". The span ends when mw hands the request on, so it
+// covers mw alone, such as the API key lookup of ValidateAPIKey, and not the
+// handlers after it.
+func WrapMiddleware(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		// resume ends the middleware span and serves the rest of the chain
+		// under the span mw started from, keeping what mw added to the
+		// context.
+		resume := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			trace.SpanFromContext(ctx).End()
+			if parent, ok := ctx.Value(parentSpanKey{}).(trace.Span); ok {
+				ctx = trace.ContextWithSpan(ctx, parent)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), parentSpanKey{}, trace.SpanFromContext(r.Context()))
+			ctx, span := tracer().Start(ctx, "middleware "+name)
+			// Ending twice is harmless; this ends the span of requests mw
+			// answers itself.
+			defer span.End()
+			resume.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Package tracing sets up OpenTelemetry tracing and provides the helpers the
+// HTTP layer, services and repositories use to record spans. Until Setup
+// installs an exporter, spans are not recorded, but incoming W3C trace
+// context still flows through to outgoing logs and calls.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation names the tracer the spans of this module come from.
+const instrumentation = "promo-api"
+
+// Setup installs the W3C trace context propagator and a tracer provider for
+// exporter: "none", which records nothing, or "otlp", which sends spans over
+// OTLP/HTTP. The OTLP endpoint, headers and TLS, and the sampler, come from
+// the standard OTEL_EXPORTER_OTLP_* and OTEL_TRACES_SAMPLER* variables. The
+// returned function flushes pending spans and stops the provider.
+func Setup(ctx context.Context, exporter, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	switch exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP trace exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start starts a span named name, such as "PromotionService.GetPromotion",
+// as a child of the span in ctx. end finishes it, recording the error errp
+// points to, if any; defer it with the address of the caller's named error
+// result.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (_ context.Context, end func(errp *error)) {
+	ctx, span := tracer().Start(ctx, name, opts...)
+	return ctx, func(errp *error) {
+		if errp != nil && *errp != nil {
+			Fail(span, *errp)
+		}
+		span.End()
+	}
+}
+
+// Fail marks span as failed with err.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
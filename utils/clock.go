@@ -0,0 +1,15 @@
+package utils
+
+import "time"
+
+// Clock abstracts the current time so time-dependent logic can be tested.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by the system time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
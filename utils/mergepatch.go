@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidMergePatch is returned when a merge patch is not valid JSON.
+var ErrInvalidMergePatch = errors.New("invalid merge patch")
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to a JSON document: object
+// members in the patch replace those in the document, null members remove
+// them, and any non-object patch replaces the document entirely.
+func MergePatch(document, patch []byte) ([]byte, error) {
+	var patchValue any
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, ErrInvalidMergePatch
+	}
+
+	var documentValue any
+	if err := json.Unmarshal(document, &documentValue); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeValue(documentValue, patchValue))
+}
+
+func mergeValue(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,53 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"promo-api/repositories"
+)
+
+// ChangeSource yields the rows changed by any instance. It is implemented by
+// *repositories.Changes.
+type ChangeSource interface {
+	Next(ctx context.Context) (repositories.Change, error)
+}
+
+// Invalidator drops cached rows. It is implemented by the cached
+// repositories.
+type Invalidator interface {
+	Invalidate(id uuid.UUID)
+	Purge()
+}
+
+// CacheInvalidator keeps the in-process caches in step with changes made
+// through any instance. Caches maps each channel to the cache it
+// invalidates.
+type CacheInvalidator struct {
+	Changes ChangeSource
+	Caches  map[string]Invalidator
+}
+
+// Run invalidates each change as it arrives until ctx is done.
+func (i *CacheInvalidator) Run(ctx context.Context) {
+	for {
+		change, err := i.Changes.Next(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Stopped invalidating caches", "error", err)
+			}
+			return
+		}
+		if change == (repositories.Change{}) {
+			for _, cache := range i.Caches {
+				cache.Purge()
+			}
+			continue
+		}
+		if cache, ok := i.Caches[change.Channel]; ok {
+			cache.Invalidate(change.ID)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"promo-api/services"
+)
+
+// OutboxRelay periodically drains the transactional outbox to the configured
+// sinks. Events are relayed within one Interval of their commit.
+type OutboxRelay struct {
+	Service  services.OutboxServiceInterface
+	Interval time.Duration
+}
+
+// Run relays immediately and then on every tick until ctx is done.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := r.Service.Relay(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to relay the outbox", "error", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "Relayed outbox events", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
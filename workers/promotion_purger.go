@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"promo-api/services"
+)
+
+// PromotionPurger periodically removes promotions that were soft-deleted
+// longer than Retention ago.
+type PromotionPurger struct {
+	Service   services.PromotionServiceInterface
+	Interval  time.Duration
+	Retention time.Duration
+}
+
+// Run purges immediately and then on every tick until ctx is done.
+func (p *PromotionPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.Service.PurgeDeletedPromotions(ctx, p.Retention); err != nil {
+			slog.ErrorContext(ctx, "Failed to purge deleted promotions", "error", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "Purged deleted promotions", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
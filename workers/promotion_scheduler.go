@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"promo-api/services"
+)
+
+// PromotionScheduler periodically persists promotion lifecycle transitions
+// (activation at StartDate, expiry at EndDate, exhaustion at MaxUsage) and
+// emits their events. Boundaries are observed within one Interval.
+type PromotionScheduler struct {
+	Service  services.PromotionServiceInterface
+	Interval time.Duration
+}
+
+// Run syncs statuses immediately and then on every tick until ctx is done.
+func (s *PromotionScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Service.SyncStatuses(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to sync promotion statuses", "error", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "Transitioned promotions", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+// ReplicaHealthChecker pings read replicas and marks the ones that fail so
+// reads avoid them. It is implemented by *repositories.Replicas.
+type ReplicaHealthChecker interface {
+	CheckHealth(ctx context.Context, timeout time.Duration)
+}
+
+// ReplicaMonitor periodically health checks the read replicas. A replica is
+// taken out of rotation, or put back, within one Interval.
+type ReplicaMonitor struct {
+	Replicas ReplicaHealthChecker
+	Interval time.Duration
+}
+
+// Run checks immediately and then on every tick until ctx is done.
+func (m *ReplicaMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.Replicas.CheckHealth(ctx, m.Interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
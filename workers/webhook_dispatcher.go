@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"promo-api/services"
+)
+
+// WebhookDispatcher periodically sends the webhook deliveries that are due,
+// including retries whose backoff has elapsed.
+type WebhookDispatcher struct {
+	Service  services.WebhookServiceInterface
+	Interval time.Duration
+}
+
+// Run delivers immediately and then on every tick until ctx is done.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := d.Service.DeliverDue(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to deliver webhooks", "error", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "Delivered webhooks", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}